@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"vigil/processor"
+	"vigil/transport"
+)
+
+// runBackfill implements the backfill subcommand: it turns the errors logged
+// in a past time range into issues and exits, without starting the poll loop
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	configPath := fs.String("config", os.Getenv("VIGIL_CONFIG"), configUsage)
+	fromFlag := fs.String("from", "", "start of the range: an RFC 3339 timestamp, a date (2006-01-02) or a duration ago (e.g. 168h); required")
+	toFlag := fs.String("to", "", "end of the range, in the same formats as --from (default: now)")
+	chunk := fs.Duration("chunk", processor.DefaultBackfillChunk, "length of each query window")
+	notify := fs.Bool("notify", false, "send notifications for created and reopened issues")
+	fs.Parse(args)
+
+	now := time.Now()
+	if *fromFlag == "" {
+		log.Fatalf("backfill: --from is required")
+	}
+	from, err := parseBackfillTime(*fromFlag, now)
+	if err != nil {
+		log.Fatalf("Invalid --from: %v", err)
+	}
+	to := now
+	if *toFlag != "" {
+		if to, err = parseBackfillTime(*toFlag, now); err != nil {
+			log.Fatalf("Invalid --to: %v", err)
+		}
+	}
+
+	log.Printf("Starting %s backfill", transport.UserAgent())
+
+	cfg := loadConfig(*configPath)
+	setupProxy(cfg)
+	tracker := setupTracker(cfg)
+	notifiers := setupNotifiers(cfg)
+	parser := setupLineParser(cfg)
+	proc := setupProcessor(cfg, tracker, notifiers, parser)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	if err := proc.Backfill(ctx, from, to, *chunk, *notify); err != nil {
+		log.Fatalf("Backfill failed: %v", err)
+	}
+}
+
+// parseBackfillTime parses an RFC 3339 timestamp, a date, or a duration
+// before now
+func parseBackfillTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not an RFC 3339 timestamp, a date (2006-01-02) or a duration ago (e.g. 168h)", value)
+}
@@ -0,0 +1,23 @@
+package cache
+
+import "time"
+
+// Entry is the cached state of a bug ID
+type Entry struct {
+	BugID       string
+	IssueNumber int64
+	LastSeen    time.Time
+	Occurrences int
+}
+
+// Cache maps bug IDs to their Gitea issue so lookups can skip searching Gitea
+type Cache interface {
+	// Get returns the entry for a bug ID, or nil if it is not cached
+	Get(bugID string) (*Entry, error)
+	// Put inserts or replaces the entry for a bug ID
+	Put(entry Entry) error
+	// Delete removes a bug ID from the cache
+	Delete(bugID string) error
+	// Close releases any resources held by the cache
+	Close() error
+}
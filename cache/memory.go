@@ -0,0 +1,49 @@
+package cache
+
+import "sync"
+
+// MemoryCache is an in-process Cache. Its contents are lost on restart.
+type MemoryCache struct {
+	mu      sync.RWMutex
+	entries map[string]Entry
+}
+
+// NewMemory creates an empty in-memory cache
+func NewMemory() *MemoryCache {
+	return &MemoryCache{entries: make(map[string]Entry)}
+}
+
+// Get returns the entry for a bug ID, or nil if it is not cached
+func (c *MemoryCache) Get(bugID string) (*Entry, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[bugID]
+	if !ok {
+		return nil, nil
+	}
+	return &entry, nil
+}
+
+// Put inserts or replaces the entry for a bug ID
+func (c *MemoryCache) Put(entry Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[entry.BugID] = entry
+	return nil
+}
+
+// Delete removes a bug ID from the cache
+func (c *MemoryCache) Delete(bugID string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, bugID)
+	return nil
+}
+
+// Close is a no-op for the in-memory cache
+func (c *MemoryCache) Close() error {
+	return nil
+}
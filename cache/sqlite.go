@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go, so CGO_ENABLED=0 builds keep working
+)
+
+// sqliteDriver is the database/sql driver name registered by modernc.org/sqlite
+const sqliteDriver = "sqlite"
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS bugs (
+	bug_id       TEXT PRIMARY KEY,
+	issue_number INTEGER NOT NULL,
+	last_seen    INTEGER NOT NULL,
+	occurrences  INTEGER NOT NULL
+)`
+
+// SQLiteCache is a Cache persisted in a SQLite database
+type SQLiteCache struct {
+	db *sql.DB
+}
+
+// OpenSQLite opens (or creates) a SQLite cache database at path
+func OpenSQLite(path string) (*SQLiteCache, error) {
+	db, err := sql.Open(sqliteDriver, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cache database: %w", err)
+	}
+
+	// SQLite allows a single writer
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create cache schema: %w", err)
+	}
+
+	return &SQLiteCache{db: db}, nil
+}
+
+// Get returns the entry for a bug ID, or nil if it is not cached
+func (c *SQLiteCache) Get(bugID string) (*Entry, error) {
+	var entry Entry
+	var lastSeen int64
+
+	err := c.db.QueryRow(
+		`SELECT bug_id, issue_number, last_seen, occurrences FROM bugs WHERE bug_id = ?`,
+		bugID,
+	).Scan(&entry.BugID, &entry.IssueNumber, &lastSeen, &entry.Occurrences)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cache: %w", err)
+	}
+
+	entry.LastSeen = time.Unix(0, lastSeen)
+	return &entry, nil
+}
+
+// Put inserts or replaces the entry for a bug ID
+func (c *SQLiteCache) Put(entry Entry) error {
+	_, err := c.db.Exec(
+		`INSERT INTO bugs (bug_id, issue_number, last_seen, occurrences) VALUES (?, ?, ?, ?)
+		ON CONFLICT(bug_id) DO UPDATE SET
+			issue_number = excluded.issue_number,
+			last_seen = excluded.last_seen,
+			occurrences = excluded.occurrences`,
+		entry.BugID, entry.IssueNumber, entry.LastSeen.UnixNano(), entry.Occurrences,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to write cache: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a bug ID from the cache
+func (c *SQLiteCache) Delete(bugID string) error {
+	if _, err := c.db.Exec(`DELETE FROM bugs WHERE bug_id = ?`, bugID); err != nil {
+		return fmt.Errorf("failed to delete from cache: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying database
+func (c *SQLiteCache) Close() error {
+	return c.db.Close()
+}
@@ -0,0 +1,406 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds all vigil settings. Every setting can be given in the YAML
+// config file or as the environment variable named by its env tag; set
+// environment variables override file values. Values are kept as strings
+// (lists excepted) and parsed where they are used, as with env-only setups.
+// Metric alerts are structured and can only be given in the file. Queries
+// and templates (fields tagged expand) may reference environment variables
+// as ${VAR}, see Expand.
+type Config struct {
+	Gitea     Gitea     `yaml:"gitea"`
+	GitLab    GitLab    `yaml:"gitlab"`
+	Loki      Loki      `yaml:"loki"`
+	Log       Log       `yaml:"log"`
+	Server    Server    `yaml:"server"`
+	Notifiers Notifiers `yaml:"notifiers"`
+	Processor Processor `yaml:"processor"`
+
+	MetricAlerts []MetricAlert `yaml:"metric_alerts"`
+
+	// Proxy overrides HTTP_PROXY/HTTPS_PROXY/NO_PROXY for all outbound requests
+	Proxy string `yaml:"proxy" env:"VIGIL_PROXY"`
+}
+
+// HTTP holds outbound HTTP client settings. Its env names are prefixed with
+// the name of the service, e.g. GITEA_TIMEOUT.
+type HTTP struct {
+	Timeout            string `yaml:"timeout" env:"TIMEOUT"`
+	CAFile             string `yaml:"ca_file" env:"CA_FILE"`
+	InsecureSkipVerify string `yaml:"insecure_skip_verify" env:"INSECURE_SKIP_VERIFY"`
+}
+
+// Gitea holds the Gitea backend settings
+type Gitea struct {
+	URL                string `yaml:"url" env:"GITEA_URL"`
+	Token              string `yaml:"token" env:"GITEA_TOKEN"`
+	Owner              string `yaml:"owner" env:"GITEA_OWNER"`
+	Repo               string `yaml:"repo" env:"GITEA_REPO"`
+	Milestone          string `yaml:"milestone" env:"GITEA_MILESTONE"`
+	EnforceLabelColors string `yaml:"enforce_label_colors" env:"ENFORCE_LABEL_COLORS"`
+	OrgLabels          string `yaml:"org_labels" env:"GITEA_ORG_LABELS"`
+	HTTP               HTTP   `yaml:"http" env:"GITEA_"`
+}
+
+// GitLab holds the GitLab backend settings
+type GitLab struct {
+	URL     string `yaml:"url" env:"GITLAB_URL"`
+	Token   string `yaml:"token" env:"GITLAB_TOKEN"`
+	Project string `yaml:"project" env:"GITLAB_PROJECT"`
+	HTTP    HTTP   `yaml:"http" env:"GITLAB_"`
+}
+
+// Loki holds the Loki connection and query settings
+type Loki struct {
+	URL           string `yaml:"url" env:"LOKI_URL"`
+	Mode          string `yaml:"mode" env:"LOKI_MODE"`
+	PollInterval  string `yaml:"poll_interval" env:"LOKI_POLL_INTERVAL"`
+	PollJitter    string `yaml:"poll_jitter" env:"POLL_JITTER"`
+	PollOverlap   string `yaml:"poll_overlap" env:"POLL_OVERLAP"`
+	Lookback      string `yaml:"lookback" env:"LOKI_LOOKBACK"`
+	LabelSelector string `yaml:"label_selector" env:"LOKI_LABEL_SELECTOR" expand:"true"`
+	ExtraFilters  string `yaml:"extra_filters" env:"LOKI_EXTRA_FILTERS" expand:"true"`
+	MaxRetries    string `yaml:"max_retries" env:"LOKI_MAX_RETRIES"`
+	QueryLimit    string `yaml:"query_limit" env:"LOKI_QUERY_LIMIT"`
+	AutoPaginate  string `yaml:"auto_paginate" env:"LOKI_AUTO_PAGINATE"`
+	Watchdog      string `yaml:"watchdog_timeout" env:"WATCHDOG_TIMEOUT"`
+	HTTP          HTTP   `yaml:"http" env:"LOKI_"`
+}
+
+// Log holds log line parsing and logging settings
+type Log struct {
+	Level           string `yaml:"level" env:"LOG_LEVEL"`
+	TimestampField  string `yaml:"timestamp_field" env:"TS_FIELD"`
+	TimestampFormat string `yaml:"timestamp_format" env:"TS_FORMAT"`
+	ServiceLabel    string `yaml:"service_label" env:"SERVICE_LABEL"`
+	Source          string `yaml:"source" env:"SOURCE"`
+	SourceFile      string `yaml:"source_file" env:"SOURCE_FILE"`
+}
+
+// Server holds the HTTP ingest endpoint and recent errors API settings
+type Server struct {
+	Addr             string `yaml:"addr" env:"HTTP_ADDR"`
+	IngestToken      string `yaml:"ingest_token" env:"INGEST_TOKEN"`
+	RecentBufferSize string `yaml:"recent_buffer_size" env:"RECENT_BUFFER_SIZE"`
+}
+
+// Notifiers holds the notifier settings. A notifier is enabled by setting
+// its URL or credentials.
+type Notifiers struct {
+	Enabled        List   `yaml:"enabled" env:"ENABLED_NOTIFIERS"`
+	Mode           string `yaml:"mode" env:"NOTIFY_MODE"`
+	DigestInterval string `yaml:"digest_interval" env:"DIGEST_INTERVAL"`
+	DigestSchedule string `yaml:"digest_schedule" env:"DIGEST_SCHEDULE"`
+	Routes         string `yaml:"routes" env:"NOTIFY_ROUTES"`
+	Theme          string `yaml:"theme" env:"NOTIFY_THEME"`
+	Compact        string `yaml:"compact" env:"NOTIFY_COMPACT"`
+
+	MaintenanceUntil string `yaml:"maintenance_until" env:"MAINTENANCE_UNTIL"`
+	MaintenanceFile  string `yaml:"maintenance_file" env:"MAINTENANCE_FILE"`
+
+	Slack      Slack      `yaml:"slack"`
+	Discord    Discord    `yaml:"discord"`
+	Mattermost Mattermost `yaml:"mattermost"`
+	Telegram   Telegram   `yaml:"telegram"`
+	Webhook    Webhook    `yaml:"webhook"`
+	Twilio     Twilio     `yaml:"twilio"`
+	Pushover   Pushover   `yaml:"pushover"`
+}
+
+// Slack holds the Slack notifier settings
+type Slack struct {
+	WebhookURL      string `yaml:"webhook_url" env:"SLACK_WEBHOOK_URL"`
+	BotToken        string `yaml:"bot_token" env:"SLACK_BOT_TOKEN"`
+	Channel         string `yaml:"channel" env:"SLACK_CHANNEL"`
+	BlockKit        string `yaml:"block_kit" env:"SLACK_BLOCK_KIT"`
+	CriticalMention string `yaml:"critical_mention" env:"SLACK_CRITICAL_MENTION"`
+	ChannelRoutes   string `yaml:"channel_routes" env:"SLACK_CHANNEL_ROUTES"`
+	MinSeverity     string `yaml:"min_severity" env:"SLACK_MIN_SEVERITY"`
+}
+
+// Discord holds the Discord notifier settings
+type Discord struct {
+	WebhookURL      string `yaml:"webhook_url" env:"DISCORD_WEBHOOK_URL"`
+	CriticalMention string `yaml:"critical_mention" env:"DISCORD_CRITICAL_MENTION"`
+	ForumChannel    string `yaml:"forum_channel" env:"DISCORD_FORUM_CHANNEL"`
+	ChannelRoutes   string `yaml:"channel_routes" env:"DISCORD_CHANNEL_ROUTES"`
+	MinSeverity     string `yaml:"min_severity" env:"DISCORD_MIN_SEVERITY"`
+}
+
+// Mattermost holds the Mattermost notifier settings
+type Mattermost struct {
+	WebhookURL string `yaml:"webhook_url" env:"MATTERMOST_WEBHOOK_URL"`
+	Channel    string `yaml:"channel" env:"MATTERMOST_CHANNEL"`
+	Username   string `yaml:"username" env:"MATTERMOST_USERNAME"`
+
+	MinSeverity string `yaml:"min_severity" env:"MATTERMOST_MIN_SEVERITY"`
+}
+
+// Telegram holds the Telegram notifier settings
+type Telegram struct {
+	BotToken string `yaml:"bot_token" env:"TELEGRAM_BOT_TOKEN"`
+	ChatID   string `yaml:"chat_id" env:"TELEGRAM_CHAT_ID"`
+
+	MinSeverity string `yaml:"min_severity" env:"TELEGRAM_MIN_SEVERITY"`
+}
+
+// Webhook holds the generic webhook notifier settings
+type Webhook struct {
+	URL    string `yaml:"url" env:"WEBHOOK_URL"`
+	Secret string `yaml:"secret" env:"WEBHOOK_SECRET"`
+
+	TemplateFile string `yaml:"template_file" env:"WEBHOOK_TEMPLATE_FILE"`
+	MinSeverity  string `yaml:"min_severity" env:"WEBHOOK_MIN_SEVERITY"`
+}
+
+// Twilio holds the Twilio SMS notifier settings
+type Twilio struct {
+	AccountSID string `yaml:"account_sid" env:"TWILIO_ACCOUNT_SID"`
+	AuthToken  string `yaml:"auth_token" env:"TWILIO_AUTH_TOKEN"`
+	From       string `yaml:"from" env:"TWILIO_FROM"`
+	To         List   `yaml:"to" env:"TWILIO_TO"`
+}
+
+// Pushover holds the Pushover notifier settings
+type Pushover struct {
+	Token string `yaml:"token" env:"PUSHOVER_TOKEN"`
+	User  string `yaml:"user" env:"PUSHOVER_USER"`
+
+	MinSeverity string `yaml:"min_severity" env:"PUSHOVER_MIN_SEVERITY"`
+}
+
+// Processor holds the issue creation settings
+type Processor struct {
+	Concurrency           string `yaml:"concurrency" env:"PROCESS_CONCURRENCY"`
+	MinSeverity           string `yaml:"min_severity" env:"MIN_SEVERITY"`
+	SeverityKeywords      string `yaml:"severity_keywords" env:"SEVERITY_KEYWORDS"`
+	CreateClosed          string `yaml:"create_closed" env:"CREATE_CLOSED"`
+	BugIDFields           List   `yaml:"bugid_fields" env:"BUGID_FIELDS"`
+	BugIDLabelColorMode   string `yaml:"bugid_label_color_mode" env:"BUGID_LABEL_COLOR_MODE"`
+	DedupByTrace          string `yaml:"dedup_by_trace" env:"DEDUP_BY_TRACE"`
+	DedupTTL              string `yaml:"dedup_ttl" env:"DEDUP_TTL"`
+	LatencyThresholdMs    string `yaml:"latency_threshold_ms" env:"LATENCY_THRESHOLD_MS"`
+	IgnoreEndpoints       List   `yaml:"ignore_endpoints" env:"IGNORE_ENDPOINTS"`
+	IgnoreMessagePatterns List   `yaml:"ignore_message_patterns" env:"IGNORE_MESSAGE_PATTERNS"`
+	DefaultLabels         List   `yaml:"default_labels" env:"DEFAULT_LABELS"`
+	DefaultEnv            string `yaml:"default_env" env:"DEFAULT_ENV"`
+	LabelFromFields       List   `yaml:"label_from_fields" env:"LABEL_FROM_FIELDS"`
+	PriorityLabels        string `yaml:"priority_labels" env:"PRIORITY_LABELS"`
+	RepoRoutes            string `yaml:"repo_routes" env:"REPO_ROUTES"`
+	UnroutedLabel         string `yaml:"unrouted_label" env:"UNROUTED_LABEL"`
+	CommentMode           string `yaml:"comment_mode" env:"COMMENT_MODE"`
+	CommentOnOpen         string `yaml:"comment_on_open" env:"COMMENT_ON_OPEN"`
+	ReopenMode            string `yaml:"reopen_mode" env:"REOPEN_MODE"`
+	StormThreshold        string `yaml:"storm_threshold" env:"STORM_THRESHOLD"`
+	StormWindow           string `yaml:"storm_window" env:"STORM_WINDOW"`
+	MaxBodyBytes          string `yaml:"max_body_bytes" env:"MAX_BODY_BYTES"`
+	MaxTitleLength        string `yaml:"max_title_length" env:"MAX_TITLE_LENGTH"`
+	StacktraceLines       string `yaml:"stacktrace_lines" env:"STACKTRACE_LINES"`
+	ContextLines          string `yaml:"context_lines" env:"CONTEXT_LINES"`
+	BodySections          string `yaml:"body_sections" env:"BODY_SECTIONS"`
+	RelatedIssues         string `yaml:"related_issues" env:"RELATED_ISSUES"`
+	UserMapFile           string `yaml:"user_map_file" env:"USER_MAP_FILE"`
+	TraceURLTemplate      string `yaml:"trace_url_template" env:"GRAFANA_TRACE_URL_TEMPLATE" expand:"true"`
+	LogsURLTemplate       string `yaml:"logs_url_template" env:"GRAFANA_LOGS_URL_TEMPLATE" expand:"true"`
+	ErrorRateWindow       string `yaml:"error_rate_window" env:"ERROR_RATE_WINDOW"`
+	ResolveAfter          string `yaml:"resolve_after" env:"RESOLVE_AFTER"`
+	ResolveClose          string `yaml:"resolve_close" env:"RESOLVE_CLOSE"`
+	DeescalateAfter       string `yaml:"deescalate_after" env:"DEESCALATE_AFTER"`
+	CloseCommentTemplate  string `yaml:"close_comment_template" env:"CLOSE_COMMENT_TEMPLATE" expand:"true"`
+	CacheDB               string `yaml:"cache_db" env:"CACHE_DB"`
+	QueueDir              string `yaml:"queue_dir" env:"QUEUE_DIR"`
+
+	OccurrenceSampleRate      string `yaml:"occurrence_sample_rate" env:"OCCURRENCE_SAMPLE_RATE"`
+	OccurrenceSampleThreshold string `yaml:"occurrence_sample_threshold" env:"OCCURRENCE_SAMPLE_THRESHOLD"`
+}
+
+// MetricAlert is a LogQL metric query that raises an issue when any of its
+// values exceeds the threshold
+type MetricAlert struct {
+	Name      string `yaml:"name"`
+	Query     string `yaml:"query" expand:"true"`
+	Threshold string `yaml:"threshold"`
+	Severity  string `yaml:"severity"`
+}
+
+// List is a list setting, given as a YAML sequence or a comma-separated string
+type List []string
+
+// UnmarshalYAML accepts both a sequence and a comma-separated scalar
+func (l *List) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*l = SplitList(node.Value)
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// SplitList splits a comma-separated value, trimming whitespace and dropping empty items
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Load reads the config file at path (if not empty), applies environment
+// overrides and validates the result
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config: %w", err)
+		}
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+		}
+	}
+
+	applyEnv(reflect.ValueOf(cfg).Elem(), "")
+
+	if err := expandFields(reflect.ValueOf(cfg).Elem(), ""); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv overrides the fields of v with the environment variables named by
+// their env tags. Tags on nested structs are prefixes for their fields.
+func applyEnv(v reflect.Value, prefix string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		name := prefix + t.Field(i).Tag.Get("env")
+
+		if field.Kind() == reflect.Struct {
+			applyEnv(field, name)
+			continue
+		}
+
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(value)
+		case List:
+			field.Set(reflect.ValueOf(List(SplitList(value))))
+		}
+	}
+}
+
+// expandFields expands environment variables in the string fields of v
+// tagged expand:"true". Errors name the field by its env tag, or by its
+// YAML path for fields without one (e.g. metric_alerts[0].query).
+func expandFields(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := v.Field(i)
+		tag := t.Field(i).Tag
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			if err := expandFields(field, prefix+tag.Get("env")); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Struct:
+			for j := 0; j < field.Len(); j++ {
+				if err := expandFields(field.Index(j), fmt.Sprintf("%s[%d].", tag.Get("yaml"), j)); err != nil {
+					return err
+				}
+			}
+		case field.Kind() == reflect.String && tag.Get("expand") == "true":
+			name := prefix + tag.Get("env")
+			if tag.Get("env") == "" {
+				name = prefix + tag.Get("yaml")
+			}
+			value, err := Expand(field.String())
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			field.SetString(value)
+		}
+	}
+	return nil
+}
+
+// expandPattern matches the references Expand replaces: $$ and ${VAR}
+var expandPattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Expand replaces ${VAR} with the value of the environment variable VAR, so
+// one config can be shared between e.g. clusters. A literal dollar sign can
+// be written as $$; any other $ is kept, so template variables such as
+// {{range $k, $v := .Fields}} need no escaping. Referencing an unset
+// variable is an error.
+func Expand(s string) (string, error) {
+	var unset []string
+	expanded := expandPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		name := ref[2 : len(ref)-1]
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			unset = append(unset, name)
+		}
+		return value
+	})
+	if len(unset) > 0 {
+		return "", fmt.Errorf("environment variable %s is not set (write $$ for a literal $)", strings.Join(unset, ", "))
+	}
+	return expanded, nil
+}
+
+// Validate checks that the settings required for the issue backend are set
+func (c *Config) Validate() error {
+	if c.GitLab.URL != "" {
+		if c.GitLab.Token == "" {
+			return errors.New("GITLAB_TOKEN (gitlab.token) is required")
+		}
+		if c.GitLab.Project == "" {
+			return errors.New("GITLAB_PROJECT (gitlab.project) is required")
+		}
+		return nil
+	}
+
+	if c.Gitea.URL == "" {
+		return errors.New("GITEA_URL (gitea.url) is required")
+	}
+	if c.Gitea.Token == "" {
+		return errors.New("GITEA_TOKEN (gitea.token) is required")
+	}
+	if c.Gitea.Owner == "" {
+		return errors.New("GITEA_OWNER (gitea.owner) is required")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	t.Setenv("VIGIL_TEST_CLUSTER", "eu-1")
+	t.Setenv("VIGIL_TEST_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		in      string
+		want    string
+		wantErr string
+	}{
+		{name: "no references", in: `{app="api"} |= "error"`, want: `{app="api"} |= "error"`},
+		{name: "braced variable", in: `cluster="${VIGIL_TEST_CLUSTER}"`, want: `cluster="eu-1"`},
+		{name: "variables in a row", in: "${VIGIL_TEST_CLUSTER}${VIGIL_TEST_CLUSTER}", want: "eu-1eu-1"},
+		{name: "empty variable", in: "x${VIGIL_TEST_EMPTY}y", want: "xy"},
+		{name: "escaped dollar", in: "costs $$5", want: "costs $5"},
+		{name: "escaped reference", in: "$${VIGIL_TEST_CLUSTER}", want: "${VIGIL_TEST_CLUSTER}"},
+		{name: "bare variable kept", in: "$VIGIL_TEST_CLUSTER", want: "$VIGIL_TEST_CLUSTER"},
+		{name: "lone dollar kept", in: "ends with $", want: "ends with $"},
+		{name: "invalid name kept", in: "${not a name}", want: "${not a name}"},
+		{
+			name: "template variables kept",
+			in:   "{{range $k, $v := .Fields}}{{$k}}={{$v}} {{end}}${VIGIL_TEST_CLUSTER}",
+			want: "{{range $k, $v := .Fields}}{{$k}}={{$v}} {{end}}eu-1",
+		},
+		{name: "unset variable", in: "${VIGIL_TEST_UNSET}", wantErr: "environment variable VIGIL_TEST_UNSET is not set"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.in)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expand(%q) error = %v, want %q", tt.in, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadExpandsTemplates(t *testing.T) {
+	t.Setenv("VIGIL_TEST_GRAFANA", "https://grafana.example.com")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	config := `
+gitea:
+  url: http://gitea:3000
+  token: secret
+  owner: team
+processor:
+  close_comment_template: "Closed after {{.QuietFor}}.{{range $k, $v := .Fields}} {{$k}}={{$v}}{{end}}"
+  trace_url_template: "${VIGIL_TEST_GRAFANA}/explore?traceId={{.TraceID}}"
+`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if want := "Closed after {{.QuietFor}}.{{range $k, $v := .Fields}} {{$k}}={{$v}}{{end}}"; cfg.Processor.CloseCommentTemplate != want {
+		t.Errorf("CloseCommentTemplate = %q, want %q", cfg.Processor.CloseCommentTemplate, want)
+	}
+	if want := "https://grafana.example.com/explore?traceId={{.TraceID}}"; cfg.Processor.TraceURLTemplate != want {
+		t.Errorf("TraceURLTemplate = %q, want %q", cfg.Processor.TraceURLTemplate, want)
+	}
+}
@@ -2,13 +2,19 @@ package gitea
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"vigil/transport"
 )
 
 // Client is a Gitea API client
@@ -18,32 +24,138 @@ type Client struct {
 	owner      string
 	repo       string
 	httpClient *http.Client
+
+	enforceLabelColors bool
+	orgLabels          bool
+	labels             *ensuredLabels
+}
+
+// ensuredLabels remembers the labels EnsureLabel has already checked, so
+// repeated calls don't list the repository's labels again
+type ensuredLabels struct {
+	mu     sync.Mutex
+	colors map[string]string // label name -> color
+}
+
+func newEnsuredLabels() *ensuredLabels {
+	return &ensuredLabels{colors: make(map[string]string)}
+}
+
+// has reports whether a label was ensured with the given color
+func (l *ensuredLabels) has(name, color string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ensured, ok := l.colors[name]
+	return ok && ensured == color
+}
+
+func (l *ensuredLabels) add(name, color string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.colors[name] = color
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets the HTTP request timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS connections
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Client) {
+		base := transport.Base()
+		base.TLSClientConfig = tlsConfig
+		c.httpClient.Transport = transport.Wrap(base)
+	}
+}
+
+// WithEnforceLabelColors makes EnsureLabel recolor existing labels whose
+// color differs from the requested one, e.g. after being recolored by hand
+func WithEnforceLabelColors() Option {
+	return func(c *Client) {
+		c.enforceLabelColors = true
+	}
+}
+
+// WithOrgLabels makes EnsureLabel create missing labels at the level of the
+// organization owning the repository, so they are shared by all of its
+// repositories. Labels are then looked up among the organization's labels
+// as well as the repository's.
+func WithOrgLabels() Option {
+	return func(c *Client) {
+		c.orgLabels = true
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for API requests, e.g. to
+// point the client at an httptest.Server. Options after it modify the
+// given client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the RoundTripper used for API requests, e.g. a mock
+// transport in tests
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = transport.Wrap(rt)
+	}
 }
 
 // NewClient creates a new Gitea client
-func NewClient(baseURL, token, owner, repo string) *Client {
-	return &Client{
+func NewClient(baseURL, token, owner, repo string, opts ...Option) *Client {
+	c := &Client{
 		baseURL: baseURL,
 		token:   token,
 		owner:   owner,
 		repo:    repo,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: transport.Wrap(nil),
 		},
+		labels: newEnsuredLabels(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+// ForRepo returns a client for another repository on the same server,
+// sharing this client's credentials and HTTP client
+func (c *Client) ForRepo(owner, repo string) *Client {
+	clone := *c
+	clone.owner = owner
+	clone.repo = repo
+	clone.labels = newEnsuredLabels()
+	return &clone
+}
+
+// Repo returns the "owner/repo" name of the client's repository
+func (c *Client) Repo() string {
+	return c.owner + "/" + c.repo
 }
 
 // Issue represents a Gitea issue
 type Issue struct {
-	ID        int64     `json:"id"`
-	Number    int64     `json:"number"`
-	Title     string    `json:"title"`
-	Body      string    `json:"body"`
-	State     string    `json:"state"`
-	Labels    []Label   `json:"labels"`
-	Comments  int       `json:"comments"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        int64      `json:"id"`
+	Number    int64      `json:"number"`
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	State     string     `json:"state"`
+	HTMLURL   string     `json:"html_url"`
+	Labels    []Label    `json:"labels"`
+	Comments  int        `json:"comments"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ClosedAt  *time.Time `json:"closed_at"`
 }
 
 // Label represents a Gitea label
@@ -55,8 +167,23 @@ type Label struct {
 
 // CreateIssueRequest is the request body for creating an issue
 type CreateIssueRequest struct {
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	Milestone int64  `json:"milestone,omitempty"`
+}
+
+// Comment represents a Gitea issue comment
+type Comment struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Milestone represents a Gitea milestone
+type Milestone struct {
+	ID    int64  `json:"id"`
 	Title string `json:"title"`
-	Body  string `json:"body"`
+	State string `json:"state"`
 }
 
 // IssueLabelsRequest is the request body for adding labels to an issue
@@ -69,9 +196,12 @@ type CreateCommentRequest struct {
 	Body string `json:"body"`
 }
 
-// UpdateIssueRequest is the request body for updating an issue
+// UpdateIssueRequest is the request body for updating an issue.
+// Nil fields are left unchanged; a pointer to "" clears the field.
 type UpdateIssueRequest struct {
-	State string `json:"state,omitempty"`
+	Title *string `json:"title,omitempty"`
+	Body  *string `json:"body,omitempty"`
+	State *string `json:"state,omitempty"`
 }
 
 // CreateLabelRequest is the request body for creating a label
@@ -80,6 +210,15 @@ type CreateLabelRequest struct {
 	Color string `json:"color"`
 }
 
+// EditLabelRequest is the request body for updating a label
+type EditLabelRequest struct {
+	Color string `json:"color"`
+}
+
+// errLabelExists is returned by createLabel when the label already exists.
+// Depending on the version, Gitea reports this as 409 or 422.
+var errLabelExists = errors.New("label already exists")
+
 // SearchIssues searches for issues by label
 func (c *Client) SearchIssues(labelName string) ([]Issue, error) {
 	params := url.Values{}
@@ -115,11 +254,12 @@ func (c *Client) SearchIssues(labelName string) ([]Issue, error) {
 
 // CreateIssue creates a new issue and optionally adds labels
 func (c *Client) CreateIssue(title, body string, labelNames []string) (*Issue, error) {
-	reqBody := CreateIssueRequest{
-		Title: title,
-		Body:  body,
-	}
+	return c.CreateIssueFromRequest(CreateIssueRequest{Title: title, Body: body}, labelNames)
+}
 
+// CreateIssueFromRequest creates a new issue with full control over the
+// request (e.g. milestone) and optionally adds labels
+func (c *Client) CreateIssueFromRequest(reqBody CreateIssueRequest, labelNames []string) (*Issue, error) {
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, err
@@ -164,7 +304,7 @@ func (c *Client) CreateIssue(title, body string, labelNames []string) (*Issue, e
 // AddLabelsByName adds labels to an issue by label names
 func (c *Client) AddLabelsByName(issueNumber int64, labelNames []string) error {
 	// Get all labels to find IDs
-	labels, err := c.GetLabels()
+	labels, err := c.ListLabels()
 	if err != nil {
 		return err
 	}
@@ -213,59 +353,279 @@ func (c *Client) AddLabelsByName(issueNumber int64, labelNames []string) error {
 	return nil
 }
 
-// GetLabels returns all labels in the repository
-func (c *Client) GetLabels() ([]Label, error) {
-	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/labels", c.baseURL, c.owner, c.repo)
-	req, err := http.NewRequest("GET", reqURL, nil)
+// AddLabels adds labels to an existing issue by label names
+func (c *Client) AddLabels(issueNumber int64, labels []string) error {
+	if err := c.AddLabelsByName(issueNumber, labels); err != nil {
+		return fmt.Errorf("failed to add labels to issue #%d: %w", issueNumber, err)
+	}
+	return nil
+}
+
+// RemoveLabel removes a label from an issue by label name
+func (c *Client) RemoveLabel(issueNumber int64, label string) error {
+	labelID, err := c.labelID(label)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/issues/%d/labels/%d", c.baseURL, c.owner, c.repo, issueNumber, labelID)
+	req, err := http.NewRequest("DELETE", reqURL, nil)
+	if err != nil {
+		return err
 	}
 	c.setAuth(req)
 
 	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to remove label: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// labelID looks up the ID of a repository label by name
+func (c *Client) labelID(name string) (int64, error) {
+	labels, err := c.ListLabels()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, label := range labels {
+		if label.Name == name {
+			return label.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("label %q not found", name)
+}
+
+// labelsPageSize is the number of labels requested per page. Gitea may
+// cap it lower (MAX_RESPONSE_ITEMS), so pages are read until one is empty.
+const labelsPageSize = 50
+
+// ListLabels returns all labels usable in the repository, following
+// pagination. With org labels, the organization's labels come first.
+func (c *Client) ListLabels() ([]Label, error) {
+	var labels []Label
+	if c.orgLabels {
+		orgLabels, err := c.ListOrgLabels()
+		if err != nil {
+			return nil, err
+		}
+		labels = orgLabels
+	}
+
+	repoLabels, err := c.listLabels(c.repoLabelsURL())
 	if err != nil {
 		return nil, err
 	}
+	return append(labels, repoLabels...), nil
+}
+
+// ListOrgLabels returns all labels of the organization owning the
+// repository, following pagination
+func (c *Client) ListOrgLabels() ([]Label, error) {
+	return c.listLabels(c.orgLabelsURL())
+}
+
+// repoLabelsURL is the API URL of the repository's labels
+func (c *Client) repoLabelsURL() string {
+	return fmt.Sprintf("%s/api/v1/repos/%s/%s/labels", c.baseURL, c.owner, c.repo)
+}
+
+// orgLabelsURL is the API URL of the owning organization's labels
+func (c *Client) orgLabelsURL() string {
+	return fmt.Sprintf("%s/api/v1/orgs/%s/labels", c.baseURL, c.owner)
+}
+
+// listLabels returns all labels at a labels API URL, following pagination
+func (c *Client) listLabels(labelsURL string) ([]Label, error) {
+	var labels []Label
+	for page := 1; ; page++ {
+		batch, total, err := c.listLabelsPage(labelsURL, page)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, batch...)
+
+		// X-Total-Count saves the request for the empty last page
+		if len(batch) == 0 || (total > 0 && len(labels) >= total) {
+			return labels, nil
+		}
+	}
+}
+
+// listLabelsPage returns one page of labels and the total number of labels
+// reported by Gitea (0 if not reported)
+func (c *Client) listLabelsPage(labelsURL string, page int) ([]Label, int, error) {
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(labelsPageSize))
+
+	reqURL := labelsURL + "?" + params.Encode()
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list labels: %w", err)
+	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to get labels: %s", string(body))
+		return nil, 0, fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
 	}
 
 	var labels []Label
 	if err := json.NewDecoder(resp.Body).Decode(&labels); err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("failed to decode labels: %w", err)
 	}
 
-	return labels, nil
+	total, _ := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	return labels, total, nil
 }
 
 // AddComment adds a comment to an issue
 func (c *Client) AddComment(issueNumber int64, body string) error {
+	_, err := c.CreateComment(issueNumber, body)
+	return err
+}
+
+// CreateComment adds a comment to an issue and returns it
+func (c *Client) CreateComment(issueNumber int64, body string) (*Comment, error) {
 	reqBody := CreateCommentRequest{Body: body}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/issues/%d/comments", c.baseURL, c.owner, c.repo, issueNumber)
 
 	req, err := http.NewRequest("POST", reqURL, bytes.NewReader(jsonBody))
 	if err != nil {
-		return err
+		return nil, err
 	}
 	c.setAuth(req)
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to add comment: %w", err)
+		return nil, fmt.Errorf("failed to add comment: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var comment Comment
+	if err := json.NewDecoder(resp.Body).Decode(&comment); err != nil {
+		return nil, fmt.Errorf("failed to decode comment: %w", err)
+	}
+
+	return &comment, nil
+}
+
+// commentsPageSize is the number of comments requested per page
+const commentsPageSize = 50
+
+// ListComments returns the comments on an issue, oldest first, following
+// pagination
+func (c *Client) ListComments(issueNumber int64) ([]Comment, error) {
+	var comments []Comment
+	seen := make(map[int64]bool)
+	for page := 1; ; page++ {
+		batch, total, err := c.listCommentsPage(issueNumber, page)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, comment := range batch {
+			// Gitea versions without comment pagination return every
+			// comment on each page
+			if !seen[comment.ID] {
+				seen[comment.ID] = true
+				comments = append(comments, comment)
+				added++
+			}
+		}
+
+		if added == 0 || (total > 0 && len(comments) >= total) {
+			return comments, nil
+		}
+	}
+}
+
+// listCommentsPage returns one page of an issue's comments and the total
+// number of comments reported by Gitea (0 if not reported)
+func (c *Client) listCommentsPage(issueNumber int64, page int) ([]Comment, int, error) {
+	params := url.Values{}
+	params.Set("page", strconv.Itoa(page))
+	params.Set("limit", strconv.Itoa(commentsPageSize))
+
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/issues/%d/comments?%s", c.baseURL, c.owner, c.repo, issueNumber, params.Encode())
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list comments: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, 0, fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var comments []Comment
+	if err := json.NewDecoder(resp.Body).Decode(&comments); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode comments: %w", err)
+	}
+
+	total, _ := strconv.Atoi(resp.Header.Get("X-Total-Count"))
+	return comments, total, nil
+}
+
+// UpdateComment replaces the body of a comment
+func (c *Client) UpdateComment(commentID int64, body string) error {
+	jsonBody, err := json.Marshal(CreateCommentRequest{Body: body})
+	if err != nil {
+		return err
+	}
+
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/issues/comments/%d", c.baseURL, c.owner, c.repo, commentID)
+
+	req, err := http.NewRequest("PATCH", reqURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
 	}
@@ -275,9 +635,35 @@ func (c *Client) AddComment(issueNumber int64, body string) error {
 
 // ReopenIssue reopens a closed issue
 func (c *Client) ReopenIssue(issueNumber int64) error {
-	reqBody := UpdateIssueRequest{State: "open"}
+	state := "open"
+	if err := c.UpdateIssue(issueNumber, UpdateIssueRequest{State: &state}); err != nil {
+		return fmt.Errorf("failed to reopen issue: %w", err)
+	}
+	return nil
+}
 
-	jsonBody, err := json.Marshal(reqBody)
+// CloseIssue closes an open issue
+func (c *Client) CloseIssue(issueNumber int64) error {
+	state := "closed"
+	if err := c.UpdateIssue(issueNumber, UpdateIssueRequest{State: &state}); err != nil {
+		return fmt.Errorf("failed to close issue: %w", err)
+	}
+	return nil
+}
+
+// UpdateIssueBody replaces the body of an issue
+func (c *Client) UpdateIssueBody(issueNumber int64, body string) error {
+	return c.UpdateIssue(issueNumber, UpdateIssueRequest{Body: &body})
+}
+
+// UpdateIssueTitle replaces the title of an issue
+func (c *Client) UpdateIssueTitle(issueNumber int64, title string) error {
+	return c.UpdateIssue(issueNumber, UpdateIssueRequest{Title: &title})
+}
+
+// UpdateIssue applies a partial update to an issue
+func (c *Client) UpdateIssue(issueNumber int64, update UpdateIssueRequest) error {
+	jsonBody, err := json.Marshal(update)
 	if err != nil {
 		return err
 	}
@@ -293,7 +679,7 @@ func (c *Client) ReopenIssue(issueNumber int64) error {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to reopen issue: %w", err)
+		return fmt.Errorf("failed to update issue: %w", err)
 	}
 	defer resp.Body.Close()
 
@@ -305,18 +691,102 @@ func (c *Client) ReopenIssue(issueNumber int64) error {
 	return nil
 }
 
-// EnsureLabel ensures a label exists, creating it if necessary
+// EnsureLabel ensures a label exists, creating it if it isn't listed in the
+// repository (or, with org labels, in the organization, where missing labels
+// are then created). Labels already ensured by this client are not checked
+// again.
 func (c *Client) EnsureLabel(name, color string) error {
-	// Just try to create - Gitea returns 409 if it already exists
-	err := c.createLabel(name, color)
-	if err != nil && !strings.Contains(err.Error(), "409") {
+	color = normalizeColor(color)
+	if c.labels.has(name, color) {
+		return nil
+	}
+
+	labelsURL := c.repoLabelsURL()
+	if c.orgLabels {
+		found, err := c.findLabel(c.orgLabelsURL(), name, color)
+		if err != nil || found {
+			return err
+		}
+		// A label created in the repository before org labels were enabled
+		// is used rather than shadowed
+		if found, err := c.findLabel(c.repoLabelsURL(), name, color); err != nil || found {
+			return err
+		}
+		labelsURL = c.orgLabelsURL()
+	} else if found, err := c.findLabel(labelsURL, name, color); err != nil || found {
+		return err
+	}
+
+	// Another client may have created the label since it was listed
+	if err := c.createLabel(labelsURL, name, color); err != nil {
+		if errors.Is(err, errLabelExists) {
+			return nil
+		}
 		return err
 	}
+	c.labels.add(name, color)
 	return nil
 }
 
-// createLabel creates a new label
-func (c *Client) createLabel(name, color string) error {
+// findLabel reports whether a label is listed at a labels API URL, recoloring
+// it if label colors are enforced, and remembers it as ensured
+func (c *Client) findLabel(labelsURL, name, color string) (bool, error) {
+	labels, err := c.listLabels(labelsURL)
+	if err != nil {
+		return false, err
+	}
+
+	for _, label := range labels {
+		if label.Name != name {
+			continue
+		}
+		if c.enforceLabelColors && normalizeColor(label.Color) != color {
+			if err := c.updateLabelColor(labelsURL, label.ID, color); err != nil {
+				return false, err
+			}
+		}
+		c.labels.add(name, color)
+		return true, nil
+	}
+	return false, nil
+}
+
+// normalizeColor returns a color in the lowercase form Gitea returns, without '#'
+func normalizeColor(color string) string {
+	return strings.ToLower(strings.TrimPrefix(color, "#"))
+}
+
+// updateLabelColor changes the color of a label listed at a labels API URL
+func (c *Client) updateLabelColor(labelsURL string, labelID int64, color string) error {
+	jsonBody, err := json.Marshal(EditLabelRequest{Color: color})
+	if err != nil {
+		return err
+	}
+
+	reqURL := fmt.Sprintf("%s/%d", labelsURL, labelID)
+	req, err := http.NewRequest("PATCH", reqURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+	c.setAuth(req)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update label: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
+
+// createLabel creates a new label at a labels API URL
+func (c *Client) createLabel(labelsURL, name, color string) error {
 	reqBody := CreateLabelRequest{
 		Name:  name,
 		Color: color,
@@ -327,9 +797,7 @@ func (c *Client) createLabel(name, color string) error {
 		return err
 	}
 
-	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/labels", c.baseURL, c.owner, c.repo)
-
-	req, err := http.NewRequest("POST", reqURL, bytes.NewReader(jsonBody))
+	req, err := http.NewRequest("POST", labelsURL, bytes.NewReader(jsonBody))
 	if err != nil {
 		return err
 	}
@@ -342,6 +810,9 @@ func (c *Client) createLabel(name, color string) error {
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusUnprocessableEntity {
+		return errLabelExists
+	}
 	if resp.StatusCode != http.StatusCreated {
 		body, _ := io.ReadAll(resp.Body)
 		return fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
@@ -350,9 +821,13 @@ func (c *Client) createLabel(name, color string) error {
 	return nil
 }
 
-// GetIssueCommentCount returns the number of comments on an issue
-func (c *Client) GetIssueCommentCount(issueNumber int64) (int, error) {
-	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/issues/%d", c.baseURL, c.owner, c.repo, issueNumber)
+// FindMilestone returns the ID of the milestone with the given title
+func (c *Client) FindMilestone(title string) (int64, error) {
+	params := url.Values{}
+	params.Set("state", "all")
+	params.Set("name", title)
+
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/milestones?%s", c.baseURL, c.owner, c.repo, params.Encode())
 
 	req, err := http.NewRequest("GET", reqURL, nil)
 	if err != nil {
@@ -362,18 +837,86 @@ func (c *Client) GetIssueCommentCount(issueNumber int64) (int, error) {
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get issue: %w", err)
+		return 0, fmt.Errorf("failed to list milestones: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var milestones []Milestone
+	if err := json.NewDecoder(resp.Body).Decode(&milestones); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	for _, milestone := range milestones {
+		if milestone.Title == title {
+			return milestone.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("milestone %q not found", title)
+}
+
+// GetIssue returns a single issue by number
+func (c *Client) GetIssue(issueNumber int64) (*Issue, error) {
+	reqURL := fmt.Sprintf("%s/api/v1/repos/%s/%s/issues/%d", c.baseURL, c.owner, c.repo, issueNumber)
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get issue: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Gitea returned status %d: %s", resp.StatusCode, string(body))
+	}
+
 	var issue Issue
 	if err := json.NewDecoder(resp.Body).Decode(&issue); err != nil {
-		return 0, fmt.Errorf("failed to decode issue: %w", err)
+		return nil, fmt.Errorf("failed to decode issue: %w", err)
 	}
 
+	return &issue, nil
+}
+
+// GetIssueCommentCount returns the number of comments on an issue
+func (c *Client) GetIssueCommentCount(issueNumber int64) (int, error) {
+	issue, err := c.GetIssue(issueNumber)
+	if err != nil {
+		return 0, err
+	}
 	return issue.Comments, nil
 }
 
+// HasLabel reports whether the issue carries a label with the given name
+func (i *Issue) HasLabel(name string) bool {
+	for _, label := range i.Labels {
+		if label.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// LabelNames returns the names of the labels on the issue
+func (i *Issue) LabelNames() []string {
+	names := make([]string, 0, len(i.Labels))
+	for _, label := range i.Labels {
+		names = append(names, label.Name)
+	}
+	return names
+}
+
 // setAuth sets the authorization header
 func (c *Client) setAuth(req *http.Request) {
 	req.Header.Set("Authorization", "token "+c.token)
@@ -0,0 +1,399 @@
+package gitlab
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"vigil/gitea"
+	"vigil/transport"
+)
+
+// Client is a GitLab Issues API client. It maps GitLab issues and notes onto
+// the gitea types so the processor can treat both backends the same way.
+type Client struct {
+	baseURL    string
+	token      string
+	project    string
+	httpClient *http.Client
+
+	mu         sync.Mutex
+	noteIssues map[int64]int64 // note ID -> issue IID, needed to edit notes
+	labels     map[string]bool // labels EnsureLabel has already created or found
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets the HTTP request timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS connections
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Client) {
+		base := transport.Base()
+		base.TLSClientConfig = tlsConfig
+		c.httpClient.Transport = transport.Wrap(base)
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for API requests. Options
+// after it modify the given client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the RoundTripper used for API requests
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = transport.Wrap(rt)
+	}
+}
+
+// NewClient creates a new GitLab client for a project, given either its
+// numeric ID or its "group/project" path
+func NewClient(baseURL, token, project string, opts ...Option) *Client {
+	c := &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		project: project,
+		httpClient: &http.Client{
+			Timeout:   30 * time.Second,
+			Transport: transport.Wrap(nil),
+		},
+		noteIssues: make(map[int64]int64),
+		labels:     make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Repo returns the project the client files issues in
+func (c *Client) Repo() string {
+	return c.project
+}
+
+// issue represents a GitLab issue
+type issue struct {
+	ID          int64      `json:"id"`
+	IID         int64      `json:"iid"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	State       string     `json:"state"`
+	WebURL      string     `json:"web_url"`
+	Labels      []string   `json:"labels"`
+	Notes       int        `json:"user_notes_count"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	ClosedAt    *time.Time `json:"closed_at"`
+}
+
+// toGitea converts a GitLab issue to its gitea equivalent
+func (i issue) toGitea() gitea.Issue {
+	state := i.State
+	if state == "opened" {
+		state = "open"
+	}
+
+	labels := make([]gitea.Label, len(i.Labels))
+	for n, name := range i.Labels {
+		labels[n] = gitea.Label{Name: name}
+	}
+
+	return gitea.Issue{
+		ID:        i.ID,
+		Number:    i.IID,
+		Title:     i.Title,
+		Body:      i.Description,
+		State:     state,
+		HTMLURL:   i.WebURL,
+		Labels:    labels,
+		Comments:  i.Notes,
+		CreatedAt: i.CreatedAt,
+		UpdatedAt: i.UpdatedAt,
+		ClosedAt:  i.ClosedAt,
+	}
+}
+
+// note represents a GitLab issue note (comment)
+type note struct {
+	ID        int64     `json:"id"`
+	Body      string    `json:"body"`
+	System    bool      `json:"system"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SearchIssues finds issues (open or closed) carrying a label
+func (c *Client) SearchIssues(labelName string) ([]gitea.Issue, error) {
+	params := url.Values{}
+	params.Set("labels", labelName)
+	params.Set("state", "all") // Include closed issues for deduplication
+
+	var issues []issue
+	if err := c.do("GET", "/issues?"+params.Encode(), nil, http.StatusOK, &issues); err != nil {
+		return nil, fmt.Errorf("failed to search issues: %w", err)
+	}
+
+	result := make([]gitea.Issue, len(issues))
+	for n, i := range issues {
+		result[n] = i.toGitea()
+	}
+	return result, nil
+}
+
+// GetIssue returns a single issue by IID
+func (c *Client) GetIssue(issueNumber int64) (*gitea.Issue, error) {
+	var i issue
+	if err := c.do("GET", fmt.Sprintf("/issues/%d", issueNumber), nil, http.StatusOK, &i); err != nil {
+		return nil, fmt.Errorf("failed to get issue: %w", err)
+	}
+	result := i.toGitea()
+	return &result, nil
+}
+
+// CreateIssueFromRequest creates a new issue with the given labels. The
+// milestone, if set, must be a GitLab milestone ID.
+func (c *Client) CreateIssueFromRequest(reqBody gitea.CreateIssueRequest, labelNames []string) (*gitea.Issue, error) {
+	payload := map[string]interface{}{
+		"title":       reqBody.Title,
+		"description": reqBody.Body,
+	}
+	if len(labelNames) > 0 {
+		payload["labels"] = strings.Join(labelNames, ",")
+	}
+	if reqBody.Milestone > 0 {
+		payload["milestone_id"] = reqBody.Milestone
+	}
+
+	var i issue
+	if err := c.do("POST", "/issues", payload, http.StatusCreated, &i); err != nil {
+		return nil, fmt.Errorf("failed to create issue: %w", err)
+	}
+	result := i.toGitea()
+	return &result, nil
+}
+
+// UpdateIssueBody replaces the description of an issue
+func (c *Client) UpdateIssueBody(issueNumber int64, body string) error {
+	return c.updateIssue(issueNumber, map[string]interface{}{"description": body})
+}
+
+// ReopenIssue reopens a closed issue
+func (c *Client) ReopenIssue(issueNumber int64) error {
+	if err := c.updateIssue(issueNumber, map[string]interface{}{"state_event": "reopen"}); err != nil {
+		return fmt.Errorf("failed to reopen issue: %w", err)
+	}
+	return nil
+}
+
+// CloseIssue closes an open issue
+func (c *Client) CloseIssue(issueNumber int64) error {
+	if err := c.updateIssue(issueNumber, map[string]interface{}{"state_event": "close"}); err != nil {
+		return fmt.Errorf("failed to close issue: %w", err)
+	}
+	return nil
+}
+
+// AddLabels adds labels to an existing issue by label names
+func (c *Client) AddLabels(issueNumber int64, labels []string) error {
+	if err := c.updateIssue(issueNumber, map[string]interface{}{"add_labels": strings.Join(labels, ",")}); err != nil {
+		return fmt.Errorf("failed to add labels to issue #%d: %w", issueNumber, err)
+	}
+	return nil
+}
+
+// RemoveLabel removes a label from an issue by label name
+func (c *Client) RemoveLabel(issueNumber int64, label string) error {
+	if err := c.updateIssue(issueNumber, map[string]interface{}{"remove_labels": label}); err != nil {
+		return fmt.Errorf("failed to remove label: %w", err)
+	}
+	return nil
+}
+
+// updateIssue applies a partial update to an issue
+func (c *Client) updateIssue(issueNumber int64, update map[string]interface{}) error {
+	return c.do("PUT", fmt.Sprintf("/issues/%d", issueNumber), update, http.StatusOK, nil)
+}
+
+// AddComment adds a note to an issue
+func (c *Client) AddComment(issueNumber int64, body string) error {
+	_, err := c.CreateComment(issueNumber, body)
+	return err
+}
+
+// CreateComment adds a note to an issue and returns it
+func (c *Client) CreateComment(issueNumber int64, body string) (*gitea.Comment, error) {
+	var n note
+	if err := c.do("POST", fmt.Sprintf("/issues/%d/notes", issueNumber), map[string]string{"body": body}, http.StatusCreated, &n); err != nil {
+		return nil, fmt.Errorf("failed to add comment: %w", err)
+	}
+	c.rememberNote(n.ID, issueNumber)
+	return &gitea.Comment{ID: n.ID, Body: n.Body, CreatedAt: n.CreatedAt}, nil
+}
+
+// notesPageSize is the number of notes requested per page, GitLab's maximum
+const notesPageSize = 100
+
+// ListComments returns the user notes on an issue, oldest first, following
+// pagination. System notes (label changes, state changes) are skipped.
+func (c *Client) ListComments(issueNumber int64) ([]gitea.Comment, error) {
+	var comments []gitea.Comment
+	for page := 1; ; page++ {
+		var notes []note
+		path := fmt.Sprintf("/issues/%d/notes?sort=asc&order_by=created_at&per_page=%d&page=%d", issueNumber, notesPageSize, page)
+		if err := c.do("GET", path, nil, http.StatusOK, &notes); err != nil {
+			return nil, fmt.Errorf("failed to list comments: %w", err)
+		}
+
+		for _, n := range notes {
+			if n.System {
+				continue
+			}
+			c.rememberNote(n.ID, issueNumber)
+			comments = append(comments, gitea.Comment{ID: n.ID, Body: n.Body, CreatedAt: n.CreatedAt})
+		}
+
+		if len(notes) < notesPageSize {
+			return comments, nil
+		}
+	}
+}
+
+// UpdateComment replaces the body of a note. GitLab addresses notes through
+// their issue, so the note must have been created or listed by this client.
+func (c *Client) UpdateComment(commentID int64, body string) error {
+	c.mu.Lock()
+	issueNumber, ok := c.noteIssues[commentID]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("failed to update comment: unknown note %d", commentID)
+	}
+
+	path := fmt.Sprintf("/issues/%d/notes/%d", issueNumber, commentID)
+	if err := c.do("PUT", path, map[string]string{"body": body}, http.StatusOK, nil); err != nil {
+		return fmt.Errorf("failed to update comment: %w", err)
+	}
+	return nil
+}
+
+// rememberNote records which issue a note belongs to
+func (c *Client) rememberNote(noteID, issueNumber int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.noteIssues[noteID] = issueNumber
+}
+
+// EnsureLabel creates a project label if it doesn't exist. Labels already
+// ensured by this client are not requested again.
+func (c *Client) EnsureLabel(name, color string) error {
+	c.mu.Lock()
+	ensured := c.labels[name]
+	c.mu.Unlock()
+	if ensured {
+		return nil
+	}
+
+	payload := map[string]string{"name": name, "color": "#" + strings.TrimPrefix(color, "#")}
+	// Just try to create - GitLab returns 409 if it already exists
+	err := c.do("POST", "/labels", payload, http.StatusCreated, nil)
+	if err != nil && !strings.Contains(err.Error(), "409") {
+		return err
+	}
+
+	c.mu.Lock()
+	c.labels[name] = true
+	c.mu.Unlock()
+	return nil
+}
+
+// TestConnection tests the connection to GitLab
+func (c *Client) TestConnection() error {
+	req, err := http.NewRequest("GET", c.projectURL(""), nil)
+	if err != nil {
+		return err
+	}
+	c.setAuth(req)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to connect to GitLab: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("project %s not found", c.project)
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("invalid GitLab token")
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GitLab returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// do sends a request to a project endpoint, expecting the given status, and
+// decodes the response into out if it is non-nil
+func (c *Client) do(method, path string, body interface{}, want int, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := http.NewRequest(method, c.projectURL(path), reader)
+	if err != nil {
+		return err
+	}
+	c.setAuth(req)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != want {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("GitLab returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return nil
+}
+
+// projectURL builds the API URL of a project endpoint
+func (c *Client) projectURL(path string) string {
+	return fmt.Sprintf("%s/api/v4/projects/%s%s", c.baseURL, url.PathEscape(c.project), path)
+}
+
+// setAuth sets the authorization header
+func (c *Client) setAuth(req *http.Request) {
+	req.Header.Set("PRIVATE-TOKEN", c.token)
+}
@@ -1,37 +1,84 @@
 package loki
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
+
+	"vigil/transport"
 )
 
 // Client is a Loki API client
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	tlsConfig  *tls.Config
+	parser     LineParser
+	maxRetries int
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithTimeout sets the HTTP request timeout
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.httpClient.Timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS and websocket connections
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *Client) {
+		base := transport.Base()
+		base.TLSClientConfig = tlsConfig
+		c.httpClient.Transport = transport.Wrap(base)
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for API requests, e.g. to
+// point the client at an httptest.Server. Options after it modify the
+// given client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithTransport sets the RoundTripper used for API requests, e.g. a mock
+// transport in tests
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = transport.Wrap(rt)
+	}
+}
+
+// WithLineParser sets how log lines returned by Loki are parsed
+func WithLineParser(parser LineParser) Option {
+	return func(c *Client) {
+		c.parser = parser
+	}
 }
 
 // NewClient creates a new Loki client
-func NewClient(baseURL string) *Client {
-	return &Client{
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
 		baseURL: baseURL,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: transport.Wrap(nil),
 		},
 	}
-}
-
-// QueryResponse represents the Loki query response
-type QueryResponse struct {
-	Status string `json:"status"`
-	Data   struct {
-		ResultType string   `json:"resultType"`
-		Result     []Stream `json:"result"`
-	} `json:"data"`
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Stream represents a log stream from Loki
@@ -42,22 +89,28 @@ type Stream struct {
 
 // LogEntry represents a parsed log entry
 type LogEntry struct {
-	Timestamp time.Time
+	Timestamp time.Time // Loki stream timestamp, or the receive time for pushed lines
+	LogTime   time.Time // time from LineParser.TimestampField, zero if unset
 	Raw       string
 	Parsed    map[string]interface{}
+	Labels    map[string]string // labels of the Loki stream, nil for pushed lines
 
 	// Common fields extracted from logs
-	Level      string
-	Message    string
-	Method     string
-	Action     string // endpoint/path
-	Status     int
-	RequestID  string
-	TraceID    string
-	UserID     string
-	BugID      string // explicit bug ID if provided in logs
-	Source     SourceInfo
-	ElapsedMs  float64
+	Level       string
+	Message     string
+	Method      string
+	Action      string // endpoint/path
+	Status      int
+	RequestID   string
+	TraceID     string
+	UserID      string
+	Service     string // name of the service that logged the entry
+	Environment string // deployment environment, e.g. production or staging
+	BugID       string // explicit bug ID if provided in logs
+	Stacktrace  string // stack trace, one frame per line
+	ErrorType   string // exception class or error type, e.g. NullPointerException
+	Source      SourceInfo
+	ElapsedMs   float64
 }
 
 // SourceInfo contains information about the log source
@@ -67,38 +120,95 @@ type SourceInfo struct {
 	Line     int
 }
 
-// QueryRange queries Loki for logs within a time range
-func (c *Client) QueryRange(query string, start, end time.Time, limit int) ([]LogEntry, error) {
+// Query directions, deciding which end of the range is returned when a
+// query hits its limit
+const (
+	DirectionBackward = "backward" // newest lines first (Loki's default)
+	DirectionForward  = "forward"  // oldest lines first
+)
+
+// QueryRange queries Loki for logs within a time range. It also reports
+// whether Loki returned as many lines as the limit, in which case lines in
+// the range may be missing from the result. Metric queries are run with
+// QueryRangeMetric instead.
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, limit int) ([]LogEntry, bool, error) {
+	return c.QueryRangeDirection(ctx, query, start, end, limit, DirectionBackward)
+}
+
+// QueryRangeDirection is QueryRange with the query direction, e.g.
+// DirectionForward for the oldest lines of the range
+func (c *Client) QueryRangeDirection(ctx context.Context, query string, start, end time.Time, limit int, direction string) ([]LogEntry, bool, error) {
 	params := url.Values{}
+	params.Set("limit", fmt.Sprintf("%d", limit))
+	params.Set("direction", direction)
+
+	result, err := c.queryRange(ctx, query, start, end, params)
+	if err != nil {
+		return nil, false, err
+	}
+	if result.ResultType != ResultTypeStreams {
+		return nil, false, fmt.Errorf("query returned a %s result instead of log lines (is it a metric query?)", result.ResultType)
+	}
+
+	lines := 0
+	for _, stream := range result.Streams {
+		lines += len(stream.Values)
+	}
+
+	return parseStreams(result.Streams, c.parser), limit > 0 && lines >= limit, nil
+}
+
+// QueryRangeMetric runs a LogQL metric query (e.g. rate(...[5m])) over a
+// time range, evaluated every step, and returns the resulting series
+func (c *Client) QueryRangeMetric(ctx context.Context, query string, start, end time.Time, step time.Duration) ([]Series, error) {
+	params := url.Values{}
+	params.Set("step", fmt.Sprintf("%gs", step.Seconds()))
+
+	result, err := c.queryRange(ctx, query, start, end, params)
+	if err != nil {
+		return nil, err
+	}
+	if result.ResultType != ResultTypeMatrix {
+		return nil, fmt.Errorf("query returned a %s result instead of a matrix (is it a log query?)", result.ResultType)
+	}
+	return result.Matrix, nil
+}
+
+// queryRange runs a range query with the given extra parameters and decodes
+// the result of any type
+func (c *Client) queryRange(ctx context.Context, query string, start, end time.Time, params url.Values) (*QueryResult, error) {
 	params.Set("query", query)
 	params.Set("start", fmt.Sprintf("%d", start.UnixNano()))
 	params.Set("end", fmt.Sprintf("%d", end.UnixNano()))
-	params.Set("limit", fmt.Sprintf("%d", limit))
 
 	reqURL := fmt.Sprintf("%s/loki/api/v1/query_range?%s", c.baseURL, params.Encode())
 
-	resp, err := c.httpClient.Get(reqURL)
+	resp, err := c.get(ctx, reqURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query Loki: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("Loki returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	var queryResp QueryResponse
-	if err := json.NewDecoder(resp.Body).Decode(&queryResp); err != nil {
-		return nil, fmt.Errorf("failed to decode Loki response: %w", err)
+	result, err := decodeQueryResult(resp.Body)
+	if err != nil {
+		return nil, err
 	}
+	result.parser = c.parser
+	return result, nil
+}
 
-	return parseStreams(queryResp.Data.Result), nil
+// lineKey identifies a log line by its timestamp and content
+type lineKey struct {
+	ts   string
+	line string
 }
 
-// parseStreams converts Loki streams to LogEntry slices
-func parseStreams(streams []Stream) []LogEntry {
+// parseStreams converts Loki streams to LogEntry slices. Lines returned by
+// several overlapping streams (e.g. with high-cardinality labels) are only
+// included once.
+func parseStreams(streams []Stream, parser LineParser) []LogEntry {
 	var entries []LogEntry
+	seen := make(map[lineKey]struct{})
 
 	for _, stream := range streams {
 		for _, value := range stream.Values {
@@ -106,6 +216,12 @@ func parseStreams(streams []Stream) []LogEntry {
 				continue
 			}
 
+			key := lineKey{ts: value[0], line: value[1]}
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+
 			// Parse timestamp (nanoseconds)
 			var ts time.Time
 			var tsNano int64
@@ -113,17 +229,8 @@ func parseStreams(streams []Stream) []LogEntry {
 				ts = time.Unix(0, tsNano)
 			}
 
-			entry := LogEntry{
-				Timestamp: ts,
-				Raw:       value[1],
-				Parsed:    make(map[string]interface{}),
-			}
-
-			// Try to parse JSON log
-			if err := json.Unmarshal([]byte(value[1]), &entry.Parsed); err == nil {
-				extractFields(&entry)
-			}
-
+			entry := parser.Parse(ts, value[1])
+			parser.applyStreamLabels(&entry, stream.Stream)
 			entries = append(entries, entry)
 		}
 	}
@@ -131,6 +238,67 @@ func parseStreams(streams []Stream) []LogEntry {
 	return entries
 }
 
+// LineParser builds log entries from raw log lines
+type LineParser struct {
+	// TimestampField is the dotted path of a timestamp in the log that is
+	// stored as LogEntry.LogTime (e.g. "time"); empty to disable
+	TimestampField string
+	// TimestampFormat is one of the Timestamp* formats
+	TimestampFormat string
+	// ServiceLabel is the stream label used as the service of entries whose
+	// log has no service field (e.g. "app"); empty to disable
+	ServiceLabel string
+}
+
+// ParseLine builds a LogEntry from a single log line, extracting common
+// fields if the line is JSON
+func ParseLine(ts time.Time, line string) LogEntry {
+	return LineParser{}.Parse(ts, line)
+}
+
+// Parse builds a LogEntry from a single log line, extracting common fields
+// if the line is JSON. ts is kept as the entry timestamp; the configured
+// timestamp field only sets LogTime.
+func (p LineParser) Parse(ts time.Time, line string) LogEntry {
+	entry := LogEntry{
+		Timestamp: ts,
+		Raw:       line,
+		Parsed:    make(map[string]interface{}),
+	}
+
+	// Try to parse JSON log
+	if err := json.Unmarshal([]byte(line), &entry.Parsed); err == nil {
+		extractFields(&entry)
+		p.extractTimestamp(&entry)
+	}
+
+	return entry
+}
+
+// applyStreamLabels attaches the labels of the stream an entry was read from
+// and takes its service from ServiceLabel if the log has none
+func (p LineParser) applyStreamLabels(entry *LogEntry, labels map[string]string) {
+	entry.Labels = labels
+	if entry.Service == "" && p.ServiceLabel != "" {
+		entry.Service = labels[p.ServiceLabel]
+	}
+}
+
+// extractTimestamp sets the entry's log time from the configured field. The
+// stream timestamp is left alone as it keys deduplication and resuming.
+func (p LineParser) extractTimestamp(entry *LogEntry) {
+	if p.TimestampField == "" {
+		return
+	}
+	value, ok := LookupPath(entry.Parsed, p.TimestampField)
+	if !ok {
+		return
+	}
+	if ts, ok := parseTimestamp(value, p.TimestampFormat); ok {
+		entry.LogTime = ts
+	}
+}
+
 // extractFields extracts common fields from parsed JSON log
 func extractFields(entry *LogEntry) {
 	if level, ok := entry.Parsed["level"].(string); ok {
@@ -139,14 +307,14 @@ func extractFields(entry *LogEntry) {
 	if msg, ok := entry.Parsed["msg"].(string); ok {
 		entry.Message = msg
 	}
-	if method, ok := entry.Parsed["method"].(string); ok {
+	if method, ok := stringField(entry.Parsed, "method", "request.method"); ok {
 		entry.Method = method
 	}
-	if action, ok := entry.Parsed["action"].(string); ok {
+	if action, ok := stringField(entry.Parsed, "action", "request.path"); ok {
 		entry.Action = action
 	}
-	if status, ok := entry.Parsed["status"].(float64); ok {
-		entry.Status = int(status)
+	if status, ok := intField(entry.Parsed, "status", "response.status", "request.status"); ok {
+		entry.Status = status
 	}
 	if requestID, ok := entry.Parsed["requestId"].(string); ok {
 		entry.RequestID = requestID
@@ -157,12 +325,24 @@ func extractFields(entry *LogEntry) {
 	if userID, ok := entry.Parsed["userid"].(string); ok {
 		entry.UserID = userID
 	}
+	if service, ok := entry.Parsed["service"].(string); ok {
+		entry.Service = service
+	}
+	if env, ok := stringField(entry.Parsed, "env", "environment"); ok {
+		entry.Environment = env
+	}
 	if bugID, ok := entry.Parsed["bugId"].(string); ok {
 		entry.BugID = bugID
 	}
-	if elapsed, ok := entry.Parsed["elapsed_ms"].(float64); ok {
+	if elapsed, ok := floatField(entry.Parsed, "elapsed_ms"); ok {
 		entry.ElapsedMs = elapsed
 	}
+	if stack, ok := stackField(entry.Parsed, StacktraceFields...); ok {
+		entry.Stacktrace = stack
+	}
+	if errType, ok := errorTypeField(entry.Parsed); ok {
+		entry.ErrorType = errType
+	}
 
 	// Extract source info
 	if source, ok := entry.Parsed["source"].(map[string]interface{}); ok {
@@ -172,8 +352,8 @@ func extractFields(entry *LogEntry) {
 		if file, ok := source["file"].(string); ok {
 			entry.Source.File = file
 		}
-		if line, ok := source["line"].(float64); ok {
-			entry.Source.Line = int(line)
+		if line, ok := intField(source, "line"); ok {
+			entry.Source.Line = line
 		}
 	}
 }
@@ -188,3 +368,37 @@ func (e *LogEntry) IsError() bool {
 	}
 	return false
 }
+
+// IsSlow returns true if the request took longer than thresholdMs
+// milliseconds; a zero threshold disables the check
+func (e *LogEntry) IsSlow(thresholdMs int) bool {
+	return thresholdMs > 0 && e.ElapsedMs > float64(thresholdMs)
+}
+
+// Error categories, see ErrorCategory
+const (
+	CategoryClientError = "client_error" // the request failed with a 4xx status
+	CategoryServerError = "server_error" // the request failed with a 5xx status
+	CategoryPanic       = "panic"        // the code panicked
+	CategoryAppError    = "app_error"    // any other logged error
+)
+
+// ErrorCategory classifies the entry for triage: panics (by level or stack
+// trace) come first, then server and client errors by status, and everything
+// else is an application error
+func (e *LogEntry) ErrorCategory() string {
+	switch strings.ToLower(e.Level) {
+	case "panic", "dpanic", "fatal":
+		return CategoryPanic
+	}
+	if e.Stacktrace != "" && strings.Contains(e.Stacktrace, "panic") {
+		return CategoryPanic
+	}
+	if e.Status >= 500 {
+		return CategoryServerError
+	}
+	if e.Status >= 400 {
+		return CategoryClientError
+	}
+	return CategoryAppError
+}
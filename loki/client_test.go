@@ -0,0 +1,39 @@
+package loki
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimestampField(t *testing.T) {
+	streamTime := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	logTime := time.Date(2026, 10, 16, 7, 59, 30, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		parser  LineParser
+		line    string
+		logTime time.Time
+	}{
+		{"rfc3339", LineParser{TimestampField: "time"}, `{"time":"2026-10-16T07:59:30Z"}`, logTime},
+		{"unix millis", LineParser{TimestampField: "ts", TimestampFormat: TimestampUnixMillis}, `{"ts":1792137570000}`, logTime},
+		{"field not configured", LineParser{}, `{"time":"2026-10-16T07:59:30Z"}`, time.Time{}},
+		{"field missing", LineParser{TimestampField: "time"}, `{"msg":"boom"}`, time.Time{}},
+		{"field invalid", LineParser{TimestampField: "time"}, `{"time":"yesterday"}`, time.Time{}},
+		{"not JSON", LineParser{TimestampField: "time"}, `boom`, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := tt.parser.Parse(streamTime, tt.line)
+			// The stream timestamp keys deduplication and resuming, so the
+			// log's own time must never replace it
+			if !entry.Timestamp.Equal(streamTime) {
+				t.Errorf("Timestamp = %v, want stream time %v", entry.Timestamp, streamTime)
+			}
+			if !entry.LogTime.Equal(tt.logTime) {
+				t.Errorf("LogTime = %v, want %v", entry.LogTime, tt.logTime)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package loki
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LookupPath resolves a dotted path (e.g. "request.method" or "errors.0.msg")
+// in a parsed log. Keys that themselves contain dots are matched first.
+func LookupPath(parsed map[string]interface{}, path string) (interface{}, bool) {
+	if value, ok := parsed[path]; ok {
+		return value, true
+	}
+
+	var current interface{} = parsed
+	for _, segment := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[segment]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil || index < 0 || index >= len(node) {
+				return nil, false
+			}
+			current = node[index]
+		default:
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// stringField returns the first string value found at any of the given paths
+func stringField(parsed map[string]interface{}, paths ...string) (string, bool) {
+	for _, path := range paths {
+		if value, ok := LookupPath(parsed, path); ok {
+			if s, ok := value.(string); ok {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
+
+// ErrorTypeFields are the log fields the error type is read from, in order
+// of preference
+var ErrorTypeFields = []string{"err_type", "error_type", "exception.type", "exception.class", "error.type", "exception", "error"}
+
+// freeTextErrorField usually holds an error message rather than a type, so
+// its value is only taken as the error type when it looks like an identifier
+const freeTextErrorField = "error"
+
+// maxErrorTypeLength bounds an error type read from freeTextErrorField
+const maxErrorTypeLength = 64
+
+// errorIdentifierPattern matches identifier-like error values such as
+// ECONNREFUSED or context.DeadlineExceeded
+var errorIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.$-]*$`)
+
+// errorTypeField returns the error type from the first of ErrorTypeFields
+// holding a string. Only the first line is kept, so an exception logged with
+// its trace is identified by its first line.
+func errorTypeField(parsed map[string]interface{}) (string, bool) {
+	for _, path := range ErrorTypeFields {
+		errType, ok := stringField(parsed, path)
+		if !ok {
+			continue
+		}
+		errType, _, _ = strings.Cut(strings.TrimSpace(errType), "\n")
+		errType = strings.TrimSpace(errType)
+		if path == freeTextErrorField && (len(errType) > maxErrorTypeLength || !errorIdentifierPattern.MatchString(errType)) {
+			continue
+		}
+		return errType, true
+	}
+	return "", false
+}
+
+// StacktraceFields are the log fields a stack trace is read from
+var StacktraceFields = []string{"stacktrace", "stack"}
+
+// stackField returns the first stack trace found at any of the given paths.
+// A trace logged as an array of frames is joined with newlines.
+func stackField(parsed map[string]interface{}, paths ...string) (string, bool) {
+	for _, path := range paths {
+		value, ok := LookupPath(parsed, path)
+		if !ok {
+			continue
+		}
+		switch v := value.(type) {
+		case string:
+			if v != "" {
+				return v, true
+			}
+		case []interface{}:
+			frames := make([]string, 0, len(v))
+			for _, frame := range v {
+				frames = append(frames, fmt.Sprint(frame))
+			}
+			if len(frames) > 0 {
+				return strings.Join(frames, "\n"), true
+			}
+		}
+	}
+	return "", false
+}
+
+// intField returns the first integer value found at any of the given paths
+func intField(parsed map[string]interface{}, paths ...string) (int, bool) {
+	if value, ok := floatField(parsed, paths...); ok {
+		return int(value), true
+	}
+	return 0, false
+}
+
+// floatField returns the first numeric value found at any of the given paths
+func floatField(parsed map[string]interface{}, paths ...string) (float64, bool) {
+	for _, path := range paths {
+		if value, ok := LookupPath(parsed, path); ok {
+			if f, ok := toFloat(value); ok {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// toFloat converts JSON numbers, Go integers and numeric strings (e.g. "500"
+// or "12.5") to a float64
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
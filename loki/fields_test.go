@@ -0,0 +1,121 @@
+package loki
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractNumericFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		status  int
+		elapsed float64
+		srcLine int
+	}{
+		{
+			name:    "numbers",
+			line:    `{"status":500,"elapsed_ms":12.5,"source":{"line":42}}`,
+			status:  500,
+			elapsed: 12.5,
+			srcLine: 42,
+		},
+		{
+			name:    "numeric strings",
+			line:    `{"status":"502","elapsed_ms":" 3000 ","source":{"line":"17"}}`,
+			status:  502,
+			elapsed: 3000,
+			srcLine: 17,
+		},
+		{
+			name: "non-numeric strings",
+			line: `{"status":"Internal Server Error","elapsed_ms":"slow","source":{"line":"n/a"}}`,
+		},
+		{
+			name: "missing",
+			line: `{"msg":"no numbers here","source":{"function":"main.run"}}`,
+		},
+		{
+			name:   "nested status",
+			line:   `{"response":{"status":"503"}}`,
+			status: 503,
+		},
+		{
+			name: "other types",
+			line: `{"status":true,"elapsed_ms":null,"source":{"line":[1]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := ParseLine(time.Unix(0, 0), tt.line)
+			if entry.Status != tt.status {
+				t.Errorf("Status = %d, want %d", entry.Status, tt.status)
+			}
+			if entry.ElapsedMs != tt.elapsed {
+				t.Errorf("ElapsedMs = %v, want %v", entry.ElapsedMs, tt.elapsed)
+			}
+			if entry.Source.Line != tt.srcLine {
+				t.Errorf("Source.Line = %d, want %d", entry.Source.Line, tt.srcLine)
+			}
+		})
+	}
+}
+
+func TestToFloat(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+		want  float64
+		ok    bool
+	}{
+		{"float64", 500.0, 500, true},
+		{"int", 42, 42, true},
+		{"int64", int64(7), 7, true},
+		{"numeric string", "12.5", 12.5, true},
+		{"padded numeric string", " 404 ", 404, true},
+		{"non-numeric string", "timeout", 0, false},
+		{"empty string", "", 0, false},
+		{"bool", true, 0, false},
+		{"nil", nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := toFloat(tt.value)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("toFloat(%#v) = %v, %t, want %v, %t", tt.value, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestErrorTypeField(t *testing.T) {
+	tests := []struct {
+		name   string
+		parsed map[string]interface{}
+		want   string
+		ok     bool
+	}{
+		{"err_type", map[string]interface{}{"err_type": "TimeoutError"}, "TimeoutError", true},
+		{"nested exception type", map[string]interface{}{"exception": map[string]interface{}{"type": "NullPointerException"}}, "NullPointerException", true},
+		{"exception with trace", map[string]interface{}{"exception": "java.io.IOException: closed\n\tat Foo.bar(Foo.java:12)"}, "java.io.IOException: closed", true},
+		{"preferred over error", map[string]interface{}{"error_type": "ValueError", "error": "ECONNREFUSED"}, "ValueError", true},
+		{"identifier error", map[string]interface{}{"error": "ECONNREFUSED"}, "ECONNREFUSED", true},
+		{"dotted identifier error", map[string]interface{}{"error": "context.DeadlineExceeded"}, "context.DeadlineExceeded", true},
+		{"free-text error", map[string]interface{}{"error": "sql: no rows in result set"}, "", false},
+		{"overlong error", map[string]interface{}{"error": strings.Repeat("E", maxErrorTypeLength+1)}, "", false},
+		{"non-string error", map[string]interface{}{"error": map[string]interface{}{"message": "boom"}}, "", false},
+		{"missing", map[string]interface{}{"msg": "boom"}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := errorTypeField(tt.parsed)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("errorTypeField(%v) = %q, %t, want %q, %t", tt.parsed, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
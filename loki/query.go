@@ -0,0 +1,136 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Result types returned by Loki queries
+const (
+	ResultTypeStreams = "streams"
+	ResultTypeVector  = "vector"
+	ResultTypeMatrix  = "matrix"
+)
+
+// Sample is a single metric value from a vector result
+type Sample struct {
+	Metric map[string]string `json:"metric"`
+	Value  SamplePair        `json:"value"`
+}
+
+// Series is a metric series from a matrix result
+type Series struct {
+	Metric map[string]string `json:"metric"`
+	Values []SamplePair      `json:"values"`
+}
+
+// SamplePair is a [timestamp, value] pair as encoded by Loki
+type SamplePair struct {
+	Timestamp time.Time
+	Value     float64
+}
+
+// UnmarshalJSON decodes Loki's [<unix seconds>, "<value>"] encoding
+func (s *SamplePair) UnmarshalJSON(data []byte) error {
+	var raw [2]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid sample: %w", err)
+	}
+
+	var ts float64
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return fmt.Errorf("invalid sample timestamp: %w", err)
+	}
+
+	var value string
+	if err := json.Unmarshal(raw[1], &value); err != nil {
+		return fmt.Errorf("invalid sample value: %w", err)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid sample value %q: %w", value, err)
+	}
+
+	s.Timestamp = time.Unix(0, int64(ts*float64(time.Second)))
+	s.Value = v
+	return nil
+}
+
+// QueryResult is the decoded result of a Loki query. Only the field
+// matching ResultType is populated.
+type QueryResult struct {
+	ResultType string
+	Streams    []Stream
+	Vector     []Sample
+	Matrix     []Series
+
+	parser LineParser
+}
+
+// Entries returns the log entries of a streams result
+func (r *QueryResult) Entries() []LogEntry {
+	return parseStreams(r.Streams, r.parser)
+}
+
+// Query runs an instant query evaluated at ts
+func (c *Client) Query(ctx context.Context, query string, ts time.Time) (*QueryResult, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", fmt.Sprintf("%d", ts.UnixNano()))
+
+	reqURL := fmt.Sprintf("%s/loki/api/v1/query?%s", c.baseURL, params.Encode())
+
+	resp, err := c.get(ctx, reqURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	result, err := decodeQueryResult(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	result.parser = c.parser
+	return result, nil
+}
+
+// decodeQueryResult decodes a Loki query response of any result type
+func decodeQueryResult(r io.Reader) (*QueryResult, error) {
+	var queryResp struct {
+		Status string `json:"status"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(r).Decode(&queryResp); err != nil {
+		return nil, fmt.Errorf("failed to decode Loki response: %w", err)
+	}
+
+	result := &QueryResult{ResultType: queryResp.Data.ResultType}
+
+	var target interface{}
+	switch result.ResultType {
+	case ResultTypeStreams:
+		target = &result.Streams
+	case ResultTypeVector:
+		target = &result.Vector
+	case ResultTypeMatrix:
+		target = &result.Matrix
+	default:
+		return nil, fmt.Errorf("unsupported Loki result type %q", result.ResultType)
+	}
+
+	if len(queryResp.Data.Result) > 0 {
+		if err := json.Unmarshal(queryResp.Data.Result, target); err != nil {
+			return nil, fmt.Errorf("failed to decode Loki %s result: %w", result.ResultType, err)
+		}
+	}
+
+	return result, nil
+}
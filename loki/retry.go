@@ -0,0 +1,85 @@
+package loki
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Retry backoff bounds; the delay doubles after every failed attempt
+const (
+	retryMinBackoff = 500 * time.Millisecond
+	retryMaxBackoff = 10 * time.Second
+)
+
+// WithRetries sets how often a failed query is retried. Network errors and
+// 5xx responses are retried with exponential backoff; other errors are not.
+func WithRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// get performs a GET request against the Loki API, retrying transient
+// failures until ctx is cancelled. The returned response always has status
+// 200; the caller must close its body.
+func (c *Client) get(ctx context.Context, reqURL string) (*http.Response, error) {
+	backoff := retryMinBackoff
+
+	for attempt := 0; ; attempt++ {
+		resp, err := c.do(ctx, reqURL)
+
+		var retryable bool
+		switch {
+		case err != nil:
+			err = fmt.Errorf("failed to query Loki: %w", err)
+			retryable = true
+		case resp.StatusCode != http.StatusOK:
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			err = fmt.Errorf("Loki returned status %d: %s", resp.StatusCode, string(body))
+			retryable = resp.StatusCode >= 500
+		default:
+			return resp, nil
+		}
+
+		if !retryable || attempt >= c.maxRetries {
+			return nil, err
+		}
+
+		log.Printf("Warning: %v (retry %d/%d in %s)", err, attempt+1, c.maxRetries, backoff)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > retryMaxBackoff {
+			backoff = retryMaxBackoff
+		}
+	}
+}
+
+// do sends a single GET request. Responses are requested gzip-compressed and
+// decompressed here, since setting Accept-Encoding disables the transport's
+// own transparent decompression.
+func (c *Client) do(ctx context.Context, reqURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := decompress(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,93 @@
+package loki
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"vigil/transport"
+
+	"github.com/gorilla/websocket"
+)
+
+// TailResponse represents a message received from the Loki tail websocket
+type TailResponse struct {
+	Streams        []Stream       `json:"streams"`
+	DroppedEntries []DroppedEntry `json:"dropped_entries"`
+}
+
+// DroppedEntry represents an entry Loki dropped while tailing
+type DroppedEntry struct {
+	Labels    map[string]string `json:"labels"`
+	Timestamp string            `json:"timestamp"`
+}
+
+// Tail connects to the Loki tail websocket and calls handler for every
+// entry as it arrives. It blocks until the context is cancelled or the
+// connection fails.
+func (c *Client) Tail(ctx context.Context, query string, start time.Time, handler func(LogEntry)) error {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", fmt.Sprintf("%d", start.UnixNano()))
+
+	wsURL, err := tailURL(c.baseURL)
+	if err != nil {
+		return err
+	}
+	reqURL := fmt.Sprintf("%s/loki/api/v1/tail?%s", wsURL, params.Encode())
+
+	dialer := websocket.Dialer{
+		HandshakeTimeout: c.httpClient.Timeout,
+		TLSClientConfig:  c.tlsConfig,
+		Proxy:            transport.Proxy,
+	}
+
+	conn, resp, err := dialer.DialContext(ctx, reqURL, transport.Headers())
+	if err != nil {
+		if resp != nil {
+			return fmt.Errorf("failed to connect to Loki tail (status %d): %w", resp.StatusCode, err)
+		}
+		return fmt.Errorf("failed to connect to Loki tail: %w", err)
+	}
+	defer conn.Close()
+
+	// Close the connection when the context is cancelled to unblock ReadJSON
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	for {
+		var tailResp TailResponse
+		if err := conn.ReadJSON(&tailResp); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return fmt.Errorf("Loki tail connection lost: %w", err)
+		}
+
+		for _, entry := range parseStreams(tailResp.Streams, c.parser) {
+			handler(entry)
+		}
+	}
+}
+
+// tailURL converts the Loki base URL to its websocket equivalent
+func tailURL(baseURL string) (string, error) {
+	switch {
+	case strings.HasPrefix(baseURL, "https://"):
+		return "wss://" + strings.TrimPrefix(baseURL, "https://"), nil
+	case strings.HasPrefix(baseURL, "http://"):
+		return "ws://" + strings.TrimPrefix(baseURL, "http://"), nil
+	case strings.HasPrefix(baseURL, "ws://"), strings.HasPrefix(baseURL, "wss://"):
+		return baseURL, nil
+	}
+	return "", fmt.Errorf("unsupported Loki URL scheme: %s", baseURL)
+}
@@ -0,0 +1,55 @@
+package loki
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Timestamp formats for LineParser.TimestampFormat
+const (
+	TimestampAuto       = ""        // RFC3339 strings, Unix seconds or millis by magnitude
+	TimestampRFC3339    = "rfc3339" // e.g. "2024-01-15T10:23:45.123Z"
+	TimestampUnix       = "unix"    // seconds, fractions allowed
+	TimestampUnixMillis = "unix_ms" // milliseconds
+)
+
+// unixMillisThreshold separates Unix seconds from millis when auto-detecting;
+// 1e11 seconds is in the year 5138
+const unixMillisThreshold = 1e11
+
+// ValidateTimestampFormat checks that a timestamp format is supported
+func ValidateTimestampFormat(format string) error {
+	switch format {
+	case TimestampAuto, TimestampRFC3339, TimestampUnix, TimestampUnixMillis:
+		return nil
+	}
+	return fmt.Errorf("unknown timestamp format %q (expected %s, %s or %s)", format, TimestampRFC3339, TimestampUnix, TimestampUnixMillis)
+}
+
+// parseTimestamp converts a parsed JSON value to a time in the given format
+func parseTimestamp(value interface{}, format string) (time.Time, bool) {
+	if s, ok := value.(string); ok && format != TimestampUnix && format != TimestampUnixMillis {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, true
+		}
+		if format == TimestampRFC3339 {
+			return time.Time{}, false
+		}
+	}
+	if format == TimestampRFC3339 {
+		return time.Time{}, false
+	}
+
+	n, ok := toFloat(value)
+	if !ok || n <= 0 {
+		return time.Time{}, false
+	}
+
+	millis := format == TimestampUnixMillis || (format == TimestampAuto && n >= unixMillisThreshold)
+	if millis {
+		return time.UnixMilli(int64(n)).Add(time.Duration((n - math.Trunc(n)) * float64(time.Millisecond))), true
+	}
+	sec, frac := math.Modf(n)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), true
+}
@@ -2,15 +2,29 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
+	"vigil/cache"
+	"vigil/config"
 	"vigil/gitea"
+	"vigil/gitlab"
+	"vigil/loki"
 	"vigil/notifier"
 	"vigil/processor"
+	"vigil/server"
+	"vigil/transport"
 
 	"github.com/joho/godotenv"
 )
@@ -21,113 +35,837 @@ func main() {
 		log.Println("No .env file found, using environment variables")
 	}
 
-	// Setup Gitea client
-	giteaClient := setupGitea()
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(os.Args[2:])
+		return
+	}
+
+	configPath := flag.String("config", os.Getenv("VIGIL_CONFIG"), configUsage)
+	flag.Parse()
+
+	log.Printf("Starting %s", transport.UserAgent())
+
+	cfg := loadConfig(*configPath)
+	setupProxy(cfg)
+
+	// Setup issue tracker (Gitea or GitLab)
+	tracker := setupTracker(cfg)
 
 	// Setup notifiers
-	notifiers := setupNotifiers()
+	notifiers := setupNotifiers(cfg)
+
+	// Setup log line parsing shared by Loki and pushed errors
+	parser := setupLineParser(cfg)
 
 	// Setup processor
-	proc := setupProcessor(giteaClient, notifiers)
+	proc := setupProcessor(cfg, tracker, notifiers, parser)
+
+	// Process a log file or stdin instead of querying Loki, then exit
+	if setupSource(cfg) == processor.SourceFile {
+		runFileSource(cfg, proc, parser)
+		return
+	}
 
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
+	go cancelOnSignal(cancel)
 
-	// Handle shutdown signals
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	go func() {
-		<-sigChan
-		log.Println("Shutting down...")
-		cancel()
-	}()
+	// Start HTTP server for pushed errors and the recent errors API
+	setupServer(ctx, cfg, proc, parser)
 
 	// Start processor (blocks until context is cancelled)
 	proc.Start(ctx)
 	log.Println("Shutdown complete")
 }
 
-func setupGitea() *gitea.Client {
-	url := os.Getenv("GITEA_URL")
-	if url == "" {
-		log.Fatal("GITEA_URL is required")
+// configUsage describes the --config flag
+const configUsage = "path to a YAML config file (environment variables override its values)"
+
+// loadConfig loads the config file, if any, and the environment
+func loadConfig(path string) *config.Config {
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	if path != "" {
+		log.Printf("Loaded config from %s", path)
+	}
+	return cfg
+}
+
+// setupProxy applies VIGIL_PROXY to all outbound clients. Without it they
+// use the proxy from HTTP_PROXY, HTTPS_PROXY and NO_PROXY.
+func setupProxy(cfg *config.Config) {
+	if cfg.Proxy == "" {
+		return
+	}
+	if err := transport.SetProxy(cfg.Proxy); err != nil {
+		log.Fatalf("Invalid VIGIL_PROXY: %v", err)
 	}
+	u, _ := url.Parse(cfg.Proxy)
+	log.Printf("Sending all outbound requests through proxy %s", u.Redacted())
+}
+
+// cancelOnSignal cancels the context on SIGINT or SIGTERM
+func cancelOnSignal(cancel context.CancelFunc) {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+
+	<-sigChan
+	log.Println("Shutting down...")
+	cancel()
+}
 
-	token := os.Getenv("GITEA_TOKEN")
-	if token == "" {
-		log.Fatal("GITEA_TOKEN is required")
+func setupServer(ctx context.Context, cfg *config.Config, proc *processor.Processor, parser loki.LineParser) {
+	token := cfg.Server.IngestToken
+	recentSize := recentBufferSize(cfg)
+	if token == "" && recentSize == 0 && cfg.Loki.Watchdog == "" {
+		return
 	}
 
-	owner := os.Getenv("GITEA_OWNER")
-	if owner == "" {
-		log.Fatal("GITEA_OWNER is required")
+	addr := cfg.Server.Addr
+	if addr == "" {
+		addr = ":8080"
 	}
 
-	repo := os.Getenv("GITEA_REPO")
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", server.HealthHandler(proc.Liveness))
+	if token != "" {
+		mux.Handle("/ingest", server.IngestHandler(token, parser.Parse, proc.Submit))
+		log.Println("Ingest endpoint enabled at /ingest")
+	}
+	if recentSize > 0 {
+		mux.Handle("/api/recent", server.RecentHandler(proc.Recent))
+		mux.Handle("/recent", server.RecentPageHandler(proc.Recent))
+		log.Printf("Recent errors enabled at /recent and /api/recent (last %d)", recentSize)
+	}
+
+	go server.Run(ctx, addr, mux)
+}
+
+// recentBufferSize returns the number of processed errors kept for the
+// recent errors API (0 when disabled)
+func recentBufferSize(cfg *config.Config) int {
+	rs := cfg.Server.RecentBufferSize
+	if rs == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(rs)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid RECENT_BUFFER_SIZE %q (expected a non-negative integer)", rs)
+	}
+	return n
+}
+
+// setupTracker picks the issue backend: GitLab when GITLAB_URL is set,
+// Gitea otherwise
+func setupTracker(cfg *config.Config) processor.IssueTracker {
+	if cfg.GitLab.URL != "" {
+		return setupGitLab(cfg)
+	}
+	return setupGitea(cfg)
+}
+
+func setupGitLab(cfg *config.Config) *gitlab.Client {
+	url := cfg.GitLab.URL
+	token := cfg.GitLab.Token
+	project := cfg.GitLab.Project
+
+	var opts []gitlab.Option
+	timeout, tlsConfig := setupHTTP("GITLAB", cfg.GitLab.HTTP)
+	if timeout > 0 {
+		opts = append(opts, gitlab.WithTimeout(timeout))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, gitlab.WithTLSConfig(tlsConfig))
+	}
+
+	log.Printf("GitLab: %s/%s", url, project)
+	return gitlab.NewClient(url, token, project, opts...)
+}
+
+func setupGitea(cfg *config.Config) *gitea.Client {
+	url := cfg.Gitea.URL
+	token := cfg.Gitea.Token
+	owner := cfg.Gitea.Owner
+
+	repo := cfg.Gitea.Repo
 	if repo == "" {
 		repo = "error-issues"
 	}
 
+	var opts []gitea.Option
+	timeout, tlsConfig := setupHTTP("GITEA", cfg.Gitea.HTTP)
+	if timeout > 0 {
+		opts = append(opts, gitea.WithTimeout(timeout))
+	}
+	if tlsConfig != nil {
+		opts = append(opts, gitea.WithTLSConfig(tlsConfig))
+	}
+	if cfg.Gitea.EnforceLabelColors == "true" {
+		opts = append(opts, gitea.WithEnforceLabelColors())
+	}
+	if cfg.Gitea.OrgLabels == "true" {
+		opts = append(opts, gitea.WithOrgLabels())
+		log.Printf("Creating labels at the organization level (%s)", owner)
+	}
+
 	log.Printf("Gitea: %s/%s/%s", url, owner, repo)
-	return gitea.NewClient(url, token, owner, repo)
+	return gitea.NewClient(url, token, owner, repo, opts...)
 }
 
-func setupNotifiers() []notifier.Notifier {
-	var notifiers []notifier.Notifier
-
-	// Slack
-	if webhookURL := os.Getenv("SLACK_WEBHOOK_URL"); webhookURL != "" {
-		notifiers = append(notifiers, notifier.NewSlackNotifier(webhookURL))
-		log.Println("Slack notifier enabled")
+// setupHTTP reads the <prefix>_TIMEOUT, <prefix>_CA_FILE and
+// <prefix>_INSECURE_SKIP_VERIFY settings for an outbound client.
+// A zero timeout or nil TLS config means the client default is kept.
+func setupHTTP(prefix string, h config.HTTP) (time.Duration, *tls.Config) {
+	var timeout time.Duration
+	if t := h.Timeout; t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			log.Fatalf("Invalid %s_TIMEOUT: %v", prefix, err)
+		}
+		timeout = d
 	}
 
-	// Discord
-	if webhookURL := os.Getenv("DISCORD_WEBHOOK_URL"); webhookURL != "" {
-		notifiers = append(notifiers, notifier.NewDiscordNotifier(webhookURL))
-		log.Println("Discord notifier enabled")
+	caFile := h.CAFile
+	insecure := h.InsecureSkipVerify == "true"
+	if caFile == "" && !insecure {
+		return timeout, nil
 	}
 
-	// Telegram
-	botToken := os.Getenv("TELEGRAM_BOT_TOKEN")
-	chatID := os.Getenv("TELEGRAM_CHAT_ID")
-	if botToken != "" && chatID != "" {
-		notifiers = append(notifiers, notifier.NewTelegramNotifier(botToken, chatID))
-		log.Println("Telegram notifier enabled")
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if insecure {
+		log.Printf("WARNING: TLS certificate verification disabled for %s", prefix)
 	}
 
-	if len(notifiers) == 0 {
-		log.Println("No notifiers configured (issues will still be created in Gitea)")
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			log.Fatalf("Failed to read %s_CA_FILE: %v", prefix, err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			log.Fatalf("No certificates found in %s_CA_FILE %s", prefix, caFile)
+		}
+		tlsConfig.RootCAs = pool
 	}
 
-	return notifiers
+	return timeout, tlsConfig
 }
 
-func setupProcessor(giteaClient *gitea.Client, notifiers []notifier.Notifier) *processor.Processor {
-	lokiURL := os.Getenv("LOKI_URL")
+// setupLineParser reads the optional TS_FIELD and TS_FORMAT settings
+func setupLineParser(cfg *config.Config) loki.LineParser {
+	parser := loki.LineParser{
+		TimestampField:  cfg.Log.TimestampField,
+		TimestampFormat: strings.ToLower(cfg.Log.TimestampFormat),
+		ServiceLabel:    cfg.Log.ServiceLabel,
+	}
+	if err := loki.ValidateTimestampFormat(parser.TimestampFormat); err != nil {
+		log.Fatalf("Invalid TS_FORMAT: %v", err)
+	}
+	if parser.TimestampField != "" {
+		log.Printf("Using log timestamps from field %q", parser.TimestampField)
+	}
+	if parser.ServiceLabel != "" {
+		log.Printf("Using stream label %q as the service of logs without a service field", parser.ServiceLabel)
+	}
+	return parser
+}
+
+func setupProcessor(cfg *config.Config, tracker processor.IssueTracker, notifiers []notifier.Notifier, parser loki.LineParser) *processor.Processor {
+	lokiURL := cfg.Loki.URL
 	if lokiURL == "" {
 		lokiURL = "http://loki:3100"
 	}
 
 	pollInterval := 30 * time.Second
-	if interval := os.Getenv("LOKI_POLL_INTERVAL"); interval != "" {
+	if interval := cfg.Loki.PollInterval; interval != "" {
 		if d, err := time.ParseDuration(interval); err == nil {
 			pollInterval = d
 		}
 	}
 
+	var pollJitter time.Duration
+	if pj := cfg.Loki.PollJitter; pj != "" {
+		d, err := time.ParseDuration(pj)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid POLL_JITTER %q (expected a duration like 5s)", pj)
+		}
+		pollJitter = d
+	}
+
+	var watchdogTimeout time.Duration
+	if wt := cfg.Loki.Watchdog; wt != "" {
+		d, err := time.ParseDuration(wt)
+		if err != nil || d < 0 || (d > 0 && d <= pollInterval+pollJitter) {
+			log.Fatalf("Invalid WATCHDOG_TIMEOUT %q (expected a duration longer than the poll interval, like %s)", wt, 5*pollInterval)
+		}
+		watchdogTimeout = d
+	}
+
 	lookback := 5 * time.Minute
-	if lb := os.Getenv("LOKI_LOOKBACK"); lb != "" {
+	if lb := cfg.Loki.Lookback; lb != "" {
 		if d, err := time.ParseDuration(lb); err == nil {
 			lookback = d
 		}
 	}
 
-	cfg := processor.Config{
+	overlap := 10 * time.Second
+	if ov := cfg.Loki.PollOverlap; ov != "" {
+		if d, err := time.ParseDuration(ov); err == nil {
+			overlap = d
+		}
+	}
+
+	concurrency := 4
+	if c := cfg.Processor.Concurrency; c != "" {
+		n, err := strconv.Atoi(c)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid PROCESS_CONCURRENCY %q (expected a positive integer)", c)
+		}
+		concurrency = n
+	}
+
+	mode := cfg.Loki.Mode
+	switch mode {
+	case "":
+		mode = processor.ModePoll
+	case processor.ModePoll, processor.ModeTail:
+	default:
+		log.Fatalf("Invalid LOKI_MODE %q (expected %q or %q)", mode, processor.ModePoll, processor.ModeTail)
+	}
+
+	var minSeverity string
+	if ms := cfg.Processor.MinSeverity; ms != "" {
+		severity, err := processor.ParseSeverity(ms)
+		if err != nil {
+			log.Fatalf("Invalid MIN_SEVERITY: %v", err)
+		}
+		minSeverity = severity
+	}
+
+	ignoreEndpoints := cfg.Processor.IgnoreEndpoints
+	if err := processor.ValidateIgnoreEndpoints(ignoreEndpoints); err != nil {
+		log.Fatalf("Invalid IGNORE_ENDPOINTS: %v", err)
+	}
+
+	ignoreMessages, err := processor.CompileIgnorePatterns(cfg.Processor.IgnoreMessagePatterns)
+	if err != nil {
+		log.Fatalf("Invalid IGNORE_MESSAGE_PATTERNS: %v", err)
+	}
+
+	var latencyThreshold int
+	if lt := cfg.Processor.LatencyThresholdMs; lt != "" {
+		n, err := strconv.Atoi(lt)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid LATENCY_THRESHOLD_MS %q (expected a non-negative integer)", lt)
+		}
+		latencyThreshold = n
+	}
+	if latencyThreshold > 0 {
+		log.Printf("Tracking requests slower than %dms as performance issues", latencyThreshold)
+	}
+
+	var dedupTTL time.Duration
+	if ttl := cfg.Processor.DedupTTL; ttl != "" {
+		d, err := processor.ParseDedupTTL(ttl)
+		if err != nil {
+			log.Fatalf("Invalid DEDUP_TTL: %v", err)
+		}
+		dedupTTL = d
+	}
+	if dedupTTL > 0 {
+		log.Printf("Creating new issues for errors recurring more than %s after their issue was closed", dedupTTL)
+	}
+
+	var sampleRate float64
+	if sr := cfg.Processor.OccurrenceSampleRate; sr != "" {
+		f, err := strconv.ParseFloat(sr, 64)
+		if err != nil || f <= 0 || f > 1 {
+			log.Fatalf("Invalid OCCURRENCE_SAMPLE_RATE %q (expected a fraction like 0.01)", sr)
+		}
+		sampleRate = f
+	}
+	sampleThreshold := processor.DefaultSampleThreshold
+	if st := cfg.Processor.OccurrenceSampleThreshold; st != "" {
+		n, err := strconv.Atoi(st)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid OCCURRENCE_SAMPLE_THRESHOLD %q (expected a positive integer)", st)
+		}
+		sampleThreshold = n
+	}
+	if sampleRate > 0 && sampleRate < 1 {
+		log.Printf("Sampling %g of occurrences above %d per minute per bug ID", sampleRate, sampleThreshold)
+	}
+
+	var severityKeywords []processor.SeverityKeyword
+	if spec := cfg.Processor.SeverityKeywords; spec != "" {
+		keywords, err := processor.ParseSeverityKeywords(spec)
+		if err != nil {
+			log.Fatalf("Invalid SEVERITY_KEYWORDS: %v", err)
+		}
+		severityKeywords = keywords
+	}
+
+	query, err := processor.BuildErrorQuery(cfg.Loki.LabelSelector, cfg.Loki.ExtraFilters, latencyThreshold, severityKeywords)
+	if err != nil {
+		log.Fatalf("Invalid LOKI_LABEL_SELECTOR: %v", err)
+	}
+	log.Printf("Loki query: %s", query)
+
+	maxRetries := 3
+	if mr := cfg.Loki.MaxRetries; mr != "" {
+		n, err := strconv.Atoi(mr)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid LOKI_MAX_RETRIES %q (expected a non-negative integer)", mr)
+		}
+		maxRetries = n
+	}
+
+	queryLimit := processor.DefaultQueryLimit
+	if ql := cfg.Loki.QueryLimit; ql != "" {
+		n, err := strconv.Atoi(ql)
+		if err != nil || n <= 0 {
+			log.Fatalf("Invalid LOKI_QUERY_LIMIT %q (expected a positive integer)", ql)
+		}
+		queryLimit = n
+	}
+
+	lokiOpts := []loki.Option{loki.WithLineParser(parser), loki.WithRetries(maxRetries)}
+	timeout, tlsConfig := setupHTTP("LOKI", cfg.Loki.HTTP)
+	if timeout > 0 {
+		lokiOpts = append(lokiOpts, loki.WithTimeout(timeout))
+	}
+	if tlsConfig != nil {
+		lokiOpts = append(lokiOpts, loki.WithTLSConfig(tlsConfig))
+	}
+
+	bugIDFields := cfg.Processor.BugIDFields
+	if err := processor.ValidateBugIDFields(bugIDFields); err != nil {
+		log.Fatalf("Invalid BUGID_FIELDS: %v", err)
+	}
+
+	bugIDLabelColorMode := cfg.Processor.BugIDLabelColorMode
+	switch bugIDLabelColorMode {
+	case "":
+		bugIDLabelColorMode = processor.BugIDLabelColorHash
+	case processor.BugIDLabelColorHash, processor.BugIDLabelColorFixed:
+	default:
+		log.Fatalf("Invalid BUGID_LABEL_COLOR_MODE %q (expected %q or %q)", bugIDLabelColorMode, processor.BugIDLabelColorHash, processor.BugIDLabelColorFixed)
+	}
+
+	notifyMode := cfg.Notifiers.Mode
+	switch notifyMode {
+	case "":
+		notifyMode = processor.NotifyModeImmediate
+	case processor.NotifyModeImmediate, processor.NotifyModeDigest:
+	default:
+		log.Fatalf("Invalid NOTIFY_MODE %q (expected %q or %q)", notifyMode, processor.NotifyModeImmediate, processor.NotifyModeDigest)
+	}
+
+	var notifyRoutes map[string][]string
+	if spec := cfg.Notifiers.Routes; spec != "" {
+		routes, err := processor.ParseNotifyRoutes(spec)
+		if err != nil {
+			log.Fatalf("Invalid NOTIFY_ROUTES: %v", err)
+		}
+		enabled := make(map[string]bool)
+		for _, n := range notifiers {
+			enabled[n.Name()] = true
+		}
+		for severity, names := range routes {
+			for _, name := range names {
+				if !enabled[name] {
+					log.Fatalf("Invalid NOTIFY_ROUTES: notifier %q for %s issues is not configured", name, severity)
+				}
+			}
+			log.Printf("Routing %s notifications to %s", severity, strings.Join(names, ", "))
+		}
+		notifyRoutes = routes
+	}
+
+	metricAlerts := parseMetricAlerts(cfg.MetricAlerts)
+
+	var maintenanceUntil time.Time
+	if mu := cfg.Notifiers.MaintenanceUntil; mu != "" {
+		t, err := time.Parse(time.RFC3339, mu)
+		if err != nil {
+			log.Fatalf("Invalid MAINTENANCE_UNTIL %q (expected an RFC 3339 timestamp like 2006-01-02T15:04:05Z)", mu)
+		}
+		maintenanceUntil = t
+	}
+	if mf := cfg.Notifiers.MaintenanceFile; mf != "" {
+		log.Printf("Notifications are suppressed while %s exists", mf)
+	}
+
+	commentMode := cfg.Processor.CommentMode
+	switch commentMode {
+	case "":
+		commentMode = processor.CommentModeOccurrence
+	case processor.CommentModeOccurrence, processor.CommentModeStats:
+	default:
+		log.Fatalf("Invalid COMMENT_MODE %q (expected %q or %q)", commentMode, processor.CommentModeOccurrence, processor.CommentModeStats)
+	}
+
+	reopenMode, reopenThreshold := processor.ReopenAlways, 0
+	if rm := cfg.Processor.ReopenMode; rm != "" {
+		mode, threshold, err := processor.ParseReopenMode(rm)
+		if err != nil {
+			log.Fatalf("Invalid REOPEN_MODE: %v", err)
+		}
+		reopenMode, reopenThreshold = mode, threshold
+	}
+
+	var stormThreshold int
+	if st := cfg.Processor.StormThreshold; st != "" {
+		n, err := strconv.Atoi(st)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid STORM_THRESHOLD %q (expected a non-negative integer)", st)
+		}
+		stormThreshold = n
+	}
+
+	stormWindow := processor.DefaultStormWindow
+	if sw := cfg.Processor.StormWindow; sw != "" {
+		d, err := time.ParseDuration(sw)
+		if err != nil || d <= 0 {
+			log.Fatalf("Invalid STORM_WINDOW %q (expected a duration like 5m)", sw)
+		}
+		stormWindow = d
+	}
+	if stormThreshold > 0 {
+		log.Printf("Storm detection enabled: more than %d new errors within %s", stormThreshold, stormWindow)
+	}
+
+	digestInterval := 15 * time.Minute
+	if di := cfg.Notifiers.DigestInterval; di != "" {
+		if d, err := time.ParseDuration(di); err == nil {
+			digestInterval = d
+		}
+	}
+
+	var digestSchedule *processor.DigestSchedule
+	if ds := cfg.Notifiers.DigestSchedule; ds != "" {
+		schedule, err := processor.ParseDigestSchedule(ds)
+		if err != nil {
+			log.Fatalf("Invalid DIGEST_SCHEDULE: %v", err)
+		}
+		digestSchedule = &schedule
+	}
+
+	var resolveAfter time.Duration
+	if ra := cfg.Processor.ResolveAfter; ra != "" {
+		d, err := time.ParseDuration(ra)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid RESOLVE_AFTER %q (expected a duration like 30m)", ra)
+		}
+		resolveAfter = d
+	}
+
+	var deescalateAfter time.Duration
+	if da := cfg.Processor.DeescalateAfter; da != "" {
+		d, err := time.ParseDuration(da)
+		if err != nil || d < 0 {
+			log.Fatalf("Invalid DEESCALATE_AFTER %q (expected a duration like 2h)", da)
+		}
+		deescalateAfter = d
+	}
+
+	var closeCommentTemplate *template.Template
+	if t := cfg.Processor.CloseCommentTemplate; t != "" {
+		tmpl, err := processor.ParseCloseCommentTemplate(t)
+		if err != nil {
+			log.Fatalf("Invalid CLOSE_COMMENT_TEMPLATE: %v", err)
+		}
+		closeCommentTemplate = tmpl
+	}
+
+	giteaClient, isGitea := tracker.(*gitea.Client)
+
+	var milestone int64
+	if m := cfg.Gitea.Milestone; m != "" {
+		if !isGitea {
+			log.Fatal("GITEA_MILESTONE is only supported with the Gitea backend")
+		}
+		if id, err := strconv.ParseInt(m, 10, 64); err == nil {
+			milestone = id
+		} else {
+			id, err := giteaClient.FindMilestone(m)
+			if err != nil {
+				log.Fatalf("Invalid GITEA_MILESTONE: %v", err)
+			}
+			milestone = id
+		}
+		log.Printf("Assigning new issues to milestone %d", milestone)
+	}
+
+	maxBodyBytes := processor.DefaultMaxBodyBytes
+	if mb := cfg.Processor.MaxBodyBytes; mb != "" {
+		n, err := strconv.Atoi(mb)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid MAX_BODY_BYTES %q (expected a non-negative integer)", mb)
+		}
+		maxBodyBytes = n
+	}
+
+	maxTitleLength := processor.DefaultMaxTitleLength
+	if ml := cfg.Processor.MaxTitleLength; ml != "" {
+		n, err := strconv.Atoi(ml)
+		if err != nil || n < 20 || n > processor.MaxTitleLength {
+			log.Fatalf("Invalid MAX_TITLE_LENGTH %q (expected an integer from 20 to %d)", ml, processor.MaxTitleLength)
+		}
+		maxTitleLength = n
+	}
+
+	var priorityLabels map[string]string
+	if spec := cfg.Processor.PriorityLabels; spec != "" {
+		labels, err := processor.ParsePriorityLabels(spec)
+		if err != nil {
+			log.Fatalf("Invalid PRIORITY_LABELS: %v", err)
+		}
+		priorityLabels = labels
+	}
+
+	stackLines := processor.DefaultStacktraceLines
+	if sl := cfg.Processor.StacktraceLines; sl != "" {
+		n, err := strconv.Atoi(sl)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid STACKTRACE_LINES %q (expected a non-negative integer)", sl)
+		}
+		stackLines = n
+	}
+
+	var contextLines int
+	if cl := cfg.Processor.ContextLines; cl != "" {
+		n, err := strconv.Atoi(cl)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid CONTEXT_LINES %q (expected a non-negative integer)", cl)
+		}
+		contextLines = n
+	}
+
+	var bodySections []string
+	if spec := cfg.Processor.BodySections; spec != "" {
+		sections, err := processor.ParseBodySections(spec)
+		if err != nil {
+			log.Fatalf("Invalid BODY_SECTIONS: %v", err)
+		}
+		bodySections = sections
+		log.Printf("Issue bodies will show sections %s", strings.Join(sections, ", "))
+	}
+
+	var traceURLTemplate, logsURLTemplate *template.Template
+	if t := cfg.Processor.TraceURLTemplate; t != "" {
+		tmpl, err := processor.ParseLinkTemplate("trace", t)
+		if err != nil {
+			log.Fatalf("Invalid GRAFANA_TRACE_URL_TEMPLATE: %v", err)
+		}
+		traceURLTemplate = tmpl
+	}
+	if t := cfg.Processor.LogsURLTemplate; t != "" {
+		tmpl, err := processor.ParseLinkTemplate("logs", t)
+		if err != nil {
+			log.Fatalf("Invalid GRAFANA_LOGS_URL_TEMPLATE: %v", err)
+		}
+		logsURLTemplate = tmpl
+	}
+
+	rateWindow := processor.DefaultRateWindow
+	if rw := cfg.Processor.ErrorRateWindow; rw != "" {
+		if d, err := time.ParseDuration(rw); err == nil {
+			rateWindow = d
+		}
+	}
+
+	unroutedLabel := cfg.Processor.UnroutedLabel
+	if unroutedLabel == "" {
+		unroutedLabel = processor.DefaultUnroutedLabel
+	}
+
+	var repoRoutes map[string]processor.IssueTracker
+	if spec := cfg.Processor.RepoRoutes; spec != "" {
+		if !isGitea {
+			log.Fatal("REPO_ROUTES is only supported with the Gitea backend")
+		}
+		routes, err := processor.ParseRepoRoutes(spec, giteaClient, cfg.Gitea.Owner)
+		if err != nil {
+			log.Fatalf("Invalid REPO_ROUTES: %v", err)
+		}
+		for service, client := range routes {
+			log.Printf("Routing service %s to %s", service, client.Repo())
+		}
+		log.Printf("Routing other services to %s (label: %s)", tracker.Repo(), unroutedLabel)
+		repoRoutes = routes
+	}
+
+	var userMap map[string]string
+	if path := cfg.Processor.UserMapFile; path != "" {
+		users, err := processor.LoadUserMap(path)
+		if err != nil {
+			log.Fatalf("Invalid USER_MAP_FILE: %v", err)
+		}
+		userMap = users
+		log.Printf("Mentioning affected users in new issues (%d mapped users)", len(users))
+	}
+
+	var bugCache cache.Cache
+	if path := cfg.Processor.CacheDB; path != "" {
+		sqliteCache, err := cache.OpenSQLite(path)
+		if err != nil {
+			log.Fatalf("Failed to open CACHE_DB: %v", err)
+		}
+		bugCache = sqliteCache
+		log.Printf("Bug ID cache enabled: %s", path)
+	}
+
+	var queue *processor.Queue
+	if dir := cfg.Processor.QueueDir; dir != "" {
+		q, err := processor.OpenQueue(dir)
+		if err != nil {
+			log.Fatalf("Failed to open QUEUE_DIR: %v", err)
+		}
+		queue = q
+		log.Printf("Retry queue enabled: %s (%d pending)", dir, q.Len())
+	}
+
+	procCfg := processor.Config{
 		LokiURL:      lokiURL,
+		Mode:         mode,
 		PollInterval: pollInterval,
+		PollJitter:   pollJitter,
 		Lookback:     lookback,
+		Overlap:      overlap,
+		QueryLimit:   queryLimit,
+		AutoPaginate: cfg.Loki.AutoPaginate == "true",
+		Concurrency:  concurrency,
+		MinSeverity:  minSeverity,
+		CreateClosed: cfg.Processor.CreateClosed == "true",
+		Debug:        strings.EqualFold(cfg.Log.Level, "debug"),
+		BugIDFields:  bugIDFields,
+		DedupByTrace: cfg.Processor.DedupByTrace == "true",
+
+		DedupTTL: dedupTTL,
+
+		SeverityKeywords: severityKeywords,
+
+		BugIDLabelColorMode: bugIDLabelColorMode,
+
+		LatencyThresholdMs: latencyThreshold,
+
+		SampleRate:      sampleRate,
+		SampleThreshold: sampleThreshold,
+
+		DefaultLabels: cfg.Processor.DefaultLabels,
+		DefaultEnv:    cfg.Processor.DefaultEnv,
+		LabelFields:   cfg.Processor.LabelFromFields,
+
+		PriorityLabels: priorityLabels,
+		Milestone:      milestone,
+		MaxBodyBytes:   maxBodyBytes,
+		MaxTitleLength: maxTitleLength,
+
+		StacktraceLines: stackLines,
+		ContextLines:    contextLines,
+		BodySections:    bodySections,
+		RelatedIssues:   cfg.Processor.RelatedIssues == "true",
+		UserMap:         userMap,
+
+		TraceURLTemplate: traceURLTemplate,
+		LogsURLTemplate:  logsURLTemplate,
+
+		RateWindow: rateWindow,
+
+		NotifyMode:     notifyMode,
+		DigestInterval: digestInterval,
+		DigestSchedule: digestSchedule,
+		NotifyRoutes:   notifyRoutes,
+
+		NotifierMinSeverity: notifierMinSeverities(cfg, notifiers),
+
+		MaintenanceUntil: maintenanceUntil,
+		MaintenanceFile:  cfg.Notifiers.MaintenanceFile,
+
+		WatchdogTimeout: watchdogTimeout,
+
+		MetricAlerts: metricAlerts,
+
+		ResolveAfter: resolveAfter,
+		ResolveClose: cfg.Processor.ResolveClose == "true",
+
+		DeescalateAfter: deescalateAfter,
+
+		CloseCommentTemplate: closeCommentTemplate,
+
+		CommentMode:          commentMode,
+		SuppressOpenComments: cfg.Processor.CommentOnOpen == "false",
+
+		ReopenMode:      reopenMode,
+		ReopenThreshold: reopenThreshold,
+
+		StormThreshold: stormThreshold,
+		StormWindow:    stormWindow,
+
+		RecentSize: recentBufferSize(cfg),
+
+		IgnoreEndpoints: ignoreEndpoints,
+		IgnoreMessages:  ignoreMessages,
+
+		Query:       query,
+		LokiOptions: lokiOpts,
+		Cache:       bugCache,
+		Queue:       queue,
+
+		RepoRoutes:    repoRoutes,
+		UnroutedLabel: unroutedLabel,
 	}
 
-	return processor.NewProcessor(giteaClient, cfg, notifiers)
+	return processor.NewProcessor(tracker, procCfg, notifiers)
+}
+
+// parseMetricAlerts validates the metric_alerts config block
+func parseMetricAlerts(alerts []config.MetricAlert) []processor.MetricAlert {
+	var parsed []processor.MetricAlert
+	names := make(map[string]bool)
+	for i, a := range alerts {
+		name := strings.TrimSpace(a.Name)
+		if name == "" {
+			log.Fatalf("Invalid metric alert %d: name is required", i+1)
+		}
+		if names[name] {
+			log.Fatalf("Invalid metric alert %q: duplicate name", name)
+		}
+		names[name] = true
+		if strings.TrimSpace(a.Query) == "" {
+			log.Fatalf("Invalid metric alert %q: query is required", name)
+		}
+
+		var threshold float64
+		if a.Threshold != "" {
+			t, err := strconv.ParseFloat(a.Threshold, 64)
+			if err != nil {
+				log.Fatalf("Invalid metric alert %q: threshold %q (expected a number)", name, a.Threshold)
+			}
+			threshold = t
+		}
+
+		severity := processor.SeverityError
+		if a.Severity != "" {
+			s, err := processor.ParseSeverity(a.Severity)
+			if err != nil {
+				log.Fatalf("Invalid metric alert %q: %v", name, err)
+			}
+			severity = s
+		}
+
+		parsed = append(parsed, processor.MetricAlert{
+			Name:      name,
+			Query:     strings.TrimSpace(a.Query),
+			Threshold: threshold,
+			Severity:  severity,
+		})
+	}
+	return parsed
 }
@@ -0,0 +1,49 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Compactable is implemented by notifiers that can send issue notifications
+// as a single line
+type Compactable interface {
+	SetCompact(compact bool)
+}
+
+// compactable is embedded by notifiers to implement Compactable
+type compactable struct {
+	compact bool
+}
+
+// SetCompact sets whether issue notifications are sent as a single line
+func (c *compactable) SetCompact(compact bool) {
+	c.compact = compact
+}
+
+// compactLine renders an issue notification as a single line, e.g.
+// "🔴 #123 [500] POST /orders — bug abc123". event is "" for new issues, or
+// e.g. "reopened". Issues without a request are described by their title.
+func compactLine(emoji, event string, issue *IssueInfo) string {
+	parts := []string{fmt.Sprintf("#%d", issue.Number)}
+	if event != "" {
+		parts = append(parts, event)
+	}
+	if issue.HTTPMethod != "" && issue.Endpoint != "" {
+		if issue.StatusCode > 0 {
+			parts = append(parts, fmt.Sprintf("[%d]", issue.StatusCode))
+		}
+		parts = append(parts, issue.HTTPMethod, issue.Endpoint)
+	} else {
+		parts = append(parts, issue.Title)
+	}
+
+	line := strings.Join(parts, " ")
+	if issue.BugID != "" {
+		line += " — bug " + issue.BugID
+	}
+	if issue.Occurrences > 1 {
+		line += fmt.Sprintf(" (%d occurrences)", issue.Occurrences)
+	}
+	return withEmoji(emoji, line)
+}
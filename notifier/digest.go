@@ -0,0 +1,98 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+)
+
+// DigestSummary summarizes issue activity over a period, for the scheduled
+// digest
+type DigestSummary struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	NewIssues      []*IssueInfo `json:"new_issues,omitempty"`
+	ReopenedIssues []*IssueInfo `json:"reopened_issues,omitempty"`
+	// TopRecurring are the issues with the most occurrences in the period
+	// (NewOccurrences), most first
+	TopRecurring []*IssueInfo `json:"top_recurring,omitempty"`
+
+	TotalOccurrences int `json:"total_occurrences"`
+}
+
+// DigestNotifier is implemented by notifiers that can send the scheduled
+// digest. Notifiers that don't implement it skip digests.
+type DigestNotifier interface {
+	NotifyDigest(summary *DigestSummary) error
+}
+
+// SendDigest sends the scheduled digest through n, or does nothing if n
+// doesn't support digests
+func SendDigest(n Notifier, summary *DigestSummary) error {
+	if d, ok := n.(DigestNotifier); ok {
+		return d.NotifyDigest(summary)
+	}
+	return nil
+}
+
+// maxDigestItems limits the issues listed per digest section
+const maxDigestItems = 10
+
+// digestSection is a titled list of issues in a digest
+type digestSection struct {
+	Title  string
+	Issues []*IssueInfo
+	More   int  // issues left out of the list
+	Counts bool // show the occurrences in the period
+}
+
+// digestSections returns the non-empty sections of a digest, each listing
+// at most maxDigestItems issues
+func digestSections(summary *DigestSummary) []digestSection {
+	var sections []digestSection
+	add := func(title string, issues []*IssueInfo, counts bool) {
+		if len(issues) == 0 {
+			return
+		}
+		section := digestSection{Title: fmt.Sprintf("%s (%d)", title, len(issues)), Issues: issues, Counts: counts}
+		if len(issues) > maxDigestItems {
+			section.Issues, section.More = issues[:maxDigestItems], len(issues)-maxDigestItems
+		}
+		sections = append(sections, section)
+	}
+
+	add("New issues", summary.NewIssues, false)
+	add("Reopened issues", summary.ReopenedIssues, false)
+	if len(summary.TopRecurring) > 0 {
+		sections = append(sections, digestSection{Title: "Top recurring errors", Issues: summary.TopRecurring, Counts: true})
+	}
+	return sections
+}
+
+// digestTitle names the digest by its period
+func digestTitle(summary *DigestSummary) string {
+	const layout = "Jan 2 15:04"
+	return fmt.Sprintf("Error digest %s – %s", summary.From.Format(layout), summary.To.Format(layout))
+}
+
+// digestTotals describes the overall activity in a digest
+func digestTotals(summary *DigestSummary) string {
+	return fmt.Sprintf("%d new, %d reopened, %d occurrence(s) in total",
+		len(summary.NewIssues), len(summary.ReopenedIssues), summary.TotalOccurrences)
+}
+
+// digestItem describes an issue in a digest section, after its name
+func digestItem(issue *IssueInfo, counts bool) string {
+	if counts {
+		return fmt.Sprintf(": %d occurrence(s)", issue.NewOccurrences)
+	}
+	if issue.Service != "" {
+		return " (" + issue.Service + ")"
+	}
+	return ""
+}
+
+// digestMore describes the issues left out of a section
+func digestMore(section digestSection) string {
+	return fmt.Sprintf("…and %d more", section.More)
+}
@@ -5,19 +5,59 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 	"time"
+
+	"vigil/transport"
 )
 
 // DiscordNotifier sends notifications to Discord via webhook
 type DiscordNotifier struct {
+	themed
+	compactable
 	webhookURL string
+	mention    string
 	httpClient *http.Client
+
+	// Forum mode: each bug ID gets its own thread in the forum channel
+	forumURL  string
+	threadsMu sync.Mutex
+	threads   map[string]string // bug ID -> thread ID
 }
 
+// DiscordOption configures a DiscordNotifier
+type DiscordOption func(*DiscordNotifier)
+
+// WithDiscordMention sets a mention (e.g. "@here" or "<@&role-id>") sent as
+// the content of new and reopened critical issues so they notify
+func WithDiscordMention(mention string) DiscordOption {
+	return func(d *DiscordNotifier) {
+		d.mention = mention
+	}
+}
+
+// WithDiscordForum posts each new issue as a post (thread) of its own in a
+// forum channel, through a webhook of that channel. Reopened and resolved
+// notifications for the same bug ID go to the same thread; summaries still
+// go to the main webhook. Thread IDs are kept in memory, so after a restart
+// an issue gets a new thread.
+func WithDiscordForum(webhookURL string) DiscordOption {
+	return func(d *DiscordNotifier) {
+		d.forumURL = webhookURL
+		d.threads = make(map[string]string)
+	}
+}
+
+// maxThreadNameLength is the longest thread name Discord accepts
+const maxThreadNameLength = 100
+
 // DiscordMessage represents a Discord webhook message
 type DiscordMessage struct {
-	Content string         `json:"content,omitempty"`
-	Embeds  []DiscordEmbed `json:"embeds,omitempty"`
+	Content    string         `json:"content,omitempty"`
+	Embeds     []DiscordEmbed `json:"embeds,omitempty"`
+	ThreadName string         `json:"thread_name,omitempty"` // starts a forum post
 }
 
 // DiscordEmbed represents a Discord embed
@@ -45,26 +85,50 @@ type DiscordEmbedFooter struct {
 }
 
 // NewDiscordNotifier creates a new Discord notifier
-func NewDiscordNotifier(webhookURL string) *DiscordNotifier {
-	return &DiscordNotifier{
+func NewDiscordNotifier(webhookURL string, opts ...DiscordOption) *DiscordNotifier {
+	d := &DiscordNotifier{
 		webhookURL: webhookURL,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport.Wrap(nil)},
 	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
 }
 
 // NotifyNewIssue sends a notification for a new issue
 func (d *DiscordNotifier) NotifyNewIssue(issue *IssueInfo) error {
+	if d.compact {
+		return d.sendIssue(issue, d.compactMessage(issue, compactLine(d.theme.emoji(issue.Severity, emojiNewIssue), "", issue)))
+	}
+
+	fields := []DiscordEmbedField{
+		{Name: "Bug ID", Value: issue.BugID, Inline: true},
+		{Name: "Status Code", Value: fmt.Sprintf("%d", issue.StatusCode), Inline: true},
+		{Name: "Endpoint", Value: fmt.Sprintf("%s %s", issue.HTTPMethod, issue.Endpoint), Inline: false},
+	}
+	if issue.Service != "" {
+		fields = append(fields, DiscordEmbedField{Name: "Service", Value: issue.Service, Inline: true})
+	}
+	if issue.Environment != "" {
+		fields = append(fields, DiscordEmbedField{Name: "Environment", Value: issue.Environment, Inline: true})
+	}
+	if issue.Category != "" {
+		fields = append(fields, DiscordEmbedField{Name: "Category", Value: issue.Category, Inline: true})
+	}
+	if links := markdownLinks(issue); links != "" {
+		fields = append(fields, DiscordEmbedField{Name: "Links", Value: links, Inline: false})
+	}
+
 	msg := DiscordMessage{
+		Content: d.mentionFor(issue),
 		Embeds: []DiscordEmbed{
 			{
-				Title:     fmt.Sprintf("New Issue #%d: %s", issue.Number, issue.Title),
-				Color:     0xff0000, // red
+				Title:     withEmoji(d.theme.emoji(issue.Severity, ""), fmt.Sprintf("New Issue #%d: %s", issue.Number, issue.Title)),
+				URL:       issue.URL,
+				Color:     colorInt(d.theme.color(issue.Severity, colorNewIssue)),
 				Timestamp: issue.FirstSeen.Format(time.RFC3339),
-				Fields: []DiscordEmbedField{
-					{Name: "Bug ID", Value: issue.BugID, Inline: true},
-					{Name: "Status Code", Value: fmt.Sprintf("%d", issue.StatusCode), Inline: true},
-					{Name: "Endpoint", Value: fmt.Sprintf("%s %s", issue.HTTPMethod, issue.Endpoint), Inline: false},
-				},
+				Fields:    fields,
 				Footer: &DiscordEmbedFooter{
 					Text: "Issue Tracker → Gitea",
 				},
@@ -72,17 +136,87 @@ func (d *DiscordNotifier) NotifyNewIssue(issue *IssueInfo) error {
 		},
 	}
 
-	return d.send(msg)
+	return d.sendIssue(issue, msg)
 }
 
 // NotifyReopenedIssue sends a notification for a reopened issue
 func (d *DiscordNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	if d.compact {
+		return d.sendIssue(issue, d.compactMessage(issue, compactLine(d.theme.emoji(issue.Severity, emojiReopenedIssue), "reopened", issue)))
+	}
+
 	msg := DiscordMessage{
+		Content: d.mentionFor(issue),
 		Embeds: []DiscordEmbed{
 			{
-				Title:       fmt.Sprintf("Reopened Issue #%d: %s", issue.Number, issue.Title),
+				Title:       withEmoji(d.theme.emoji(issue.Severity, ""), fmt.Sprintf("Reopened Issue #%d: %s", issue.Number, issue.Title)),
 				Description: fmt.Sprintf("This issue has been reopened. Total occurrences: %d", issue.Occurrences),
-				Color:       0xff9900, // orange
+				Color:       colorInt(d.theme.color(issue.Severity, colorReopenedIssue)),
+				Timestamp:   time.Now().Format(time.RFC3339),
+				Footer: &DiscordEmbedFooter{
+					Text: "Issue Tracker → Gitea",
+				},
+			},
+		},
+	}
+
+	return d.sendIssue(issue, msg)
+}
+
+// compactMessage builds a single-line message, led by the mention for
+// critical issues
+func (d *DiscordNotifier) compactMessage(issue *IssueInfo, line string) DiscordMessage {
+	if mention := d.mentionFor(issue); mention != "" {
+		line = mention + " " + line
+	}
+	return DiscordMessage{Content: line}
+}
+
+// mentionFor returns the configured mention for critical issues, or nothing
+func (d *DiscordNotifier) mentionFor(issue *IssueInfo) string {
+	if issue.Severity != SeverityCritical {
+		return ""
+	}
+	return d.mention
+}
+
+// NotifyResolvedIssue sends a notification for an issue that has gone quiet
+func (d *DiscordNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	if d.compact {
+		return d.sendIssue(issue, DiscordMessage{Content: compactLine(emojiResolvedIssue, "resolved", issue)})
+	}
+
+	msg := DiscordMessage{
+		Embeds: []DiscordEmbed{
+			{
+				Title:       fmt.Sprintf("Resolved Issue #%d: %s", issue.Number, issue.Title),
+				URL:         issue.URL,
+				Description: resolvedLine(issue),
+				Color:       colorInt(colorResolvedIssue),
+				Timestamp:   time.Now().Format(time.RFC3339),
+				Footer: &DiscordEmbedFooter{
+					Text: "Issue Tracker → Gitea",
+				},
+			},
+		},
+	}
+
+	return d.sendIssue(issue, msg)
+}
+
+// NotifySummary sends a digest of activity on existing issues
+func (d *DiscordNotifier) NotifySummary(issues []*IssueInfo) error {
+	var description strings.Builder
+	for _, issue := range issues {
+		description.WriteString(fmt.Sprintf("• **#%d** %s: %s\n", issue.Number, issue.Title, summaryLine(issue)))
+	}
+
+	msg := DiscordMessage{
+		Embeds: []DiscordEmbed{
+			{
+				Title:       fmt.Sprintf("Activity on %d issue(s)", len(issues)),
+				Description: description.String(),
+				Color:       colorInt(colorReopenedIssue),
 				Timestamp:   time.Now().Format(time.RFC3339),
 				Footer: &DiscordEmbedFooter{
 					Text: "Issue Tracker → Gitea",
@@ -94,6 +228,50 @@ func (d *DiscordNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
 	return d.send(msg)
 }
 
+// NotifyDigest sends the scheduled digest
+func (d *DiscordNotifier) NotifyDigest(summary *DigestSummary) error {
+	var fields []DiscordEmbedField
+	for _, section := range digestSections(summary) {
+		var value strings.Builder
+		for _, issue := range section.Issues {
+			name := fmt.Sprintf("#%d %s", issue.Number, issue.Title)
+			if issue.URL != "" {
+				name = fmt.Sprintf("[%s](%s)", name, issue.URL)
+			}
+			value.WriteString(fmt.Sprintf("• %s%s\n", name, digestItem(issue, section.Counts)))
+		}
+		if section.More > 0 {
+			value.WriteString(digestMore(section))
+		}
+		fields = append(fields, DiscordEmbedField{Name: section.Title, Value: truncateField(value.String())})
+	}
+
+	return d.send(DiscordMessage{
+		Embeds: []DiscordEmbed{
+			{
+				Title:       digestTitle(summary),
+				Description: digestTotals(summary),
+				Timestamp:   summary.To.Format(time.RFC3339),
+				Fields:      fields,
+				Footer: &DiscordEmbedFooter{
+					Text: "Issue Tracker → Gitea",
+				},
+			},
+		},
+	})
+}
+
+// maxFieldLength is the longest embed field value Discord accepts
+const maxFieldLength = 1024
+
+// truncateField cuts an embed field value to Discord's limit
+func truncateField(value string) string {
+	if runes := []rune(value); len(runes) > maxFieldLength {
+		return string(runes[:maxFieldLength-1]) + "…"
+	}
+	return value
+}
+
 // Name returns the name of this notifier
 func (d *DiscordNotifier) Name() string {
 	return "discord"
@@ -101,12 +279,87 @@ func (d *DiscordNotifier) Name() string {
 
 // send posts a message to the Discord webhook
 func (d *DiscordNotifier) send(msg DiscordMessage) error {
+	return d.post(d.webhookURL, msg, nil)
+}
+
+// sendIssue posts a message about an issue. In forum mode it goes to the
+// thread of the issue's bug ID, which is started if there is none yet or the
+// known one can't be posted to anymore.
+func (d *DiscordNotifier) sendIssue(issue *IssueInfo, msg DiscordMessage) error {
+	if d.forumURL == "" || issue.BugID == "" {
+		return d.send(msg)
+	}
+
+	var threadErr error
+	if threadID := d.thread(issue.BugID); threadID != "" {
+		if threadErr = d.post(withQuery(d.forumURL, "thread_id", threadID), msg, nil); threadErr == nil {
+			return nil
+		}
+		d.setThread(issue.BugID, "")
+	}
+
+	msg.ThreadName = threadName(issue)
+	var created struct {
+		ChannelID string `json:"channel_id"`
+	}
+	if err := d.post(withQuery(d.forumURL, "wait", "true"), msg, &created); err != nil {
+		if threadErr != nil {
+			return fmt.Errorf("failed to post to the existing thread (%v) and to start a new one: %w", threadErr, err)
+		}
+		return err
+	}
+	d.setThread(issue.BugID, created.ChannelID)
+	return nil
+}
+
+// thread returns the thread ID of a bug ID, or "" if it has none
+func (d *DiscordNotifier) thread(bugID string) string {
+	d.threadsMu.Lock()
+	defer d.threadsMu.Unlock()
+	return d.threads[bugID]
+}
+
+// setThread records the thread of a bug ID; an empty ID forgets it
+func (d *DiscordNotifier) setThread(bugID, threadID string) {
+	d.threadsMu.Lock()
+	defer d.threadsMu.Unlock()
+	if threadID == "" {
+		delete(d.threads, bugID)
+		return
+	}
+	d.threads[bugID] = threadID
+}
+
+// threadName names the forum post of an issue
+func threadName(issue *IssueInfo) string {
+	name := []rune(fmt.Sprintf("#%d %s", issue.Number, issue.Title))
+	if len(name) > maxThreadNameLength {
+		name = append(name[:maxThreadNameLength-1], '…')
+	}
+	return string(name)
+}
+
+// withQuery adds a query parameter to a webhook URL
+func withQuery(rawURL, key, value string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// post sends a message to a webhook URL and decodes the response into out,
+// if given
+func (d *DiscordNotifier) post(webhookURL string, msg DiscordMessage, out interface{}) error {
 	body, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Discord message: %w", err)
 	}
 
-	resp, err := d.httpClient.Post(d.webhookURL, "application/json", bytes.NewReader(body))
+	resp, err := d.httpClient.Post(webhookURL, "application/json", bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("failed to send Discord notification: %w", err)
 	}
@@ -116,5 +369,11 @@ func (d *DiscordNotifier) send(msg DiscordMessage) error {
 		return fmt.Errorf("Discord webhook returned status %d", resp.StatusCode)
 	}
 
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode Discord response: %w", err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,220 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"vigil/transport"
+)
+
+// MattermostNotifier sends notifications to Mattermost via incoming webhook
+type MattermostNotifier struct {
+	themed
+	compactable
+	webhookURL string
+	channel    string
+	username   string
+	httpClient *http.Client
+}
+
+// MattermostMessage represents a Mattermost incoming webhook payload
+type MattermostMessage struct {
+	Text        string                 `json:"text,omitempty"`
+	Channel     string                 `json:"channel,omitempty"`
+	Username    string                 `json:"username,omitempty"`
+	Attachments []MattermostAttachment `json:"attachments,omitempty"`
+}
+
+// MattermostAttachment represents a Mattermost message attachment
+type MattermostAttachment struct {
+	Fallback  string            `json:"fallback,omitempty"`
+	Color     string            `json:"color,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	TitleLink string            `json:"title_link,omitempty"`
+	Text      string            `json:"text,omitempty"`
+	Fields    []MattermostField `json:"fields,omitempty"`
+	Footer    string            `json:"footer,omitempty"`
+}
+
+// MattermostField represents a field in a Mattermost attachment
+type MattermostField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// NewMattermostNotifier creates a new Mattermost notifier. The channel and
+// username override the webhook defaults when non-empty.
+func NewMattermostNotifier(webhookURL, channel, username string) *MattermostNotifier {
+	return &MattermostNotifier{
+		webhookURL: webhookURL,
+		channel:    channel,
+		username:   username,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport.Wrap(nil)},
+	}
+}
+
+// NotifyNewIssue sends a notification for a new issue
+func (m *MattermostNotifier) NotifyNewIssue(issue *IssueInfo) error {
+	if m.compact {
+		return m.sendText(compactLine(m.theme.emoji(issue.Severity, emojiNewIssue), "", issue))
+	}
+
+	title := withEmoji(m.theme.emoji(issue.Severity, ""), fmt.Sprintf("New Issue #%d: %s", issue.Number, issue.Title))
+
+	fields := []MattermostField{
+		{Title: "Bug ID", Value: fmt.Sprintf("`%s`", issue.BugID), Short: true},
+		{Title: "Status Code", Value: fmt.Sprintf("%d", issue.StatusCode), Short: true},
+		{Title: "Endpoint", Value: fmt.Sprintf("`%s %s`", issue.HTTPMethod, issue.Endpoint), Short: false},
+	}
+	if issue.Service != "" {
+		fields = append(fields, MattermostField{Title: "Service", Value: issue.Service, Short: true})
+	}
+	if issue.Environment != "" {
+		fields = append(fields, MattermostField{Title: "Environment", Value: issue.Environment, Short: true})
+	}
+	if issue.Category != "" {
+		fields = append(fields, MattermostField{Title: "Category", Value: issue.Category, Short: true})
+	}
+	if links := markdownLinks(issue); links != "" {
+		fields = append(fields, MattermostField{Title: "Links", Value: links, Short: false})
+	}
+
+	return m.send(MattermostAttachment{
+		Fallback:  title,
+		Color:     m.theme.color(issue.Severity, colorNewIssue),
+		Title:     title,
+		TitleLink: issue.URL,
+		Fields:    fields,
+		Footer:    "Issue Tracker → Gitea",
+	})
+}
+
+// NotifyReopenedIssue sends a notification for a reopened issue
+func (m *MattermostNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	if m.compact {
+		return m.sendText(compactLine(m.theme.emoji(issue.Severity, emojiReopenedIssue), "reopened", issue))
+	}
+
+	title := withEmoji(m.theme.emoji(issue.Severity, ""), fmt.Sprintf("Reopened Issue #%d: %s", issue.Number, issue.Title))
+
+	return m.send(MattermostAttachment{
+		Fallback:  title,
+		Color:     m.theme.color(issue.Severity, colorReopenedIssue),
+		Title:     title,
+		TitleLink: issue.URL,
+		Text:      fmt.Sprintf("This issue has been reopened. Total occurrences: %d", issue.Occurrences),
+		Footer:    "Issue Tracker → Gitea",
+	})
+}
+
+// NotifyResolvedIssue sends a notification for an issue that has gone quiet
+func (m *MattermostNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	if m.compact {
+		return m.sendText(compactLine(emojiResolvedIssue, "resolved", issue))
+	}
+
+	title := fmt.Sprintf("Resolved Issue #%d: %s", issue.Number, issue.Title)
+
+	return m.send(MattermostAttachment{
+		Fallback:  title,
+		Color:     colorResolvedIssue,
+		Title:     title,
+		TitleLink: issue.URL,
+		Text:      resolvedLine(issue),
+		Footer:    "Issue Tracker → Gitea",
+	})
+}
+
+// NotifySummary sends a digest of activity on existing issues
+func (m *MattermostNotifier) NotifySummary(issues []*IssueInfo) error {
+	title := fmt.Sprintf("Activity on %d issue(s)", len(issues))
+
+	var text strings.Builder
+	for _, issue := range issues {
+		name := fmt.Sprintf("#%d %s", issue.Number, issue.Title)
+		if issue.URL != "" {
+			name = fmt.Sprintf("[%s](%s)", name, issue.URL)
+		}
+		text.WriteString(fmt.Sprintf("- %s: %s\n", name, summaryLine(issue)))
+	}
+
+	return m.send(MattermostAttachment{
+		Fallback: title,
+		Color:    colorReopenedIssue, // recurring activity
+		Title:    title,
+		Text:     text.String(),
+		Footer:   "Issue Tracker → Gitea",
+	})
+}
+
+// NotifyDigest sends the scheduled digest
+func (m *MattermostNotifier) NotifyDigest(summary *DigestSummary) error {
+	title := digestTitle(summary)
+
+	var text strings.Builder
+	text.WriteString(digestTotals(summary) + "\n")
+	for _, section := range digestSections(summary) {
+		text.WriteString(fmt.Sprintf("\n**%s**\n", section.Title))
+		for _, issue := range section.Issues {
+			name := fmt.Sprintf("#%d %s", issue.Number, issue.Title)
+			if issue.URL != "" {
+				name = fmt.Sprintf("[%s](%s)", name, issue.URL)
+			}
+			text.WriteString(fmt.Sprintf("- %s%s\n", name, digestItem(issue, section.Counts)))
+		}
+		if section.More > 0 {
+			text.WriteString(digestMore(section) + "\n")
+		}
+	}
+
+	return m.send(MattermostAttachment{
+		Fallback: title,
+		Title:    title,
+		Text:     text.String(),
+		Footer:   "Issue Tracker → Gitea",
+	})
+}
+
+// Name returns the name of this notifier
+func (m *MattermostNotifier) Name() string {
+	return "mattermost"
+}
+
+// send posts an attachment to the Mattermost webhook
+func (m *MattermostNotifier) send(attachment MattermostAttachment) error {
+	return m.post(MattermostMessage{Attachments: []MattermostAttachment{attachment}})
+}
+
+// sendText posts a plain text message to the Mattermost webhook
+func (m *MattermostNotifier) sendText(text string) error {
+	return m.post(MattermostMessage{Text: text})
+}
+
+// post posts a message to the Mattermost webhook, in the configured channel
+// and under the configured username
+func (m *MattermostNotifier) post(msg MattermostMessage) error {
+	msg.Channel = m.channel
+	msg.Username = m.username
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal Mattermost message: %w", err)
+	}
+
+	resp, err := m.httpClient.Post(m.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to send Mattermost notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Mattermost webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -1,26 +1,63 @@
 package notifier
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // IssueInfo contains information about an issue for notifications
 type IssueInfo struct {
-	Number      int64
-	Title       string
-	BugID       string
-	Endpoint    string
-	HTTPMethod  string
-	StatusCode  int
-	FirstSeen   time.Time
-	Occurrences int
+	Number      int64     `json:"number"`
+	Title       string    `json:"title"`
+	URL         string    `json:"url,omitempty"`       // link to the issue in Gitea, if known
+	TraceURL    string    `json:"trace_url,omitempty"` // link to the trace, if configured
+	LogsURL     string    `json:"logs_url,omitempty"`  // link to the request's logs, if configured
+	BugID       string    `json:"bug_id,omitempty"`
+	Service     string    `json:"service,omitempty"`     // name of the service that logged the error, if known
+	Environment string    `json:"environment,omitempty"` // deployment environment, e.g. production
+	Severity    string    `json:"severity,omitempty"`    // used to look up the notifier theme
+	Category    string    `json:"category,omitempty"`    // error category: client_error, server_error, panic or app_error
+	Labels      []string  `json:"labels,omitempty"`      // issue labels, used to route notifications to channels
+	Endpoint    string    `json:"endpoint,omitempty"`
+	HTTPMethod  string    `json:"http_method,omitempty"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	FirstSeen   time.Time `json:"first_seen,omitempty"`
+	Occurrences int       `json:"occurrences,omitempty"`
+
+	// Digest fields: activity accumulated since the last summary
+	NewOccurrences int  `json:"new_occurrences,omitempty"`
+	Reopened       bool `json:"reopened,omitempty"`
+
+	// Resolution fields: how long the issue has been quiet and whether it was closed
+	QuietFor time.Duration `json:"quiet_for_ns,omitempty"`
+	Closed   bool          `json:"closed,omitempty"`
 }
 
 // Notifier is the interface for sending notifications
 type Notifier interface {
 	NotifyNewIssue(issue *IssueInfo) error
 	NotifyReopenedIssue(issue *IssueInfo) error
+	NotifyResolvedIssue(issue *IssueInfo) error
+	NotifySummary(issues []*IssueInfo) error
 	Name() string
 }
 
+// OccurrenceNotifier is implemented by notifiers that keep a message per
+// issue up to date, e.g. Slack with a bot token
+type OccurrenceNotifier interface {
+	NotifyOccurrence(issue *IssueInfo) error
+}
+
+// SendOccurrence updates the message of an issue with a new occurrence
+// through n, or does nothing if n doesn't support it
+func SendOccurrence(n Notifier, issue *IssueInfo) error {
+	if o, ok := n.(OccurrenceNotifier); ok {
+		return o.NotifyOccurrence(issue)
+	}
+	return nil
+}
+
 // MultiNotifier sends notifications to multiple notifiers
 type MultiNotifier struct {
 	notifiers []Notifier
@@ -53,7 +90,70 @@ func (m *MultiNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
 	return lastErr
 }
 
+// NotifyResolvedIssue sends a resolved issue notification to all notifiers
+func (m *MultiNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	var lastErr error
+	for _, n := range m.notifiers {
+		if err := n.NotifyResolvedIssue(issue); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// NotifySummary sends an activity summary to all notifiers
+func (m *MultiNotifier) NotifySummary(issues []*IssueInfo) error {
+	var lastErr error
+	for _, n := range m.notifiers {
+		if err := n.NotifySummary(issues); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// NotifyDigest sends the scheduled digest to all notifiers that support it
+func (m *MultiNotifier) NotifyDigest(summary *DigestSummary) error {
+	var lastErr error
+	for _, n := range m.notifiers {
+		if err := SendDigest(n, summary); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
 // Name returns the name of this notifier
 func (m *MultiNotifier) Name() string {
 	return "multi"
 }
+
+// summaryLine describes an issue's accumulated activity in a digest
+func summaryLine(issue *IssueInfo) string {
+	line := fmt.Sprintf("%d new occurrence(s), %d total", issue.NewOccurrences, issue.Occurrences)
+	if issue.Reopened {
+		line += ", reopened"
+	}
+	return line
+}
+
+// resolvedLine describes why an issue was resolved
+func resolvedLine(issue *IssueInfo) string {
+	line := fmt.Sprintf("No occurrences for %s. Total occurrences: %d", issue.QuietFor, issue.Occurrences)
+	if issue.Closed {
+		line += ". The issue has been closed."
+	}
+	return line
+}
+
+// markdownLinks renders the trace and logs links as Markdown, or an empty string
+func markdownLinks(issue *IssueInfo) string {
+	var links []string
+	if issue.TraceURL != "" {
+		links = append(links, fmt.Sprintf("[View trace](%s)", issue.TraceURL))
+	}
+	if issue.LogsURL != "" {
+		links = append(links, fmt.Sprintf("[View logs](%s)", issue.LogsURL))
+	}
+	return strings.Join(links, " · ")
+}
@@ -0,0 +1,136 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"vigil/transport"
+)
+
+// pushoverAPIURL is the Pushover message endpoint
+const pushoverAPIURL = "https://api.pushover.net/1/messages.json"
+
+// Pushover priorities
+const (
+	pushoverPriorityQuiet     = -1
+	pushoverPriorityNormal    = 0
+	pushoverPriorityHigh      = 1
+	pushoverPriorityEmergency = 2
+)
+
+// Emergency messages repeat every pushoverRetry until acknowledged or
+// pushoverExpire has passed
+const (
+	pushoverRetry  = 60 * time.Second
+	pushoverExpire = time.Hour
+)
+
+// PushoverNotifier sends mobile push notifications via Pushover
+type PushoverNotifier struct {
+	token      string
+	user       string
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewPushoverNotifier creates a new Pushover notifier for an application
+// token and a user or group key
+func NewPushoverNotifier(token, user string) *PushoverNotifier {
+	return &PushoverNotifier{
+		token:      token,
+		user:       user,
+		apiURL:     pushoverAPIURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport.Wrap(nil)},
+	}
+}
+
+// NotifyNewIssue sends a push for a new issue
+func (p *PushoverNotifier) NotifyNewIssue(issue *IssueInfo) error {
+	return p.send("New Issue", issue, pushoverPriority(issue.Severity))
+}
+
+// NotifyReopenedIssue sends a push for a reopened issue
+func (p *PushoverNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	return p.send("Issue Reopened", issue, pushoverPriority(issue.Severity))
+}
+
+// NotifyResolvedIssue sends a quiet push for a resolved issue
+func (p *PushoverNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	return p.send("Issue Resolved", issue, pushoverPriorityQuiet)
+}
+
+// NotifySummary does nothing; digests are not pushed to phones
+func (p *PushoverNotifier) NotifySummary(issues []*IssueInfo) error {
+	return nil
+}
+
+// Name returns the name of this notifier
+func (p *PushoverNotifier) Name() string {
+	return "pushover"
+}
+
+// pushoverPriority maps an issue severity to a Pushover priority
+func pushoverPriority(severity string) int {
+	switch severity {
+	case SeverityCritical:
+		return pushoverPriorityEmergency
+	case SeverityError:
+		return pushoverPriorityHigh
+	default:
+		return pushoverPriorityNormal
+	}
+}
+
+// send posts a message about an issue with a link to it
+func (p *PushoverNotifier) send(event string, issue *IssueInfo, priority int) error {
+	message := issue.Title
+	if issue.StatusCode > 0 {
+		message += fmt.Sprintf(" (status %d)", issue.StatusCode)
+	}
+	if issue.Service != "" {
+		message += "\nService: " + issue.Service
+	}
+	if issue.Environment != "" {
+		message += "\nEnvironment: " + issue.Environment
+	}
+	if issue.Category != "" {
+		message += "\nCategory: " + issue.Category
+	}
+
+	form := url.Values{}
+	form.Set("token", p.token)
+	form.Set("user", p.user)
+	form.Set("title", fmt.Sprintf("%s #%d", event, issue.Number))
+	form.Set("message", message)
+	form.Set("priority", strconv.Itoa(priority))
+	if issue.URL != "" {
+		form.Set("url", issue.URL)
+		form.Set("url_title", fmt.Sprintf("Open issue #%d", issue.Number))
+	}
+	if priority == pushoverPriorityEmergency {
+		form.Set("retry", strconv.Itoa(int(pushoverRetry.Seconds())))
+		form.Set("expire", strconv.Itoa(int(pushoverExpire.Seconds())))
+	}
+
+	req, err := http.NewRequest("POST", p.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Pushover message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Pushover API returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,184 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChannelRoute is a parsed channel route: issues carrying Label are sent
+// to the webhook at URL
+type ChannelRoute struct {
+	Label string
+	URL   string
+}
+
+// ParseChannelRoutes parses a comma-separated list of label=webhook routes,
+// e.g. "service:billing=https://hooks.slack.com/...,team:infra=https://..."
+func ParseChannelRoutes(spec string) ([]ChannelRoute, error) {
+	var routes []ChannelRoute
+	seen := make(map[string]bool)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		label, url, ok := strings.Cut(item, "=")
+		label = strings.TrimSpace(label)
+		url = strings.TrimSpace(url)
+		if !ok || label == "" || url == "" {
+			return nil, fmt.Errorf("invalid route %q (expected label=webhook URL, e.g. service:billing=https://...)", item)
+		}
+		if seen[label] {
+			return nil, fmt.Errorf("duplicate route for %s", label)
+		}
+		seen[label] = true
+		routes = append(routes, ChannelRoute{Label: label, URL: url})
+	}
+
+	return routes, nil
+}
+
+// Route sends the issues carrying a label to a notifier
+type Route struct {
+	Label    string // an issue label, e.g. service:billing or team:payments
+	Notifier Notifier
+}
+
+// RoutedNotifier sends each issue to the notifiers of the routes matching
+// its labels (its service matches service:<name>), and issues matching no
+// route to the default notifier, e.g. one Slack channel per team.
+type RoutedNotifier struct {
+	fallback Notifier // nil to drop unrouted issues
+	routes   []Route
+}
+
+// NewRoutedNotifier creates a notifier routing issues by label. fallback may
+// be nil, in which case issues matching no route are not sent.
+func NewRoutedNotifier(fallback Notifier, routes []Route) *RoutedNotifier {
+	return &RoutedNotifier{fallback: fallback, routes: routes}
+}
+
+// targets returns the notifiers an issue is sent to
+func (r *RoutedNotifier) targets(issue *IssueInfo) []Notifier {
+	var targets []Notifier
+	for _, route := range r.routes {
+		if issue.hasLabel(route.Label) {
+			targets = append(targets, route.Notifier)
+		}
+	}
+	if len(targets) == 0 && r.fallback != nil {
+		targets = append(targets, r.fallback)
+	}
+	return targets
+}
+
+// send calls notify for each notifier the issue is routed to
+func (r *RoutedNotifier) send(issue *IssueInfo, notify func(n Notifier) error) error {
+	var lastErr error
+	for _, n := range r.targets(issue) {
+		if err := notify(n); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// NotifyNewIssue sends a new issue notification to the issue's channels
+func (r *RoutedNotifier) NotifyNewIssue(issue *IssueInfo) error {
+	return r.send(issue, func(n Notifier) error { return n.NotifyNewIssue(issue) })
+}
+
+// NotifyReopenedIssue sends a reopened issue notification to the issue's channels
+func (r *RoutedNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	return r.send(issue, func(n Notifier) error { return n.NotifyReopenedIssue(issue) })
+}
+
+// NotifyResolvedIssue sends a resolved issue notification to the issue's channels
+func (r *RoutedNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	return r.send(issue, func(n Notifier) error { return n.NotifyResolvedIssue(issue) })
+}
+
+// NotifyOccurrence updates the issue's message in the channels it was sent to
+func (r *RoutedNotifier) NotifyOccurrence(issue *IssueInfo) error {
+	return r.send(issue, func(n Notifier) error { return SendOccurrence(n, issue) })
+}
+
+// NotifySummary sends each channel a summary of the issues routed to it
+func (r *RoutedNotifier) NotifySummary(issues []*IssueInfo) error {
+	var order []Notifier
+	grouped := make(map[Notifier][]*IssueInfo)
+	for _, issue := range issues {
+		for _, n := range r.targets(issue) {
+			if _, ok := grouped[n]; !ok {
+				order = append(order, n)
+			}
+			grouped[n] = append(grouped[n], issue)
+		}
+	}
+
+	var lastErr error
+	for _, n := range order {
+		if err := n.NotifySummary(grouped[n]); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// NotifyDigest sends the scheduled digest to the default channel only, as
+// it covers all issues
+func (r *RoutedNotifier) NotifyDigest(summary *DigestSummary) error {
+	if r.fallback == nil {
+		return nil
+	}
+	return SendDigest(r.fallback, summary)
+}
+
+// SetTheme sets the severity theme of every channel
+func (r *RoutedNotifier) SetTheme(theme Theme) {
+	if t, ok := r.fallback.(Themeable); ok {
+		t.SetTheme(theme)
+	}
+	for _, route := range r.routes {
+		if t, ok := route.Notifier.(Themeable); ok {
+			t.SetTheme(theme)
+		}
+	}
+}
+
+// SetCompact sets whether every channel sends single-line notifications
+func (r *RoutedNotifier) SetCompact(compact bool) {
+	if c, ok := r.fallback.(Compactable); ok {
+		c.SetCompact(compact)
+	}
+	for _, route := range r.routes {
+		if c, ok := route.Notifier.(Compactable); ok {
+			c.SetCompact(compact)
+		}
+	}
+}
+
+// Name returns the name of the routed notifier, e.g. "slack", so severity
+// routes apply to all of its channels
+func (r *RoutedNotifier) Name() string {
+	if r.fallback != nil {
+		return r.fallback.Name()
+	}
+	return r.routes[0].Notifier.Name()
+}
+
+// hasLabel reports whether the issue carries a label. The service label is
+// derived from Service, as not every notification lists the labels.
+func (issue *IssueInfo) hasLabel(label string) bool {
+	if issue.Service != "" && label == "service:"+issue.Service {
+		return true
+	}
+	for _, l := range issue.Labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
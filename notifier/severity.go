@@ -0,0 +1,30 @@
+package notifier
+
+// Severity levels, ordered from least to most severe
+const (
+	SeverityWarning  = "warning"
+	SeverityError    = "error"
+	SeverityCritical = "critical"
+)
+
+// severityRanks orders severities for threshold comparisons
+var severityRanks = map[string]int{
+	SeverityWarning:  1,
+	SeverityError:    2,
+	SeverityCritical: 3,
+}
+
+// SeverityRank returns the rank of a severity, higher for more severe ones,
+// or 0 if it is unknown
+func SeverityRank(severity string) int {
+	return severityRanks[severity]
+}
+
+// MeetsSeverity reports whether severity is at or above the minimum.
+// An empty minimum accepts everything.
+func MeetsSeverity(severity, minimum string) bool {
+	if minimum == "" {
+		return true
+	}
+	return SeverityRank(severity) >= SeverityRank(minimum)
+}
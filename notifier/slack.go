@@ -5,18 +5,52 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
+
+	"vigil/transport"
 )
 
-// SlackNotifier sends notifications to Slack via webhook
+// SlackNotifier sends notifications to Slack via webhook, or with a bot
+// token through the Web API
 type SlackNotifier struct {
+	themed
+	compactable
 	webhookURL string
+	blockKit   bool
+	mention    string
 	httpClient *http.Client
+
+	// Bot mode: the message of each bug ID is updated and replied to
+	botToken string
+	channel  string
+	postsMu  sync.Mutex
+	posts    map[string]*slackPost // bug ID -> new issue message
+}
+
+// SlackOption configures a SlackNotifier
+type SlackOption func(*SlackNotifier)
+
+// WithBlockKit renders messages with Block Kit blocks instead of legacy attachments
+func WithBlockKit() SlackOption {
+	return func(s *SlackNotifier) {
+		s.blockKit = true
+	}
+}
+
+// WithSlackMention sets a mention (e.g. "<!here>" or "<!subteam^S123>")
+// sent with new and reopened critical issues so they notify
+func WithSlackMention(mention string) SlackOption {
+	return func(s *SlackNotifier) {
+		s.mention = mention
+	}
 }
 
 // SlackMessage represents a Slack webhook message
 type SlackMessage struct {
 	Text        string            `json:"text,omitempty"`
+	Blocks      []SlackBlock      `json:"blocks,omitempty"`
 	Attachments []SlackAttachment `json:"attachments,omitempty"`
 }
 
@@ -40,42 +74,160 @@ type SlackField struct {
 }
 
 // NewSlackNotifier creates a new Slack notifier
-func NewSlackNotifier(webhookURL string) *SlackNotifier {
-	return &SlackNotifier{
+func NewSlackNotifier(webhookURL string, opts ...SlackOption) *SlackNotifier {
+	s := &SlackNotifier{
 		webhookURL: webhookURL,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport.Wrap(nil)},
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // NotifyNewIssue sends a notification for a new issue
 func (s *SlackNotifier) NotifyNewIssue(issue *IssueInfo) error {
-	msg := SlackMessage{
+	return s.postIssue(issue, s.withMention(issue, s.newIssueMessage(issue)))
+}
+
+// newIssueMessage renders a new issue notification, listing the occurrences
+// once there are several
+func (s *SlackNotifier) newIssueMessage(issue *IssueInfo) SlackMessage {
+	if s.compact {
+		return SlackMessage{Text: compactLine(s.theme.emoji(issue.Severity, emojiNewIssue), "", issue)}
+	}
+	if s.blockKit {
+		return slackNewIssueBlocks(issue, s.theme.emoji(issue.Severity, emojiNewIssue))
+	}
+
+	fields := []SlackField{
+		{Title: "Bug ID", Value: issue.BugID, Short: true},
+		{Title: "Status Code", Value: fmt.Sprintf("%d", issue.StatusCode), Short: true},
+		{Title: "Endpoint", Value: fmt.Sprintf("%s %s", issue.HTTPMethod, issue.Endpoint), Short: false},
+	}
+	if issue.Service != "" {
+		fields = append(fields, SlackField{Title: "Service", Value: issue.Service, Short: true})
+	}
+	if issue.Environment != "" {
+		fields = append(fields, SlackField{Title: "Environment", Value: issue.Environment, Short: true})
+	}
+	if issue.Category != "" {
+		fields = append(fields, SlackField{Title: "Category", Value: issue.Category, Short: true})
+	}
+	if issue.Occurrences > 1 {
+		fields = append(fields, SlackField{Title: "Occurrences", Value: fmt.Sprintf("%d", issue.Occurrences), Short: true})
+	}
+	if links := slackLinks(issue); links != "" {
+		fields = append(fields, SlackField{Title: "Links", Value: links, Short: false})
+	}
+
+	return SlackMessage{
 		Attachments: []SlackAttachment{
 			{
-				Color: "#ff0000", // red for new issues
-				Title: fmt.Sprintf("New Issue #%d: %s", issue.Number, issue.Title),
-				Fields: []SlackField{
-					{Title: "Bug ID", Value: issue.BugID, Short: true},
-					{Title: "Status Code", Value: fmt.Sprintf("%d", issue.StatusCode), Short: true},
-					{Title: "Endpoint", Value: fmt.Sprintf("%s %s", issue.HTTPMethod, issue.Endpoint), Short: false},
-				},
+				Color:  s.theme.color(issue.Severity, colorNewIssue),
+				Title:  withEmoji(s.theme.emoji(issue.Severity, ""), fmt.Sprintf("New Issue #%d: %s", issue.Number, issue.Title)),
+				Fields: fields,
 				Footer: "Issue Tracker → Gitea",
 				Ts:     issue.FirstSeen.Unix(),
 			},
 		},
 	}
-
-	return s.send(msg)
 }
 
 // NotifyReopenedIssue sends a notification for a reopened issue
 func (s *SlackNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	if s.compact {
+		line := compactLine(s.theme.emoji(issue.Severity, emojiReopenedIssue), "reopened", issue)
+		return s.reply(issue, s.withMention(issue, SlackMessage{Text: line}), true)
+	}
+	if s.blockKit {
+		return s.reply(issue, s.withMention(issue, slackReopenedIssueBlocks(issue, s.theme.emoji(issue.Severity, emojiReopenedIssue))), true)
+	}
+
 	msg := SlackMessage{
 		Attachments: []SlackAttachment{
 			{
-				Color: "#ff9900", // orange for reopened
-				Title: fmt.Sprintf("Reopened Issue #%d: %s", issue.Number, issue.Title),
-				Text:  fmt.Sprintf("This issue has been reopened. Total occurrences: %d", issue.Occurrences),
+				Color:  s.theme.color(issue.Severity, colorReopenedIssue),
+				Title:  withEmoji(s.theme.emoji(issue.Severity, ""), fmt.Sprintf("Reopened Issue #%d: %s", issue.Number, issue.Title)),
+				Text:   fmt.Sprintf("This issue has been reopened. Total occurrences: %d", issue.Occurrences),
+				Footer: "Issue Tracker → Gitea",
+				Ts:     time.Now().Unix(),
+			},
+		},
+	}
+
+	return s.reply(issue, s.withMention(issue, msg), true)
+}
+
+// withMention adds the configured mention to a message about a critical
+// issue. Block Kit messages only use Text as a fallback, so the mention is
+// also shown in a section above the blocks.
+func (s *SlackNotifier) withMention(issue *IssueInfo, msg SlackMessage) SlackMessage {
+	if s.mention == "" || issue.Severity != SeverityCritical {
+		return msg
+	}
+
+	if len(msg.Blocks) > 0 {
+		msg.Text = s.mention + " " + msg.Text
+		section := SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: s.mention}}
+		msg.Blocks = append([]SlackBlock{section}, msg.Blocks...)
+		return msg
+	}
+
+	if msg.Text != "" {
+		msg.Text = s.mention + " " + msg.Text
+		return msg
+	}
+	msg.Text = s.mention
+	return msg
+}
+
+// NotifyResolvedIssue sends a notification for an issue that has gone quiet.
+// In bot mode the issue's message is forgotten afterwards, so a recurrence
+// is posted to the channel.
+func (s *SlackNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	defer s.setPost(issue.BugID, nil)
+
+	if s.compact {
+		return s.reply(issue, SlackMessage{Text: compactLine(emojiResolvedIssue, "resolved", issue)}, false)
+	}
+	if s.blockKit {
+		return s.reply(issue, slackResolvedIssueBlocks(issue), false)
+	}
+
+	msg := SlackMessage{
+		Attachments: []SlackAttachment{
+			{
+				Color:     colorResolvedIssue,
+				Title:     fmt.Sprintf("Resolved Issue #%d: %s", issue.Number, issue.Title),
+				TitleLink: issue.URL,
+				Text:      resolvedLine(issue),
+				Footer:    "Issue Tracker → Gitea",
+				Ts:        time.Now().Unix(),
+			},
+		},
+	}
+
+	return s.reply(issue, msg, false)
+}
+
+// NotifySummary sends a digest of activity on existing issues
+func (s *SlackNotifier) NotifySummary(issues []*IssueInfo) error {
+	if s.blockKit {
+		return s.send(slackSummaryBlocks(issues))
+	}
+
+	var text strings.Builder
+	for _, issue := range issues {
+		text.WriteString(fmt.Sprintf("• #%d %s: %s\n", issue.Number, issue.Title, summaryLine(issue)))
+	}
+
+	msg := SlackMessage{
+		Attachments: []SlackAttachment{
+			{
+				Color:  colorReopenedIssue, // recurring activity
+				Title:  fmt.Sprintf("Activity on %d issue(s)", len(issues)),
+				Text:   text.String(),
 				Footer: "Issue Tracker → Gitea",
 				Ts:     time.Now().Unix(),
 			},
@@ -85,13 +237,71 @@ func (s *SlackNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
 	return s.send(msg)
 }
 
+// NotifyDigest sends the scheduled digest
+func (s *SlackNotifier) NotifyDigest(summary *DigestSummary) error {
+	var text strings.Builder
+	text.WriteString(digestTotals(summary) + "\n")
+	for _, section := range digestSections(summary) {
+		text.WriteString(fmt.Sprintf("\n*%s*\n", section.Title))
+		for _, issue := range section.Issues {
+			name := fmt.Sprintf("#%d %s", issue.Number, issue.Title)
+			if issue.URL != "" {
+				name = fmt.Sprintf("<%s|%s>", issue.URL, name)
+			}
+			text.WriteString(fmt.Sprintf("• %s%s\n", name, digestItem(issue, section.Counts)))
+		}
+		if section.More > 0 {
+			text.WriteString(digestMore(section) + "\n")
+		}
+	}
+
+	title := digestTitle(summary)
+	if s.blockKit {
+		return s.send(SlackMessage{
+			Text: title,
+			Blocks: []SlackBlock{
+				slackHeader(title),
+				{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text.String()}},
+			},
+		})
+	}
+
+	return s.send(SlackMessage{
+		Attachments: []SlackAttachment{
+			{
+				Title:  title,
+				Text:   text.String(),
+				Footer: "Issue Tracker → Gitea",
+				Ts:     summary.To.Unix(),
+			},
+		},
+	})
+}
+
 // Name returns the name of this notifier
 func (s *SlackNotifier) Name() string {
 	return "slack"
 }
 
-// send posts a message to the Slack webhook
+// slackLinks renders the trace and logs links in Slack's link syntax
+func slackLinks(issue *IssueInfo) string {
+	var links []string
+	if issue.TraceURL != "" {
+		links = append(links, fmt.Sprintf("<%s|View trace>", issue.TraceURL))
+	}
+	if issue.LogsURL != "" {
+		links = append(links, fmt.Sprintf("<%s|View logs>", issue.LogsURL))
+	}
+	return strings.Join(links, " · ")
+}
+
+// send posts a message to the Slack webhook, or to the channel in bot mode
 func (s *SlackNotifier) send(msg SlackMessage) error {
+	if s.botToken != "" {
+		_, err := s.callAPI("chat.postMessage", slackAPIRequest{SlackMessage: msg, Channel: s.channel})
+		return err
+	}
+
 	body, err := json.Marshal(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal Slack message: %w", err)
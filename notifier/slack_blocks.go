@@ -0,0 +1,155 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SlackBlock represents a Slack Block Kit layout block
+type SlackBlock struct {
+	Type     string         `json:"type"`
+	Text     *SlackText     `json:"text,omitempty"`
+	Fields   []SlackText    `json:"fields,omitempty"`
+	Elements []SlackElement `json:"elements,omitempty"`
+}
+
+// SlackText represents a Block Kit text object
+type SlackText struct {
+	Type string `json:"type"` // "plain_text" or "mrkdwn"
+	Text string `json:"text"`
+}
+
+// SlackElement represents an interactive Block Kit element
+type SlackElement struct {
+	Type  string     `json:"type"`
+	Text  *SlackText `json:"text,omitempty"`
+	URL   string     `json:"url,omitempty"`
+	Style string     `json:"style,omitempty"`
+}
+
+// slackNewIssueBlocks renders a new issue notification as Block Kit blocks
+func slackNewIssueBlocks(issue *IssueInfo, emoji string) SlackMessage {
+	title := fmt.Sprintf("New Issue #%d: %s", issue.Number, issue.Title)
+
+	fields := []SlackText{
+		{Type: "mrkdwn", Text: fmt.Sprintf("*Bug ID:*\n`%s`", issue.BugID)},
+		{Type: "mrkdwn", Text: fmt.Sprintf("*Status Code:*\n%d", issue.StatusCode)},
+		{Type: "mrkdwn", Text: fmt.Sprintf("*Endpoint:*\n`%s %s`", issue.HTTPMethod, issue.Endpoint)},
+		{Type: "mrkdwn", Text: fmt.Sprintf("*First Seen:*\n<!date^%d^{date_short_pretty} {time}|%s>", issue.FirstSeen.Unix(), issue.FirstSeen.Format("2006-01-02 15:04:05"))},
+	}
+	if issue.Service != "" {
+		fields = append(fields, SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*Service:*\n%s", issue.Service)})
+	}
+	if issue.Environment != "" {
+		fields = append(fields, SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*Environment:*\n%s", issue.Environment)})
+	}
+	if issue.Category != "" {
+		fields = append(fields, SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*Category:*\n%s", issue.Category)})
+	}
+	if issue.Occurrences > 1 {
+		fields = append(fields, SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*Occurrences:*\n%d", issue.Occurrences)})
+	}
+
+	blocks := []SlackBlock{
+		slackHeader(withEmoji(emoji, title)),
+		{Type: "section", Fields: fields},
+	}
+	blocks = append(blocks, slackIssueActions(issue)...)
+
+	return SlackMessage{Text: title, Blocks: blocks}
+}
+
+// slackReopenedIssueBlocks renders a reopened issue notification as Block Kit blocks
+func slackReopenedIssueBlocks(issue *IssueInfo, emoji string) SlackMessage {
+	title := fmt.Sprintf("Reopened Issue #%d: %s", issue.Number, issue.Title)
+
+	blocks := []SlackBlock{
+		slackHeader(withEmoji(emoji, title)),
+		{
+			Type: "section",
+			Text: &SlackText{Type: "mrkdwn", Text: fmt.Sprintf("This issue has been reopened. Total occurrences: *%d*", issue.Occurrences)},
+		},
+	}
+	blocks = append(blocks, slackIssueActions(issue)...)
+
+	return SlackMessage{Text: title, Blocks: blocks}
+}
+
+// slackResolvedIssueBlocks renders a resolved issue notification as Block Kit blocks
+func slackResolvedIssueBlocks(issue *IssueInfo) SlackMessage {
+	title := fmt.Sprintf("Resolved Issue #%d: %s", issue.Number, issue.Title)
+
+	blocks := []SlackBlock{
+		slackHeader(withEmoji(emojiResolvedIssue, title)),
+		{
+			Type: "section",
+			Text: &SlackText{Type: "mrkdwn", Text: resolvedLine(issue)},
+		},
+	}
+	blocks = append(blocks, slackIssueActions(issue)...)
+
+	return SlackMessage{Text: title, Blocks: blocks}
+}
+
+// slackSummaryBlocks renders a digest as Block Kit blocks
+func slackSummaryBlocks(issues []*IssueInfo) SlackMessage {
+	title := fmt.Sprintf("Activity on %d issue(s)", len(issues))
+
+	var text strings.Builder
+	for _, issue := range issues {
+		name := fmt.Sprintf("#%d %s", issue.Number, issue.Title)
+		if issue.URL != "" {
+			name = fmt.Sprintf("<%s|%s>", issue.URL, name)
+		}
+		text.WriteString(fmt.Sprintf("• %s: %s\n", name, summaryLine(issue)))
+	}
+
+	return SlackMessage{
+		Text: title,
+		Blocks: []SlackBlock{
+			slackHeader(withEmoji(emojiReopenedIssue, title)),
+			{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: text.String()}},
+		},
+	}
+}
+
+// slackHeader builds a header block, truncated to Slack's 150 character limit
+func slackHeader(text string) SlackBlock {
+	if runes := []rune(text); len(runes) > 150 {
+		text = string(runes[:149]) + "…"
+	}
+	return SlackBlock{Type: "header", Text: &SlackText{Type: "plain_text", Text: text}}
+}
+
+// slackIssueActions builds an actions block with buttons linking to the
+// issue, trace and logs, for whichever URLs are known
+func slackIssueActions(issue *IssueInfo) []SlackBlock {
+	var elements []SlackElement
+	if issue.URL != "" {
+		elements = append(elements, SlackElement{
+			Type:  "button",
+			Text:  &SlackText{Type: "plain_text", Text: "View Issue"},
+			URL:   issue.URL,
+			Style: "primary",
+		})
+	}
+	if issue.TraceURL != "" {
+		elements = append(elements, SlackElement{
+			Type: "button",
+			Text: &SlackText{Type: "plain_text", Text: "View Trace"},
+			URL:  issue.TraceURL,
+		})
+	}
+	if issue.LogsURL != "" {
+		elements = append(elements, SlackElement{
+			Type: "button",
+			Text: &SlackText{Type: "plain_text", Text: "View Logs"},
+			URL:  issue.LogsURL,
+		})
+	}
+
+	if len(elements) == 0 {
+		return nil
+	}
+	return []SlackBlock{{Type: "actions", Elements: elements}}
+}
@@ -0,0 +1,188 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// slackAPIURL is the base URL of the Slack Web API
+const slackAPIURL = "https://slack.com/api/"
+
+// slackUpdateInterval limits how often the message of an issue is updated
+// with its occurrence count, to stay within Slack's rate limits
+const slackUpdateInterval = 30 * time.Second
+
+// slackPostTTL is how long the message of an issue without occurrences is
+// remembered, bounding the messages kept for issues that are never resolved
+const slackPostTTL = 7 * 24 * time.Hour
+
+// slackPost is the new issue message posted for a bug ID in bot mode
+type slackPost struct {
+	channel string // channel ID, as chat.update doesn't take names
+	ts      string
+	issue   IssueInfo // rendered again with the current occurrence count
+	updated time.Time
+}
+
+// WithSlackBot posts messages with a bot token (chat:write scope) to a
+// channel instead of through a webhook. The new issue message of a bug ID
+// is then updated with its occurrence count, and reopened and resolved
+// notifications are posted as replies in its thread; reopens are also sent
+// to the channel. Message timestamps are kept in memory until the issue is
+// resolved or has had no occurrences for a week, so after a restart or
+// once forgotten, notifications about existing issues are posted to the
+// channel instead.
+func WithSlackBot(token, channel string) SlackOption {
+	return func(s *SlackNotifier) {
+		s.botToken = token
+		s.channel = channel
+		s.posts = make(map[string]*slackPost)
+	}
+}
+
+// slackAPIRequest is a chat.postMessage or chat.update request
+type slackAPIRequest struct {
+	SlackMessage
+	Channel        string `json:"channel"`
+	TS             string `json:"ts,omitempty"`        // message to update
+	ThreadTS       string `json:"thread_ts,omitempty"` // message to reply to
+	ReplyBroadcast bool   `json:"reply_broadcast,omitempty"`
+}
+
+// slackAPIResponse is the response of a Slack Web API call
+type slackAPIResponse struct {
+	OK      bool   `json:"ok"`
+	Error   string `json:"error,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	TS      string `json:"ts,omitempty"`
+}
+
+// NotifyOccurrence updates the new issue message of a bug ID with its
+// occurrence count. Issues without a known message are skipped.
+func (s *SlackNotifier) NotifyOccurrence(issue *IssueInfo) error {
+	if s.botToken == "" || issue.BugID == "" {
+		return nil
+	}
+
+	post := s.post(issue.BugID)
+	if post == nil || time.Since(post.updated) < slackUpdateInterval {
+		return nil
+	}
+
+	info := post.issue
+	info.Occurrences = issue.Occurrences
+	msg := s.withMention(&info, s.newIssueMessage(&info))
+	if _, err := s.callAPI("chat.update", slackAPIRequest{SlackMessage: msg, Channel: post.channel, TS: post.ts}); err != nil {
+		s.setPost(issue.BugID, nil)
+		return err
+	}
+
+	post.issue = info
+	post.updated = time.Now()
+	s.setPost(issue.BugID, post)
+	return nil
+}
+
+// postIssue posts a new issue message, remembering it in bot mode so it can
+// be updated and replied to
+func (s *SlackNotifier) postIssue(issue *IssueInfo, msg SlackMessage) error {
+	if s.botToken == "" || issue.BugID == "" {
+		return s.send(msg)
+	}
+
+	resp, err := s.callAPI("chat.postMessage", slackAPIRequest{SlackMessage: msg, Channel: s.channel})
+	if err != nil {
+		return err
+	}
+	s.setPost(issue.BugID, &slackPost{channel: resp.Channel, ts: resp.TS, issue: *issue, updated: time.Now()})
+	return nil
+}
+
+// reply posts a message about an issue in the thread of its new issue
+// message, also sending it to the channel if broadcast is set. Without a
+// known message, or if it can't be replied to anymore, it is posted to the
+// channel.
+func (s *SlackNotifier) reply(issue *IssueInfo, msg SlackMessage, broadcast bool) error {
+	var post *slackPost
+	if s.botToken != "" && issue.BugID != "" {
+		post = s.post(issue.BugID)
+	}
+	if post == nil {
+		return s.send(msg)
+	}
+
+	req := slackAPIRequest{SlackMessage: msg, Channel: post.channel, ThreadTS: post.ts, ReplyBroadcast: broadcast}
+	if _, threadErr := s.callAPI("chat.postMessage", req); threadErr != nil {
+		s.setPost(issue.BugID, nil)
+		if err := s.send(msg); err != nil {
+			return fmt.Errorf("failed to reply in the thread (%v) and to post to the channel: %w", threadErr, err)
+		}
+	}
+	return nil
+}
+
+// post returns the new issue message of a bug ID, or nil if there is none
+func (s *SlackNotifier) post(bugID string) *slackPost {
+	s.postsMu.Lock()
+	defer s.postsMu.Unlock()
+	if post, ok := s.posts[bugID]; ok {
+		copied := *post
+		return &copied
+	}
+	return nil
+}
+
+// setPost records the new issue message of a bug ID; nil forgets it.
+// Messages not updated within slackPostTTL are pruned on the way.
+func (s *SlackNotifier) setPost(bugID string, post *slackPost) {
+	s.postsMu.Lock()
+	defer s.postsMu.Unlock()
+	if post == nil {
+		delete(s.posts, bugID)
+		return
+	}
+
+	for id, p := range s.posts {
+		if time.Since(p.updated) > slackPostTTL {
+			delete(s.posts, id)
+		}
+	}
+	s.posts[bugID] = post
+}
+
+// callAPI calls a Slack Web API method with the bot token
+func (s *SlackNotifier) callAPI(method string, req slackAPIRequest) (*slackAPIResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal Slack message: %w", err)
+	}
+
+	httpReq, err := http.NewRequest(http.MethodPost, slackAPIURL+method, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json; charset=utf-8")
+	httpReq.Header.Set("Authorization", "Bearer "+s.botToken)
+
+	resp, err := s.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send Slack notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Slack %s returned status %d", method, resp.StatusCode)
+	}
+
+	var result slackAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode Slack %s response: %w", method, err)
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("Slack %s failed: %s", method, result.Error)
+	}
+	return &result, nil
+}
@@ -4,12 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
+
+	"vigil/transport"
 )
 
 // TelegramNotifier sends notifications to Telegram via Bot API
 type TelegramNotifier struct {
+	themed
+	compactable
 	botToken   string
 	chatID     string
 	httpClient *http.Client
@@ -27,37 +33,68 @@ func NewTelegramNotifier(botToken, chatID string) *TelegramNotifier {
 	return &TelegramNotifier{
 		botToken:   botToken,
 		chatID:     chatID,
-		httpClient: &http.Client{Timeout: 10 * time.Second},
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport.Wrap(nil)},
 	}
 }
 
 // NotifyNewIssue sends a notification for a new issue
 func (t *TelegramNotifier) NotifyNewIssue(issue *IssueInfo) error {
+	if t.compact {
+		return t.send(escapeMarkdown(compactLine(t.theme.emoji(issue.Severity, emojiNewIssue), "", issue)))
+	}
+
 	text := fmt.Sprintf(
-		"🔴 *New Issue \\#%d*\n\n"+
+		"%s *New Issue \\#%d*\n\n"+
 			"*Title:* %s\n"+
 			"*Bug ID:* `%s`\n"+
 			"*Status Code:* %d\n"+
 			"*Endpoint:* `%s %s`\n"+
 			"*Time:* %s",
+		t.theme.emoji(issue.Severity, emojiNewIssue),
 		issue.Number,
 		escapeMarkdown(issue.Title),
-		issue.BugID,
+		escapeCode(issue.BugID),
 		issue.StatusCode,
-		issue.HTTPMethod,
-		escapeMarkdown(issue.Endpoint),
+		escapeCode(issue.HTTPMethod),
+		escapeCode(issue.Endpoint),
 		issue.FirstSeen.Format(time.RFC3339),
 	)
 
+	if issue.Service != "" {
+		text += "\n*Service:* " + escapeMarkdown(issue.Service)
+	}
+	if issue.Environment != "" {
+		text += "\n*Environment:* " + escapeMarkdown(issue.Environment)
+	}
+	if issue.Category != "" {
+		text += "\n*Category:* " + escapeMarkdown(issue.Category)
+	}
+
+	var links []string
+	if issue.TraceURL != "" {
+		links = append(links, fmt.Sprintf("[View trace](%s)", escapeLinkURL(issue.TraceURL)))
+	}
+	if issue.LogsURL != "" {
+		links = append(links, fmt.Sprintf("[View logs](%s)", escapeLinkURL(issue.LogsURL)))
+	}
+	if len(links) > 0 {
+		text += "\n*Links:* " + strings.Join(links, " · ")
+	}
+
 	return t.send(text)
 }
 
 // NotifyReopenedIssue sends a notification for a reopened issue
 func (t *TelegramNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	if t.compact {
+		return t.send(escapeMarkdown(compactLine(t.theme.emoji(issue.Severity, emojiReopenedIssue), "reopened", issue)))
+	}
+
 	text := fmt.Sprintf(
-		"🟠 *Reopened Issue \\#%d*\n\n"+
+		"%s *Reopened Issue \\#%d*\n\n"+
 			"*Title:* %s\n"+
 			"*Occurrences:* %d",
+		t.theme.emoji(issue.Severity, emojiReopenedIssue),
 		issue.Number,
 		escapeMarkdown(issue.Title),
 		issue.Occurrences,
@@ -66,41 +103,139 @@ func (t *TelegramNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
 	return t.send(text)
 }
 
+// NotifyResolvedIssue sends a notification for an issue that has gone quiet
+func (t *TelegramNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	if t.compact {
+		return t.send(escapeMarkdown(compactLine(emojiResolvedIssue, "resolved", issue)))
+	}
+
+	text := fmt.Sprintf(
+		"%s *Resolved Issue \\#%d*\n\n"+
+			"*Title:* %s\n"+
+			"%s",
+		emojiResolvedIssue,
+		issue.Number,
+		escapeMarkdown(issue.Title),
+		escapeMarkdown(resolvedLine(issue)),
+	)
+
+	return t.send(text)
+}
+
+// NotifySummary sends a digest of activity on existing issues
+func (t *TelegramNotifier) NotifySummary(issues []*IssueInfo) error {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf(emojiReopenedIssue+" *Activity on %d issue\\(s\\)*\n\n", len(issues)))
+	for _, issue := range issues {
+		text.WriteString(fmt.Sprintf("• *\\#%d* %s: %s\n",
+			issue.Number,
+			escapeMarkdown(issue.Title),
+			escapeMarkdown(summaryLine(issue)),
+		))
+	}
+
+	return t.send(text.String())
+}
+
+// NotifyDigest sends the scheduled digest
+func (t *TelegramNotifier) NotifyDigest(summary *DigestSummary) error {
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("*%s*\n%s\n", escapeMarkdown(digestTitle(summary)), escapeMarkdown(digestTotals(summary))))
+	for _, section := range digestSections(summary) {
+		text.WriteString(fmt.Sprintf("\n*%s*\n", escapeMarkdown(section.Title)))
+		for _, issue := range section.Issues {
+			text.WriteString(fmt.Sprintf("• *\\#%d* %s\n",
+				issue.Number,
+				escapeMarkdown(issue.Title+digestItem(issue, section.Counts)),
+			))
+		}
+		if section.More > 0 {
+			text.WriteString(escapeMarkdown(digestMore(section)) + "\n")
+		}
+	}
+
+	return t.send(text.String())
+}
+
 // Name returns the name of this notifier
 func (t *TelegramNotifier) Name() string {
 	return "telegram"
 }
 
-// send posts a message to Telegram
+// telegramResponse is the part of a Bot API response describing an error
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+}
+
+// send posts a MarkdownV2 message to Telegram. If Telegram can't parse the
+// markup, the message is sent again as plain text so the alert isn't lost.
 func (t *TelegramNotifier) send(text string) error {
-	msg := TelegramMessage{
-		ChatID:    t.chatID,
-		Text:      text,
-		ParseMode: "MarkdownV2",
+	status, description, err := t.post(TelegramMessage{ChatID: t.chatID, Text: text, ParseMode: "MarkdownV2"})
+	if err != nil {
+		return err
+	}
+	if status == http.StatusBadRequest && strings.Contains(description, "can't parse entities") {
+		log.Printf("Warning: Telegram rejected the MarkdownV2 message (%s), sending it as plain text", description)
+		status, description, err = t.post(TelegramMessage{ChatID: t.chatID, Text: plainText(text)})
+		if err != nil {
+			return err
+		}
 	}
 
+	if status != http.StatusOK {
+		if description != "" {
+			return fmt.Errorf("Telegram API returned status %d: %s", status, description)
+		}
+		return fmt.Errorf("Telegram API returned status %d", status)
+	}
+
+	return nil
+}
+
+// post sends a message and returns the response status and, for errors,
+// Telegram's description
+func (t *TelegramNotifier) post(msg TelegramMessage) (int, string, error) {
 	body, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("failed to marshal Telegram message: %w", err)
+		return 0, "", fmt.Errorf("failed to marshal Telegram message: %w", err)
 	}
 
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", t.botToken)
 	resp, err := t.httpClient.Post(url, "application/json", bytes.NewReader(body))
 	if err != nil {
-		return fmt.Errorf("failed to send Telegram notification: %w", err)
+		return 0, "", fmt.Errorf("failed to send Telegram notification: %w", err)
 	}
 	defer resp.Body.Close()
 
+	var result telegramResponse
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("Telegram API returned status %d", resp.StatusCode)
+		json.NewDecoder(resp.Body).Decode(&result)
 	}
+	return resp.StatusCode, result.Description, nil
+}
 
-	return nil
+// plainText turns a MarkdownV2 message into plain text by dropping the
+// escapes and the bold and code markers
+func plainText(text string) string {
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text):
+			i++
+			sb.WriteByte(text[i])
+		case c == '*' || c == '`':
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
 }
 
-// escapeMarkdown escapes special characters for Telegram MarkdownV2
+// escapeMarkdown escapes special characters for plain text in Telegram
+// MarkdownV2. Text inside code spans is escaped with escapeCode instead.
 func escapeMarkdown(text string) string {
-	chars := []string{"_", "*", "[", "]", "(", ")", "~", "`", ">", "#", "+", "-", "=", "|", "{", "}", ".", "!"}
+	chars := []string{"\\", "_", "*", "[", "]", "(", ")", "~", "`", ">", "#", "+", "-", "=", "|", "{", "}", ".", "!"}
 	result := text
 	for _, char := range chars {
 		result = escapeChar(result, char)
@@ -108,6 +243,18 @@ func escapeMarkdown(text string) string {
 	return result
 }
 
+// escapeCode escapes text inside a MarkdownV2 code span, where only '`'
+// and '\' must be escaped
+func escapeCode(text string) string {
+	return escapeChar(escapeChar(text, "\\"), "`")
+}
+
+// escapeLinkURL escapes a URL for the (...) part of a MarkdownV2 inline link,
+// where only ')' and '\' must be escaped
+func escapeLinkURL(url string) string {
+	return escapeChar(escapeChar(url, "\\"), ")")
+}
+
 func escapeChar(text, char string) string {
 	var result []byte
 	for i := 0; i < len(text); i++ {
@@ -0,0 +1,104 @@
+package notifier
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Default colors and emoji, used when the theme has no entry for a severity
+const (
+	colorNewIssue      = "#ff0000" // red
+	colorReopenedIssue = "#ff9900" // orange
+	colorResolvedIssue = "#36a64f" // green
+	emojiNewIssue      = "🔴"
+	emojiReopenedIssue = "🟠"
+	emojiResolvedIssue = "✅"
+)
+
+var hexColor = regexp.MustCompile(`^#?[0-9a-fA-F]{6}$`)
+
+// Style is the color and emoji used for notifications of one severity
+type Style struct {
+	Color string // "#rrggbb"
+	Emoji string
+}
+
+// Theme maps severities to notification styles
+type Theme map[string]Style
+
+// Themeable is implemented by notifiers whose colors and emoji can be themed
+type Themeable interface {
+	SetTheme(theme Theme)
+}
+
+// themed is embedded by notifiers to implement Themeable
+type themed struct {
+	theme Theme
+}
+
+// SetTheme sets the severity theme
+func (t *themed) SetTheme(theme Theme) {
+	t.theme = theme
+}
+
+// ParseTheme parses a comma-separated list of severity=color[:emoji]
+// entries, e.g. "critical=#d00000:🔥,warning=#ffcc00"
+func ParseTheme(spec string) (Theme, error) {
+	theme := make(Theme)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		severity, value, ok := strings.Cut(item, "=")
+		severity = strings.ToLower(strings.TrimSpace(severity))
+		if !ok || severity == "" {
+			return nil, fmt.Errorf("invalid theme entry %q (expected severity=color[:emoji])", item)
+		}
+
+		color, emoji, _ := strings.Cut(value, ":")
+		color = strings.TrimSpace(color)
+		if color != "" {
+			if !hexColor.MatchString(color) {
+				return nil, fmt.Errorf("invalid color %q for %s (expected #rrggbb)", color, severity)
+			}
+			color = "#" + strings.TrimPrefix(color, "#")
+		}
+
+		theme[severity] = Style{Color: color, Emoji: strings.TrimSpace(emoji)}
+	}
+	return theme, nil
+}
+
+// color returns the themed color for a severity, or fallback
+func (t Theme) color(severity, fallback string) string {
+	if style, ok := t[severity]; ok && style.Color != "" {
+		return style.Color
+	}
+	return fallback
+}
+
+// emoji returns the themed emoji for a severity, or fallback
+func (t Theme) emoji(severity, fallback string) string {
+	if style, ok := t[severity]; ok && style.Emoji != "" {
+		return style.Emoji
+	}
+	return fallback
+}
+
+// colorInt converts a "#rrggbb" color to the integer form Discord expects
+func colorInt(color string) int {
+	n, _ := strconv.ParseInt(strings.TrimPrefix(color, "#"), 16, 32)
+	return int(n)
+}
+
+// withEmoji prefixes text with an emoji, if any
+func withEmoji(emoji, text string) string {
+	if emoji == "" {
+		return text
+	}
+	return emoji + " " + text
+}
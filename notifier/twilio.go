@@ -0,0 +1,109 @@
+package notifier
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"vigil/transport"
+)
+
+// twilioTitleLength keeps SMS messages within a single segment
+const twilioTitleLength = 80
+
+// TwilioNotifier sends SMS notifications for critical issues via Twilio
+type TwilioNotifier struct {
+	accountSID string
+	authToken  string
+	from       string
+	to         []string
+	apiURL     string
+	httpClient *http.Client
+}
+
+// NewTwilioNotifier creates a new Twilio SMS notifier sending from one
+// number to each of the given numbers
+func NewTwilioNotifier(accountSID, authToken, from string, to []string) *TwilioNotifier {
+	return &TwilioNotifier{
+		accountSID: accountSID,
+		authToken:  authToken,
+		from:       from,
+		to:         to,
+		apiURL:     fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", url.PathEscape(accountSID)),
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport.Wrap(nil)},
+	}
+}
+
+// NotifyNewIssue texts a short message for critical issues only
+func (t *TwilioNotifier) NotifyNewIssue(issue *IssueInfo) error {
+	if issue.Severity != SeverityCritical {
+		return nil
+	}
+
+	title := issue.Title
+	if runes := []rune(title); len(runes) > twilioTitleLength {
+		title = string(runes[:twilioTitleLength-1]) + "…"
+	}
+
+	text := fmt.Sprintf("[vigil] CRITICAL #%d: %s", issue.Number, title)
+	if issue.StatusCode > 0 {
+		text += fmt.Sprintf(" (status %d)", issue.StatusCode)
+	}
+
+	var lastErr error
+	for _, to := range t.to {
+		if err := t.send(to, text); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// NotifyReopenedIssue does nothing; only new critical issues are texted
+func (t *TwilioNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	return nil
+}
+
+// NotifyResolvedIssue does nothing; only new critical issues are texted
+func (t *TwilioNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	return nil
+}
+
+// NotifySummary does nothing; digests are not sent by SMS
+func (t *TwilioNotifier) NotifySummary(issues []*IssueInfo) error {
+	return nil
+}
+
+// Name returns the name of this notifier
+func (t *TwilioNotifier) Name() string {
+	return "twilio"
+}
+
+// send creates a Twilio message to a single number
+func (t *TwilioNotifier) send(to, text string) error {
+	form := url.Values{}
+	form.Set("From", t.from)
+	form.Set("To", to)
+	form.Set("Body", text)
+
+	req, err := http.NewRequest("POST", t.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(t.accountSID, t.authToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := t.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send Twilio SMS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Twilio API returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package notifier
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// telegramTokenPattern matches a bot token as issued by BotFather,
+// "<bot id>:<secret>"
+var telegramTokenPattern = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]{30,}$`)
+
+// slackTokenPattern matches a Slack bot or user OAuth token
+var slackTokenPattern = regexp.MustCompile(`^xox[bp]-[A-Za-z0-9-]+$`)
+
+// ValidateWebhookURL checks that raw is an absolute URL with a host. If
+// requireHTTPS is set, the scheme must be https; otherwise http is allowed
+// too (e.g. for self-hosted services on an internal network). Errors don't
+// include the URL since webhook paths are often secret.
+func ValidateWebhookURL(raw string, requireHTTPS bool) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("not a valid URL: %w", err)
+	}
+
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if requireHTTPS {
+			return fmt.Errorf("must use https, not http")
+		}
+	case "":
+		return fmt.Errorf("missing scheme (expected e.g. https://hooks.example.com/...)")
+	default:
+		return fmt.Errorf("unsupported scheme %q (expected https)", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return fmt.Errorf("missing host (expected e.g. https://hooks.example.com/...)")
+	}
+	return nil
+}
+
+// ValidateTelegramToken checks that token looks like a Telegram bot token
+func ValidateTelegramToken(token string) error {
+	if !telegramTokenPattern.MatchString(token) {
+		return fmt.Errorf("malformed bot token (expected <bot id>:<secret> as issued by BotFather)")
+	}
+	return nil
+}
+
+// ValidateSlackBotToken checks that token looks like a Slack OAuth token
+func ValidateSlackBotToken(token string) error {
+	if !slackTokenPattern.MatchString(token) {
+		return fmt.Errorf("malformed bot token (expected xoxb-...)")
+	}
+	return nil
+}
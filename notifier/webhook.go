@@ -0,0 +1,153 @@
+package notifier
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+	"time"
+
+	"vigil/transport"
+)
+
+// SignatureHeader carries the hex HMAC-SHA256 of the request body when a
+// webhook secret is configured
+const SignatureHeader = "X-Vigil-Signature"
+
+// Webhook event types
+const (
+	WebhookEventNewIssue      = "new_issue"
+	WebhookEventReopenedIssue = "reopened_issue"
+	WebhookEventResolvedIssue = "resolved_issue"
+	WebhookEventSummary       = "summary"
+	WebhookEventDigest        = "digest"
+)
+
+// WebhookNotifier posts notifications as JSON to an arbitrary HTTP endpoint
+type WebhookNotifier struct {
+	url        string
+	secret     string
+	template   *template.Template
+	httpClient *http.Client
+}
+
+// WebhookOption configures a WebhookNotifier
+type WebhookOption func(*WebhookNotifier)
+
+// WithWebhookTemplate renders request bodies from a template (see
+// ParseWebhookTemplate) instead of posting the payload as JSON
+func WithWebhookTemplate(tmpl *template.Template) WebhookOption {
+	return func(w *WebhookNotifier) {
+		w.template = tmpl
+	}
+}
+
+// WebhookPayload is the JSON body posted for every event
+type WebhookPayload struct {
+	Event     string         `json:"event"`
+	Timestamp time.Time      `json:"timestamp"`
+	Issue     *IssueInfo     `json:"issue,omitempty"`
+	Issues    []*IssueInfo   `json:"issues,omitempty"`
+	Digest    *DigestSummary `json:"digest,omitempty"`
+}
+
+// NewWebhookNotifier creates a new generic webhook notifier. When secret is
+// non-empty every request is signed with it.
+func NewWebhookNotifier(url, secret string, opts ...WebhookOption) *WebhookNotifier {
+	w := &WebhookNotifier{
+		url:        url,
+		secret:     secret,
+		httpClient: &http.Client{Timeout: 10 * time.Second, Transport: transport.Wrap(nil)},
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// NotifyNewIssue posts a new issue event
+func (w *WebhookNotifier) NotifyNewIssue(issue *IssueInfo) error {
+	return w.send(WebhookPayload{Event: WebhookEventNewIssue, Issue: issue})
+}
+
+// NotifyReopenedIssue posts a reopened issue event
+func (w *WebhookNotifier) NotifyReopenedIssue(issue *IssueInfo) error {
+	return w.send(WebhookPayload{Event: WebhookEventReopenedIssue, Issue: issue})
+}
+
+// NotifyResolvedIssue posts a resolved issue event
+func (w *WebhookNotifier) NotifyResolvedIssue(issue *IssueInfo) error {
+	return w.send(WebhookPayload{Event: WebhookEventResolvedIssue, Issue: issue})
+}
+
+// NotifySummary posts a digest event
+func (w *WebhookNotifier) NotifySummary(issues []*IssueInfo) error {
+	return w.send(WebhookPayload{Event: WebhookEventSummary, Issues: issues})
+}
+
+// NotifyDigest posts a scheduled digest event
+func (w *WebhookNotifier) NotifyDigest(summary *DigestSummary) error {
+	return w.send(WebhookPayload{Event: WebhookEventDigest, Digest: summary})
+}
+
+// Name returns the name of this notifier
+func (w *WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+// Sign returns the hex HMAC-SHA256 of body keyed with secret, as sent in
+// the X-Vigil-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// send posts a payload to the webhook, rendered with the template if one is
+// set, signing it if a secret is set
+func (w *WebhookNotifier) send(payload WebhookPayload) error {
+	payload.Timestamp = time.Now().UTC()
+
+	body, err := w.body(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.secret, body))
+	}
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send webhook notification: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// body returns the request body of a payload
+func (w *WebhookNotifier) body(payload WebhookPayload) ([]byte, error) {
+	if w.template != nil {
+		return renderWebhookTemplate(w.template, payload)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook payload: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,71 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// webhookTemplateFuncs are the functions available to webhook templates
+var webhookTemplateFuncs = template.FuncMap{
+	// json encodes a value as JSON, e.g. a quoted and escaped string
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// sampleWebhookPayload is rendered to validate a webhook template
+var sampleWebhookPayload = WebhookPayload{
+	Event:     WebhookEventNewIssue,
+	Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+	Issue: &IssueInfo{
+		Number:      42,
+		Title:       `[500] POST /api/orders - "Payment" failed`,
+		URL:         "https://gitea.example.com/owner/error-issues/issues/42",
+		BugID:       "a1b2c3d4e5f6",
+		Service:     "billing",
+		Environment: "production",
+		Severity:    SeverityCritical,
+		Category:    "server_error",
+		Labels:      []string{"auto-generated", "severity:critical"},
+		Endpoint:    "/api/orders",
+		HTTPMethod:  "POST",
+		StatusCode:  500,
+		FirstSeen:   time.Date(2024, 5, 1, 11, 59, 0, 0, time.UTC),
+		Occurrences: 1,
+	},
+}
+
+// ParseWebhookTemplate parses a Go template rendering the webhook request
+// body from a WebhookPayload. It is checked by rendering a sample new issue
+// event, which must produce valid JSON.
+func ParseWebhookTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("webhook").Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook template: %w", err)
+	}
+
+	body, err := renderWebhookTemplate(tmpl, sampleWebhookPayload)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("webhook template renders invalid JSON for a new issue: %s", body)
+	}
+	return tmpl, nil
+}
+
+// renderWebhookTemplate renders the request body of a payload
+func renderWebhookTemplate(tmpl *template.Template, payload WebhookPayload) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payload); err != nil {
+		return nil, fmt.Errorf("failed to render webhook template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strings"
+
+	"vigil/config"
+	"vigil/notifier"
+	"vigil/processor"
+)
+
+// setting is a notifier setting with the environment variable naming it
+type setting struct {
+	env   string
+	value string
+}
+
+// notifierFactory builds a notifier from its settings
+type notifierFactory struct {
+	name string
+	// required returns the settings that must be set to enable the notifier
+	required func(cfg *config.Notifiers) []setting
+	// create builds the notifier; it may exit on invalid settings
+	create func(cfg *config.Notifiers) notifier.Notifier
+	// minSeverity returns the notifier's minimum severity setting, if it has one
+	minSeverity func(cfg *config.Notifiers) setting
+}
+
+// notifierRegistry lists every notifier by the name its Name() returns, in
+// the order they are set up. New notifiers only need an entry here.
+var notifierRegistry = []notifierFactory{
+	{
+		name: "slack",
+		required: func(c *config.Notifiers) []setting {
+			if c.Slack.BotToken != "" {
+				return []setting{
+					{"SLACK_BOT_TOKEN", c.Slack.BotToken},
+					{"SLACK_CHANNEL", c.Slack.Channel},
+				}
+			}
+			if c.Slack.WebhookURL == "" && c.Slack.ChannelRoutes != "" {
+				return []setting{{"SLACK_CHANNEL_ROUTES", c.Slack.ChannelRoutes}}
+			}
+			return []setting{{"SLACK_WEBHOOK_URL", c.Slack.WebhookURL}}
+		},
+		create: func(c *config.Notifiers) notifier.Notifier {
+			var opts []notifier.SlackOption
+			if c.Slack.BlockKit == "true" {
+				opts = append(opts, notifier.WithBlockKit())
+			}
+			if c.Slack.CriticalMention != "" {
+				opts = append(opts, notifier.WithSlackMention(c.Slack.CriticalMention))
+			}
+			var fallback notifier.Notifier
+			switch {
+			case c.Slack.BotToken != "":
+				if err := notifier.ValidateSlackBotToken(c.Slack.BotToken); err != nil {
+					log.Fatalf("Invalid SLACK_BOT_TOKEN: %v", err)
+				}
+				if c.Slack.WebhookURL != "" {
+					log.Println("Warning: SLACK_BOT_TOKEN is set, ignoring SLACK_WEBHOOK_URL")
+				}
+				// The bot is only used for the default channel
+				botOpts := append(opts[:len(opts):len(opts)], notifier.WithSlackBot(c.Slack.BotToken, c.Slack.Channel))
+				fallback = notifier.NewSlackNotifier("", botOpts...)
+				log.Printf("Posting Slack notifications to %s with a bot token", c.Slack.Channel)
+			case c.Slack.WebhookURL != "":
+				validateURL("SLACK_WEBHOOK_URL", c.Slack.WebhookURL, true)
+				fallback = notifier.NewSlackNotifier(c.Slack.WebhookURL, opts...)
+			}
+			return channelRoutes("SLACK_CHANNEL_ROUTES", fallback, c.Slack.ChannelRoutes, func(url string) notifier.Notifier {
+				return notifier.NewSlackNotifier(url, opts...)
+			})
+		},
+		minSeverity: func(c *config.Notifiers) setting {
+			return setting{"SLACK_MIN_SEVERITY", c.Slack.MinSeverity}
+		},
+	},
+	{
+		name: "discord",
+		required: func(c *config.Notifiers) []setting {
+			if c.Discord.WebhookURL == "" && c.Discord.ChannelRoutes != "" {
+				return []setting{{"DISCORD_CHANNEL_ROUTES", c.Discord.ChannelRoutes}}
+			}
+			return []setting{{"DISCORD_WEBHOOK_URL", c.Discord.WebhookURL}}
+		},
+		create: func(c *config.Notifiers) notifier.Notifier {
+			var opts []notifier.DiscordOption
+			if c.Discord.CriticalMention != "" {
+				opts = append(opts, notifier.WithDiscordMention(c.Discord.CriticalMention))
+			}
+			var fallback notifier.Notifier
+			if c.Discord.WebhookURL != "" {
+				validateURL("DISCORD_WEBHOOK_URL", c.Discord.WebhookURL, true)
+				fallbackOpts := opts
+				if c.Discord.ForumChannel != "" {
+					// Forum threads are only kept for the default channel
+					fallbackOpts = append(fallbackOpts[:len(opts):len(opts)], notifier.WithDiscordForum(c.Discord.ForumChannel))
+				}
+				fallback = notifier.NewDiscordNotifier(c.Discord.WebhookURL, fallbackOpts...)
+			}
+			return channelRoutes("DISCORD_CHANNEL_ROUTES", fallback, c.Discord.ChannelRoutes, func(url string) notifier.Notifier {
+				return notifier.NewDiscordNotifier(url, opts...)
+			})
+		},
+		minSeverity: func(c *config.Notifiers) setting {
+			return setting{"DISCORD_MIN_SEVERITY", c.Discord.MinSeverity}
+		},
+	},
+	{
+		name: "mattermost",
+		required: func(c *config.Notifiers) []setting {
+			return []setting{{"MATTERMOST_WEBHOOK_URL", c.Mattermost.WebhookURL}}
+		},
+		create: func(c *config.Notifiers) notifier.Notifier {
+			validateURL("MATTERMOST_WEBHOOK_URL", c.Mattermost.WebhookURL, false)
+			return notifier.NewMattermostNotifier(c.Mattermost.WebhookURL, c.Mattermost.Channel, c.Mattermost.Username)
+		},
+		minSeverity: func(c *config.Notifiers) setting {
+			return setting{"MATTERMOST_MIN_SEVERITY", c.Mattermost.MinSeverity}
+		},
+	},
+	{
+		name: "telegram",
+		required: func(c *config.Notifiers) []setting {
+			return []setting{
+				{"TELEGRAM_BOT_TOKEN", c.Telegram.BotToken},
+				{"TELEGRAM_CHAT_ID", c.Telegram.ChatID},
+			}
+		},
+		create: func(c *config.Notifiers) notifier.Notifier {
+			if err := notifier.ValidateTelegramToken(c.Telegram.BotToken); err != nil {
+				log.Fatalf("Invalid TELEGRAM_BOT_TOKEN: %v", err)
+			}
+			return notifier.NewTelegramNotifier(c.Telegram.BotToken, c.Telegram.ChatID)
+		},
+		minSeverity: func(c *config.Notifiers) setting {
+			return setting{"TELEGRAM_MIN_SEVERITY", c.Telegram.MinSeverity}
+		},
+	},
+	{
+		name: "webhook",
+		required: func(c *config.Notifiers) []setting {
+			return []setting{{"WEBHOOK_URL", c.Webhook.URL}}
+		},
+		create: func(c *config.Notifiers) notifier.Notifier {
+			validateURL("WEBHOOK_URL", c.Webhook.URL, false)
+			if c.Webhook.Secret != "" {
+				log.Println("Webhook requests will be signed")
+			}
+			var opts []notifier.WebhookOption
+			if path := c.Webhook.TemplateFile; path != "" {
+				text, err := os.ReadFile(path)
+				if err != nil {
+					log.Fatalf("Failed to read WEBHOOK_TEMPLATE_FILE: %v", err)
+				}
+				tmpl, err := notifier.ParseWebhookTemplate(string(text))
+				if err != nil {
+					log.Fatalf("Invalid WEBHOOK_TEMPLATE_FILE %s: %v", path, err)
+				}
+				opts = append(opts, notifier.WithWebhookTemplate(tmpl))
+				log.Printf("Webhook payloads will be rendered from %s", path)
+			}
+			return notifier.NewWebhookNotifier(c.Webhook.URL, c.Webhook.Secret, opts...)
+		},
+		minSeverity: func(c *config.Notifiers) setting {
+			return setting{"WEBHOOK_MIN_SEVERITY", c.Webhook.MinSeverity}
+		},
+	},
+	{
+		name: "twilio",
+		required: func(c *config.Notifiers) []setting {
+			return []setting{
+				{"TWILIO_ACCOUNT_SID", c.Twilio.AccountSID},
+				{"TWILIO_AUTH_TOKEN", c.Twilio.AuthToken},
+				{"TWILIO_FROM", c.Twilio.From},
+				{"TWILIO_TO", strings.Join(c.Twilio.To, ",")},
+			}
+		},
+		create: func(c *config.Notifiers) notifier.Notifier {
+			log.Printf("Twilio texts critical issues only (%d recipient(s))", len(c.Twilio.To))
+			return notifier.NewTwilioNotifier(c.Twilio.AccountSID, c.Twilio.AuthToken, c.Twilio.From, c.Twilio.To)
+		},
+	},
+	{
+		name: "pushover",
+		required: func(c *config.Notifiers) []setting {
+			return []setting{
+				{"PUSHOVER_TOKEN", c.Pushover.Token},
+				{"PUSHOVER_USER", c.Pushover.User},
+			}
+		},
+		create: func(c *config.Notifiers) notifier.Notifier {
+			return notifier.NewPushoverNotifier(c.Pushover.Token, c.Pushover.User)
+		},
+		minSeverity: func(c *config.Notifiers) setting {
+			return setting{"PUSHOVER_MIN_SEVERITY", c.Pushover.MinSeverity}
+		},
+	},
+}
+
+// missingSettings returns the env names of the required settings that are empty
+func missingSettings(settings []setting) []string {
+	var missing []string
+	for _, s := range settings {
+		if s.value == "" {
+			missing = append(missing, s.env)
+		}
+	}
+	return missing
+}
+
+// setupNotifiers creates the notifiers listed in ENABLED_NOTIFIERS, or every
+// notifier whose required settings are all set if the list is empty
+func setupNotifiers(cfg *config.Config) []notifier.Notifier {
+	c := &cfg.Notifiers
+
+	enabled := make(map[string]bool)
+	for _, name := range c.Enabled {
+		enabled[strings.ToLower(name)] = true
+	}
+	for name := range enabled {
+		known := false
+		for _, f := range notifierRegistry {
+			known = known || f.name == name
+		}
+		if !known {
+			log.Fatalf("Invalid ENABLED_NOTIFIERS: unknown notifier %q", name)
+		}
+	}
+
+	var notifiers []notifier.Notifier
+	for _, f := range notifierRegistry {
+		missing := missingSettings(f.required(c))
+		if len(enabled) > 0 {
+			if !enabled[f.name] {
+				continue
+			}
+			if len(missing) > 0 {
+				log.Fatalf("Notifier %s is enabled but %s not set", f.name, strings.Join(missing, ", "))
+			}
+		} else if len(missing) > 0 {
+			continue
+		}
+
+		notifiers = append(notifiers, f.create(c))
+		log.Printf("%s notifier enabled", f.name)
+	}
+
+	if len(notifiers) == 0 {
+		log.Println("No notifiers configured (issues will still be created in Gitea)")
+	}
+
+	if spec := c.Theme; spec != "" {
+		theme, err := notifier.ParseTheme(spec)
+		if err != nil {
+			log.Fatalf("Invalid NOTIFY_THEME: %v", err)
+		}
+		for severity := range theme {
+			if _, err := processor.ParseSeverity(severity); err != nil {
+				log.Fatalf("Invalid NOTIFY_THEME: %v", err)
+			}
+		}
+		for _, n := range notifiers {
+			if t, ok := n.(notifier.Themeable); ok {
+				t.SetTheme(theme)
+			}
+		}
+	}
+
+	if c.Compact == "true" {
+		for _, n := range notifiers {
+			if cn, ok := n.(notifier.Compactable); ok {
+				cn.SetCompact(true)
+			}
+		}
+		log.Println("Sending compact single-line notifications")
+	}
+
+	return notifiers
+}
+
+// channelRoutes returns the notifier for the default channel (fallback, nil
+// if there is none) or, when the routes setting named env is set, a notifier
+// routing issues by label to a channel each, created with create
+func channelRoutes(env string, fallback notifier.Notifier, spec string, create func(url string) notifier.Notifier) notifier.Notifier {
+	if spec == "" {
+		return fallback
+	}
+
+	parsed, err := notifier.ParseChannelRoutes(spec)
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", env, err)
+	}
+	if len(parsed) == 0 && fallback == nil {
+		log.Fatalf("Invalid %s: no routes", env)
+	}
+
+	routes := make([]notifier.Route, 0, len(parsed))
+	for _, r := range parsed {
+		validateURL(env, r.URL, true)
+		routes = append(routes, notifier.Route{Label: r.Label, Notifier: create(r.URL)})
+		log.Printf("Routing notifications for %s to their own channel (%s)", r.Label, env)
+	}
+	return notifier.NewRoutedNotifier(fallback, routes)
+}
+
+// validateURL exits with a message naming the setting if a notifier URL is malformed
+func validateURL(name, value string, requireHTTPS bool) {
+	if err := notifier.ValidateWebhookURL(value, requireHTTPS); err != nil {
+		log.Fatalf("Invalid %s: %v", name, err)
+	}
+}
+
+// notifierMinSeverities reads the minimum severity setting of each notifier,
+// e.g. TELEGRAM_MIN_SEVERITY, and returns them by notifier name
+func notifierMinSeverities(cfg *config.Config, notifiers []notifier.Notifier) map[string]string {
+	enabled := make(map[string]bool)
+	for _, n := range notifiers {
+		enabled[n.Name()] = true
+	}
+
+	minimums := make(map[string]string)
+	for _, f := range notifierRegistry {
+		if f.minSeverity == nil {
+			continue
+		}
+		s := f.minSeverity(&cfg.Notifiers)
+		if s.value == "" {
+			continue
+		}
+		severity, err := processor.ParseSeverity(s.value)
+		if err != nil {
+			log.Fatalf("Invalid %s: %v", s.env, err)
+		}
+		minimums[f.name] = severity
+		if enabled[f.name] {
+			log.Printf("Sending %s notifications for %s issues and above", f.name, severity)
+		}
+	}
+	return minimums
+}
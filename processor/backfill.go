@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// DefaultBackfillChunk is the length of the query windows a backfill is
+// split into
+const DefaultBackfillChunk = time.Hour
+
+// Backfill runs the query and processing pipeline over a past time range,
+// one chunk at a time from oldest to newest, and returns when the range is
+// done or the context is cancelled. Chunks that hit the query limit are
+// split further. Issues are deduplicated as usual, so re-running a backfill
+// doesn't create duplicate issues, but it does add the occurrences again.
+//
+// Storm detection is disabled, since a backfill creates issues far faster
+// than errors occurred. Notifications are only sent if notify is set.
+func (p *Processor) Backfill(ctx context.Context, from, to time.Time, chunk time.Duration, notify bool) error {
+	if !from.Before(to) {
+		return fmt.Errorf("start %s is not before end %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if chunk <= 0 {
+		chunk = DefaultBackfillChunk
+	}
+
+	if err := p.tracker.TestConnection(); err != nil {
+		return fmt.Errorf("issue tracker connection test failed: %w", err)
+	}
+	for _, client := range p.clients() {
+		p.ensureLabels(client)
+	}
+
+	p.storm = nil
+	p.muted = !notify
+
+	log.Printf("Backfilling %s to %s in %s chunks", from.Format(time.RFC3339), to.Format(time.RFC3339), chunk)
+
+	total := 0
+	for start := from; start.Before(to); start = start.Add(chunk) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		end := start.Add(chunk)
+		if end.After(to) {
+			end = to
+		}
+
+		entries, err := p.queryWindow(ctx, start, end, true, 0)
+		if err != nil {
+			return fmt.Errorf("failed to query %s to %s: %w", start.Format(time.RFC3339), end.Format(time.RFC3339), err)
+		}
+		p.seen.prune(start)
+
+		// Loki returns the newest entries first; process them in the order
+		// they occurred so occurrence comments read chronologically
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].Timestamp.Before(entries[j].Timestamp)
+		})
+
+		errorCount := p.processBatch(ctx, entries)
+		total += errorCount
+		log.Printf("Backfilled %s to %s: %d entries, %d errors", start.Format(time.RFC3339), end.Format(time.RFC3339), len(entries), errorCount)
+	}
+
+	log.Printf("Backfill complete: %d error entries processed", total)
+	return nil
+}
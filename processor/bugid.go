@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"vigil/loki"
+)
+
+// Bug ID label color modes, see Config.BugIDLabelColorMode
+const (
+	BugIDLabelColorHash  = "hash"  // a stable color derived from the bug ID
+	BugIDLabelColorFixed = "fixed" // the same blue for every bug ID
+)
+
+// bugIDLabelFixedColor is the color of bug ID labels in fixed mode
+const bugIDLabelFixedColor = "0366d6" // blue
+
+// bugIDLabelColor returns the color a bug ID label is created with
+func (p *Processor) bugIDLabelColor(label string) string {
+	if p.bugIDLabelColorMode == BugIDLabelColorFixed {
+		return bugIDLabelFixedColor
+	}
+	return labelColor(label)
+}
+
+// DefaultBugIDFields are the fields hashed into a bug ID when none are configured
+var DefaultBugIDFields = []string{"method", "endpoint", "status", "function"}
+
+// bugIDFieldValues maps each supported bug ID field to its value in a log entry
+var bugIDFieldValues = map[string]func(entry loki.LogEntry) string{
+	"method":          func(e loki.LogEntry) string { return e.Method },
+	"endpoint":        func(e loki.LogEntry) string { return normalizeEndpoint(e.Action) },
+	"status":          func(e loki.LogEntry) string { return strconv.Itoa(e.Status) },
+	"function":        func(e loki.LogEntry) string { return e.Source.Function },
+	"file":            func(e loki.LogEntry) string { return e.Source.File },
+	"line":            func(e loki.LogEntry) string { return strconv.Itoa(e.Source.Line) },
+	"level":           func(e loki.LogEntry) string { return strings.ToLower(e.Level) },
+	"message":         func(e loki.LogEntry) string { return e.Message },
+	"message_pattern": func(e loki.LogEntry) string { return normalizeMessage(e.Message) },
+	"service":         func(e loki.LogEntry) string { return e.Service },
+	"error_type":      func(e loki.LogEntry) string { return errorType(e) },
+}
+
+// ValidateBugIDFields checks that all configured bug ID fields are supported
+func ValidateBugIDFields(fields []string) error {
+	for _, field := range fields {
+		if _, ok := bugIDFieldValues[field]; !ok {
+			return fmt.Errorf("unknown bug ID field %q", field)
+		}
+	}
+	return nil
+}
+
+// equalFields reports whether two field lists are identical
+func equalFields(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// GenerateBugID creates a unique bug ID from log entry using the default fields
+func GenerateBugID(entry loki.LogEntry) string {
+	return GenerateBugIDWithFields(entry, DefaultBugIDFields)
+}
+
+// GenerateBugIDWithFields creates a unique bug ID by hashing the given fields
+func GenerateBugIDWithFields(entry loki.LogEntry, fields []string) string {
+	// If explicit bugId is provided in the log, use it
+	if entry.BugID != "" {
+		return entry.BugID
+	}
+
+	if len(fields) == 0 {
+		fields = DefaultBugIDFields
+	}
+
+	// Auto-generate from log fields
+	values := make([]string, 0, len(fields))
+	if !hasRequest(entry) && equalFields(fields, DefaultBugIDFields) {
+		// The default fields describe an HTTP request, so errors from
+		// background jobs would all share one ID; group them by where they
+		// were raised and what they say instead
+		values = append(values, entry.Source.Function, normalizeMessage(entry.Message))
+	} else {
+		for _, field := range fields {
+			if value, ok := bugIDFieldValues[field]; ok {
+				values = append(values, value(entry))
+			}
+		}
+	}
+
+	// Track the same error separately per environment. Entries without one
+	// keep the IDs they had before environments were supported.
+	if entry.Environment != "" {
+		values = append(values, "env="+entry.Environment)
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(values, "|")))
+	return hex.EncodeToString(hash[:8]) // Shorter for readability
+}
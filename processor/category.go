@@ -0,0 +1,35 @@
+package processor
+
+import "vigil/loki"
+
+// categoryLabelColors are the colors of the error category labels
+var categoryLabelColors = map[string]string{
+	"category:" + loki.CategoryPanic:       "b60205", // dark red
+	"category:" + loki.CategoryServerError: "d93f0b", // red
+	"category:" + loki.CategoryClientError: "fef2c0", // pale yellow
+	"category:" + loki.CategoryAppError:    "c5def5", // pale blue
+}
+
+// categoryLabel returns the error category label of an entry
+func categoryLabel(entry loki.LogEntry) string {
+	return "category:" + entry.ErrorCategory()
+}
+
+// errorType returns the normalized error type of an entry, or "" if it has
+// none or the type just repeats the message
+func errorType(entry loki.LogEntry) string {
+	if entry.ErrorType == "" || entry.ErrorType == entry.Message {
+		return ""
+	}
+	return normalizeMessage(entry.ErrorType)
+}
+
+// errorTypeLabel returns the "type:" label of an entry, or "" if it has no
+// error type
+func errorTypeLabel(entry loki.LogEntry) string {
+	errType := errorType(entry)
+	if errType == "" {
+		return ""
+	}
+	return sanitizeLabel("type:" + errType)
+}
@@ -0,0 +1,110 @@
+package processor
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"vigil/loki"
+)
+
+// contextWindow is how far before and after an error context lines are
+// looked up
+const contextWindow = 5 * time.Minute
+
+// maxContextLineBytes limits the size of each context line in the body
+const maxContextLineBytes = 500
+
+// logContext holds the lines logged around an error in the same stream,
+// oldest first
+type logContext struct {
+	Before []string
+	Line   string
+	After  []string
+}
+
+// contextFor looks up the lines logged just before and after an entry in
+// its Loki stream. It returns nil if context lines are disabled, the entry
+// didn't come from Loki or the queries fail. Lines logged after the entry
+// was queried are not included.
+func (p *Processor) contextFor(ctx context.Context, entry loki.LogEntry) *logContext {
+	if p.contextLines <= 0 || entry.Timestamp.IsZero() {
+		return nil
+	}
+
+	selector := streamSelector(entry)
+	if selector == "" {
+		return nil
+	}
+
+	before, _, err := p.lokiClient.QueryRangeDirection(ctx, selector, entry.Timestamp.Add(-contextWindow), entry.Timestamp,
+		p.contextLines+1, loki.DirectionBackward)
+	if err != nil {
+		log.Printf("Warning: failed to query context lines: %v", err)
+		return nil
+	}
+	after, _, err := p.lokiClient.QueryRangeDirection(ctx, selector, entry.Timestamp, entry.Timestamp.Add(contextWindow),
+		p.contextLines+1, loki.DirectionForward)
+	if err != nil {
+		log.Printf("Warning: failed to query context lines: %v", err)
+		return nil
+	}
+
+	lc := &logContext{
+		Before: contextLines(before, entry.Raw),
+		Line:   entry.Raw,
+		After:  contextLines(after, entry.Raw),
+	}
+	if len(lc.Before) > p.contextLines {
+		lc.Before = lc.Before[len(lc.Before)-p.contextLines:]
+	}
+	if len(lc.After) > p.contextLines {
+		lc.After = lc.After[:p.contextLines]
+	}
+	if len(lc.Before) == 0 && len(lc.After) == 0 {
+		return nil
+	}
+	return lc
+}
+
+// contextLines returns the lines of the entries, oldest first, leaving out
+// the error line itself
+func contextLines(entries []loki.LogEntry, errorLine string) []string {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+
+	var lines []string
+	for _, entry := range entries {
+		if entry.Raw != errorLine {
+			lines = append(lines, entry.Raw)
+		}
+	}
+	return lines
+}
+
+// writeContext renders the context lines of an issue body, marking the error
+// line
+func writeContext(sb *strings.Builder, lc *logContext) {
+	sb.WriteString("\n## Context\n\n```\n")
+	for _, line := range lc.Before {
+		sb.WriteString("  " + contextLine(line) + "\n")
+	}
+	sb.WriteString("> " + contextLine(lc.Line) + "\n")
+	for _, line := range lc.After {
+		sb.WriteString("  " + contextLine(line) + "\n")
+	}
+	sb.WriteString("```\n")
+}
+
+// contextLine shortens a context line to maxContextLineBytes and keeps it on
+// one line
+func contextLine(line string) string {
+	line = strings.ReplaceAll(line, "\n", " ")
+	if len(line) > maxContextLineBytes {
+		line = truncateBytes(line, maxContextLineBytes) + "..."
+	}
+	return line
+}
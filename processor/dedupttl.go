@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"vigil/gitea"
+)
+
+// ParseDedupTTL parses a deduplication TTL: a Go duration like 720h, or a
+// number of days like 30d
+func ParseDedupTTL(spec string) (time.Duration, error) {
+	spec = strings.TrimSpace(spec)
+	if days, ok := strings.CutSuffix(spec, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid dedup TTL %q (expected a duration like 720h or days like 30d)", spec)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(spec)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid dedup TTL %q (expected a duration like 720h or days like 30d)", spec)
+	}
+	return d, nil
+}
+
+// closedTime returns when a closed issue was closed, falling back to its
+// last update for trackers that don't report it
+func closedTime(issue gitea.Issue) time.Time {
+	if issue.ClosedAt != nil {
+		return *issue.ClosedAt
+	}
+	return issue.UpdatedAt
+}
+
+// pastDedupTTL reports whether an issue was closed longer than the dedup
+// TTL ago, so a recurrence gets a new issue rather than reopening it
+func (p *Processor) pastDedupTTL(issue gitea.Issue) bool {
+	if p.dedupTTL <= 0 || issue.State != "closed" {
+		return false
+	}
+	closed := closedTime(issue)
+	return !closed.IsZero() && time.Since(closed) > p.dedupTTL
+}
+
+// latestIssue returns the most recently created of the issues matching a
+// bug ID. With a dedup TTL an old closed issue can share its bug ID with the
+// newer issue that replaced it.
+func (p *Processor) latestIssue(issues []gitea.Issue) *gitea.Issue {
+	latest := &issues[0]
+	if p.dedupTTL <= 0 {
+		return latest
+	}
+	for i := range issues {
+		if issues[i].Number > latest.Number {
+			latest = &issues[i]
+		}
+	}
+	return latest
+}
+
+// linkPrevious comments on an issue closed past the dedup TTL that its error
+// is now tracked in a new issue
+func linkPrevious(client IssueTracker, previous gitea.Issue, issueNumber int64) {
+	comment := fmt.Sprintf("**Recurred** after this issue was closed on `%s`; now tracked in #%d.",
+		closedTime(previous).Format(time.RFC3339), issueNumber)
+	if err := client.AddComment(previous.Number, comment); err != nil {
+		log.Printf("Warning: failed to link issue #%d from issue #%d: %v", issueNumber, previous.Number, err)
+	}
+}
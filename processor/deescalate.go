@@ -0,0 +1,87 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// EscalatedLabel marks an issue whose severity was raised above that of
+// its error, e.g. by hand during an incident
+const EscalatedLabel = "severity:escalated"
+
+// trackEscalated records an occurrence of an escalated issue for
+// de-escalation, if enabled
+func (p *Processor) trackEscalated(issue activeIssue) {
+	if p.deescalateAfter <= 0 || !contains(issue.info.Labels, EscalatedLabel) {
+		return
+	}
+	p.escalated.touch(issue)
+}
+
+// runDeescalator periodically de-escalates escalated issues that have gone
+// quiet until the context is cancelled
+func (p *Processor) runDeescalator(ctx context.Context) {
+	interval := p.deescalateAfter / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	} else if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, issue := range p.escalated.expire(time.Now().Add(-p.deescalateAfter)) {
+				p.deescalate(issue)
+			}
+		}
+	}
+}
+
+// deescalate removes the escalated label from an open issue that has had
+// no occurrences for the quiet period, reverting it to the severity of its
+// error
+func (p *Processor) deescalate(issue activeIssue) {
+	unlock := p.bugLocks.Lock(issue.key)
+	defer unlock()
+
+	// An occurrence processed since the issue expired keeps it escalated
+	if p.escalated.has(issue.key) {
+		return
+	}
+
+	// The issue may have been closed or de-escalated by hand meanwhile
+	current, err := issue.client.GetIssue(issue.info.Number)
+	if err != nil {
+		log.Printf("Warning: failed to fetch issue #%d: %v", issue.info.Number, err)
+		return
+	}
+	if current.State == "closed" || !current.HasLabel(EscalatedLabel) {
+		return
+	}
+
+	if err := issue.client.RemoveLabel(current.Number, EscalatedLabel); err != nil {
+		log.Printf("Warning: failed to remove label %s from issue #%d: %v", EscalatedLabel, current.Number, err)
+		return
+	}
+	severityLabel := "severity:" + issue.info.Severity
+	if !current.HasLabel(severityLabel) {
+		if err := issue.client.AddLabels(current.Number, []string{severityLabel}); err != nil {
+			log.Printf("Warning: failed to add label %s to issue #%d: %v", severityLabel, current.Number, err)
+		}
+	}
+
+	comment := fmt.Sprintf("**De-escalated:** no occurrences for %s (last seen `%s`). Severity reverted to `%s`.",
+		p.deescalateAfter, issue.lastSeen.Format(time.RFC3339), issue.info.Severity)
+	if err := issue.client.AddComment(current.Number, comment); err != nil {
+		log.Printf("Warning: failed to comment on de-escalated issue #%d: %v", current.Number, err)
+	}
+	log.Printf("De-escalated issue %s#%d to %s (no occurrences for %s)", issue.client.Repo(), current.Number, issue.info.Severity, p.deescalateAfter)
+}
@@ -0,0 +1,103 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"vigil/notifier"
+)
+
+// Notification modes
+const (
+	NotifyModeImmediate = "immediate"
+	NotifyModeDigest    = "digest"
+)
+
+// digest accumulates occurrence activity on existing issues between flushes
+type digest struct {
+	mu     sync.Mutex
+	issues map[string]*notifier.IssueInfo // keyed by "owner/repo#number"
+}
+
+func newDigest() *digest {
+	return &digest{issues: make(map[string]*notifier.IssueInfo)}
+}
+
+// record adds an occurrence of an existing issue in repo to the digest
+func (d *digest) record(repo string, issue notifier.IssueInfo, reopened bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := fmt.Sprintf("%s#%d", repo, issue.Number)
+	info, ok := d.issues[key]
+	if !ok {
+		info = &notifier.IssueInfo{Number: issue.Number}
+		d.issues[key] = info
+	}
+	info.Title = issue.Title
+	info.URL = issue.URL
+	info.Service = issue.Service
+	info.Labels = issue.Labels
+	info.Occurrences = issue.Occurrences
+	info.NewOccurrences++
+	info.Reopened = info.Reopened || reopened
+}
+
+// drain returns the accumulated issues ordered by issue number and resets the digest
+func (d *digest) drain() []*notifier.IssueInfo {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	issues := make([]*notifier.IssueInfo, 0, len(d.issues))
+	for _, info := range d.issues {
+		issues = append(issues, info)
+	}
+	d.issues = make(map[string]*notifier.IssueInfo)
+
+	sort.Slice(issues, func(i, j int) bool { return issues[i].Number < issues[j].Number })
+	return issues
+}
+
+// runDigest periodically flushes the digest until the context is cancelled
+func (p *Processor) runDigest(ctx context.Context) {
+	ticker := time.NewTicker(p.digestInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.flushDigest()
+			return
+		case <-ticker.C:
+			p.flushDigest()
+		}
+	}
+}
+
+// flushDigest sends a summary of accumulated activity to all notifiers
+func (p *Processor) flushDigest() {
+	issues := p.digest.drain()
+	if len(issues) == 0 {
+		return
+	}
+
+	if p.notificationsSuppressed() {
+		log.Printf("Dropping digest for %d issues: notifications are suppressed", len(issues))
+		return
+	}
+
+	log.Printf("Sending digest for %d issues", len(issues))
+	for _, n := range p.notifiers {
+		selected := p.summaryIssues(n, issues)
+		if len(selected) == 0 {
+			continue
+		}
+		if err := n.NotifySummary(selected); err != nil {
+			log.Printf("Error sending %s digest: %v", n.Name(), err)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package processor
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"regexp"
+	"strings"
+
+	"vigil/loki"
+)
+
+// maxLabelLength is the longest label name Gitea accepts
+const maxLabelLength = 50
+
+// labelUnsafeChars matches runs of characters not kept in label names
+var labelUnsafeChars = regexp.MustCompile(`[^A-Za-z0-9._:/-]+`)
+
+// sanitizeLabel replaces characters outside the label charset with dashes
+// and caps the length
+func sanitizeLabel(name string) string {
+	name = strings.Trim(labelUnsafeChars.ReplaceAllString(name, "-"), "-")
+	if len(name) > maxLabelLength {
+		name = name[:maxLabelLength]
+	}
+	return name
+}
+
+// labelColor derives a stable color from a label name, so the same label
+// gets the same color in every repository
+func labelColor(name string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	return fmt.Sprintf("%06x", h.Sum32()&0xffffff)
+}
+
+// fieldLabels returns a "field:value" label for each configured log field
+// present in the entry. Fields may be dotted paths, and fields missing from
+// the log are looked up among the stream labels (e.g. namespace); missing or
+// empty fields are skipped.
+func (p *Processor) fieldLabels(entry loki.LogEntry) []string {
+	var labels []string
+	for _, field := range p.labelFields {
+		value, ok := loki.LookupPath(entry.Parsed, field)
+		if !ok || value == nil {
+			if value, ok = entry.Labels[field]; !ok {
+				continue
+			}
+		}
+		s := fmt.Sprint(value)
+		if s == "" {
+			continue
+		}
+		if label := sanitizeLabel(field + ":" + s); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// ensureFieldLabels creates the field labels in a repository and returns them
+func (p *Processor) ensureFieldLabels(client IssueTracker, entry loki.LogEntry) []string {
+	labels := p.fieldLabels(entry)
+	for _, label := range labels {
+		if err := client.EnsureLabel(label, labelColor(label)); err != nil {
+			log.Printf("Warning: failed to create label %s: %v", label, err)
+		}
+	}
+	return labels
+}
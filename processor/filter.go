@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+
+	"vigil/loki"
+)
+
+// ignoreReason returns why an entry should be ignored, or an empty string
+// if it should be processed
+func (p *Processor) ignoreReason(entry loki.LogEntry) string {
+	if entry.Action != "" {
+		for _, pattern := range p.ignoreEndpoints {
+			if matched, _ := path.Match(pattern, entry.Action); matched {
+				return fmt.Sprintf("endpoint %s matches ignore pattern %q", entry.Action, pattern)
+			}
+		}
+	}
+
+	if entry.Message != "" {
+		for _, re := range p.ignoreMessages {
+			if re.MatchString(entry.Message) {
+				return fmt.Sprintf("message matches ignore pattern %q", re.String())
+			}
+		}
+	}
+
+	return ""
+}
+
+// ValidateIgnoreEndpoints checks that all endpoint globs are well-formed
+func ValidateIgnoreEndpoints(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid endpoint pattern %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// CompileIgnorePatterns compiles message ignore regexes
+func CompileIgnorePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid message pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"vigil/loki"
+	"vigil/notifier"
+)
+
+// SeverityKeyword gives entries whose message contains Keyword (matched
+// case-insensitively) a severity when their structured fields don't
+type SeverityKeyword struct {
+	Keyword  string // lowercase
+	Severity string
+}
+
+// ParseSeverityKeywords parses a comma-separated list of keyword=severity
+// mappings, e.g. "panic=critical,fatal=critical,timeout=error"
+func ParseSeverityKeywords(spec string) ([]SeverityKeyword, error) {
+	var keywords []SeverityKeyword
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		keyword, severity, ok := strings.Cut(item, "=")
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+		if !ok || keyword == "" {
+			return nil, fmt.Errorf("invalid severity keyword %q (expected keyword=severity)", item)
+		}
+		severity, err := ParseSeverity(severity)
+		if err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, SeverityKeyword{Keyword: keyword, Severity: severity})
+	}
+	return keywords, nil
+}
+
+// keywordSeverity returns the most severe severity of the keywords found in
+// an entry's message, or "" if none is found. Entries with an HTTP status or
+// that panicked are classified by those instead.
+func keywordSeverity(entry loki.LogEntry, keywords []SeverityKeyword) string {
+	if len(keywords) == 0 || entry.Status > 0 || entry.ErrorCategory() == loki.CategoryPanic {
+		return ""
+	}
+
+	message := strings.ToLower(entry.Message)
+	severity := ""
+	for _, k := range keywords {
+		if strings.Contains(message, k.Keyword) && notifier.SeverityRank(k.Severity) > notifier.SeverityRank(severity) {
+			severity = k.Severity
+		}
+	}
+	return severity
+}
+
+// severity returns the severity of an entry: the one inferred from its
+// message keywords, if any, or else the one of its error category and level
+func (p *Processor) severity(entry loki.LogEntry) string {
+	if severity := keywordSeverity(entry, p.severityKeywords); severity != "" {
+		return severity
+	}
+	return entrySeverity(entry)
+}
+
+// isError reports whether an entry is an error: by its level or status, or
+// by a severity keyword in its message
+func (p *Processor) isError(entry loki.LogEntry) bool {
+	return entry.IsError() || keywordSeverity(entry, p.severityKeywords) != ""
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"vigil/loki"
+)
+
+// performanceLabel marks issues about slow requests rather than errors
+const performanceLabel = "performance"
+
+// slow reports whether an entry is tracked as a slow request: it is not an
+// error, but took longer than the latency threshold. Slow errors are tracked
+// as errors.
+func (p *Processor) slow(entry loki.LogEntry) bool {
+	return !p.isError(entry) && entry.IsSlow(p.latencyThreshold)
+}
+
+// slowBugID groups slow occurrences of the same endpoint, apart from the
+// errors it returns
+func slowBugID(entry loki.LogEntry) string {
+	values := []string{"slow", entry.Method, normalizeEndpoint(entry.Action)}
+	if !hasRequest(entry) {
+		values = append(values, entry.Source.Function)
+	}
+	if entry.Environment != "" {
+		values = append(values, "env="+entry.Environment)
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(values, "|")))
+	return hex.EncodeToString(hash[:8])
+}
+
+// slowTitle creates the title of a slow request issue, noting the latency
+// of its first occurrence
+func slowTitle(entry loki.LogEntry, maxLength int) string {
+	parts := []string{fmt.Sprintf("[SLOW %.0fms]", entry.ElapsedMs)}
+	if entry.Service != "" {
+		parts = append(parts, fmt.Sprintf("[%s]", entry.Service))
+	}
+	switch {
+	case entry.Method != "" && entry.Action != "":
+		parts = append(parts, fmt.Sprintf("%s %s", entry.Method, normalizeEndpoint(entry.Action)))
+	case entry.Source.Function != "":
+		parts = append(parts, entry.Source.Function)
+	default:
+		parts = append(parts, "Slow request")
+	}
+	return fitTitle(parts, nil, maxLength)
+}
+
+// title creates the issue title of an entry
+func (p *Processor) title(entry loki.LogEntry) string {
+	if p.slow(entry) {
+		return slowTitle(entry, p.maxTitleLen)
+	}
+	return generateTitle(entry, p.maxTitleLen)
+}
+
+// category returns the error category of an entry, or "" for slow requests
+func (p *Processor) category(entry loki.LogEntry) string {
+	if p.slow(entry) {
+		return ""
+	}
+	return entry.ErrorCategory()
+}
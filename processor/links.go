@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"text/template"
+
+	"vigil/loki"
+)
+
+// entryLinks holds deep links to observability tools for an entry
+type entryLinks struct {
+	Trace string
+	Logs  string
+}
+
+// ParseLinkTemplate parses a URL template rendered with the log entry as data,
+// e.g. "https://grafana/explore?traceId={{.TraceID}}"
+func ParseLinkTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// links renders the configured deep links for an entry. A link is only
+// rendered when the field it is keyed on is present.
+func (p *Processor) links(entry loki.LogEntry) entryLinks {
+	var links entryLinks
+	if entry.TraceID != "" {
+		links.Trace = renderLink(p.traceURLTemplate, entry)
+	}
+	if entry.RequestID != "" {
+		links.Logs = renderLink(p.logsURLTemplate, entry)
+	}
+	return links
+}
+
+// renderLink executes a link template, returning an empty string on failure
+func renderLink(tmpl *template.Template, entry loki.LogEntry) string {
+	if tmpl == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, entry); err != nil {
+		log.Printf("Warning: failed to render %s link: %v", tmpl.Name(), err)
+		return ""
+	}
+	return sb.String()
+}
@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Liveness reports when the poll loop last ran, for health checks
+type Liveness struct {
+	Status      string    `json:"status"`                 // "ok", or "stalled" once the watchdog timeout has passed
+	Mode        string    `json:"mode"`                   // poll or tail
+	LastPoll    time.Time `json:"last_poll,omitempty"`    // last poll that queried Loki successfully (tail: last entry)
+	LastAttempt time.Time `json:"last_attempt,omitempty"` // last poll that completed, successfully or not
+}
+
+// Liveness statuses
+const (
+	LivenessOK      = "ok"
+	LivenessStalled = "stalled"
+)
+
+// liveness records the progress of the poll loop
+type liveness struct {
+	mu          sync.Mutex
+	started     time.Time
+	lastPoll    time.Time
+	lastAttempt time.Time
+}
+
+// polled records a completed poll
+func (l *liveness) polled(at time.Time, success bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastAttempt = at
+	if success {
+		l.lastPoll = at
+	}
+}
+
+// since returns how long ago the last successful poll completed, or the
+// loop started if no poll has succeeded yet. Failed polls don't count, so a
+// loop that keeps failing against Loki is reported as stalled.
+func (l *liveness) since(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	last := l.lastPoll
+	if last.IsZero() {
+		last = l.started
+	}
+	return now.Sub(last)
+}
+
+// Liveness returns when the poll loop last ran. The status is only ever
+// "stalled" in poll mode with a watchdog timeout.
+func (p *Processor) Liveness() Liveness {
+	p.liveness.mu.Lock()
+	status := Liveness{
+		Status:      LivenessOK,
+		Mode:        p.mode,
+		LastPoll:    p.liveness.lastPoll,
+		LastAttempt: p.liveness.lastAttempt,
+	}
+	p.liveness.mu.Unlock()
+
+	if status.Mode == "" {
+		status.Mode = ModePoll
+	}
+	if p.watchdogTimeout > 0 && p.mode != ModeTail && p.liveness.since(time.Now()) > p.watchdogTimeout {
+		status.Status = LivenessStalled
+	}
+	return status
+}
+
+// runWatchdog exits the process once no poll has succeeded for the watchdog
+// timeout, e.g. because a query hangs, processing deadlocked or Loki keeps
+// failing, so the orchestrator restarts vigil. A stuck goroutine can't be stopped from the
+// outside, so exiting is the only reliable recovery.
+func (p *Processor) runWatchdog(ctx context.Context) {
+	interval := p.watchdogTimeout / 4
+	if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if stalled := p.liveness.since(time.Now()); stalled > p.watchdogTimeout {
+				log.Fatalf("ERROR: watchdog: no poll has succeeded for %s (WATCHDOG_TIMEOUT %s), exiting so vigil is restarted",
+					stalled.Round(time.Second), p.watchdogTimeout)
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package processor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLivenessSinceIgnoresFailedPolls(t *testing.T) {
+	start := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	l := liveness{started: start}
+
+	if got := l.since(start.Add(time.Minute)); got != time.Minute {
+		t.Errorf("since() before any poll = %s, want the time since start (1m)", got)
+	}
+
+	l.polled(start.Add(time.Minute), true)
+	l.polled(start.Add(2*time.Minute), false)
+	l.polled(start.Add(3*time.Minute), false)
+
+	if got := l.since(start.Add(4 * time.Minute)); got != 3*time.Minute {
+		t.Errorf("since() after failed polls = %s, want the time since the last successful poll (3m)", got)
+	}
+}
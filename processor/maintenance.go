@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maintenance suppresses notifications during a planned maintenance window,
+// given as an end time, a file whose existence enables it, or both
+type maintenance struct {
+	until time.Time
+	file  string
+
+	mu     sync.Mutex
+	active bool
+}
+
+// newMaintenance returns a maintenance window, or nil if neither an end time
+// nor a file is configured
+func newMaintenance(until time.Time, file string) *maintenance {
+	if until.IsZero() && file == "" {
+		return nil
+	}
+	return &maintenance{until: until, file: file}
+}
+
+// suppressed reports whether notifications are currently suppressed. It logs
+// when a window starts, with the reason, and when it ends; a window given by
+// an end time ends by itself once the time has passed.
+func (m *maintenance) suppressed() bool {
+	if m == nil {
+		return false
+	}
+
+	reason := m.reason(time.Now())
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if active := reason != ""; active != m.active {
+		m.active = active
+		if active {
+			log.Printf("Maintenance window active (%s): issues are still created and updated, notifications are suppressed", reason)
+		} else {
+			log.Printf("Maintenance window ended: notifications resumed")
+		}
+	}
+	return m.active
+}
+
+// reason describes why the window is active at now, or returns "" if it isn't
+func (m *maintenance) reason(now time.Time) string {
+	if !m.until.IsZero() && now.Before(m.until) {
+		return "until " + m.until.Format(time.RFC3339)
+	}
+
+	if m.file != "" {
+		data, err := os.ReadFile(m.file)
+		if err != nil {
+			return ""
+		}
+		note, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+		if note = strings.TrimSpace(note); note != "" {
+			return m.file + ": " + note
+		}
+		return m.file + " exists"
+	}
+
+	return ""
+}
@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"vigil/loki"
+)
+
+// Patterns for variable data in messages, replaced in this order so that
+// e.g. the digits of a UUID aren't replaced as numbers first
+var (
+	messageQuoted = regexp.MustCompile("\"[^\"]*\"|`[^`]*`")
+	// Single quotes must follow a non-word character so apostrophes
+	// ("can't") don't start a quoted string
+	messageSingleQuoted = regexp.MustCompile(`(^|\W)'[^']*'`)
+	messageUUID         = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	messageTimestamp    = regexp.MustCompile(`\b\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+	messageHex          = regexp.MustCompile(`\b(0x[0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`)
+	messageNumber       = regexp.MustCompile(`\b\d+(\.\d+)?[a-zA-Z]*\b`) // with units, e.g. 1.5s
+)
+
+// hasDigitAndLetter reports whether a token mixes digits and letters, which
+// tells hex tokens apart from plain numbers and words like "deadbeef"
+func hasDigitAndLetter(token string) bool {
+	return strings.ContainsAny(token, "0123456789") && strings.IndexFunc(token, unicode.IsLetter) >= 0
+}
+
+// normalizeMessage replaces variable data in a log message (quoted strings,
+// UUIDs, timestamps, hex tokens and numbers with their units) with
+// placeholders, so that e.g. "user 123 not found" and "user 456 not found"
+// group together. It is the message analog of normalizeEndpoint.
+func normalizeMessage(msg string) string {
+	msg = messageQuoted.ReplaceAllString(msg, ":str")
+	msg = messageSingleQuoted.ReplaceAllString(msg, "${1}:str")
+	msg = messageUUID.ReplaceAllString(msg, ":uuid")
+	msg = messageTimestamp.ReplaceAllString(msg, ":time")
+	msg = messageHex.ReplaceAllStringFunc(msg, func(token string) string {
+		if strings.HasPrefix(token, "0x") || hasDigitAndLetter(token) {
+			return ":hex"
+		}
+		return token
+	})
+	msg = messageNumber.ReplaceAllString(msg, ":num")
+	return strings.TrimSpace(msg)
+}
+
+// hasRequest reports whether an entry describes an HTTP request. Errors from
+// background jobs and workers have neither a method nor an endpoint.
+func hasRequest(entry loki.LogEntry) bool {
+	return entry.Method != "" || entry.Action != ""
+}
@@ -0,0 +1,37 @@
+package processor
+
+import "testing"
+
+func TestNormalizeMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+		want string
+	}{
+		{"plain", "connection refused", "connection refused"},
+		{"number", "user 123 not found", "user :num not found"},
+		{"numbers with units", "request took 1.5s (limit 500ms)", "request took :num (limit :num)"},
+		{"size with unit", "payload of 12MB exceeds limit", "payload of :num exceeds limit"},
+		{"UUID", "order 3f2b8c1e-9a4d-4e6f-b7c2-1d5e8f9a0b3c failed", "order :uuid failed"},
+		{"double quoted", `unknown field "discount_code" in request`, "unknown field :str in request"},
+		{"backquoted", "column `email` cannot be null", "column :str cannot be null"},
+		{"single quoted", "invalid value 'abc' for limit", "invalid value :str for limit"},
+		{"hex with prefix", "segfault at 0x7ffd5e8c", "segfault at :hex"},
+		{"hex token", "commit 9fceb02d0ae5 not found", "commit :hex not found"},
+		{"hex-looking word", "deadbeef is not a valid token", "deadbeef is not a valid token"},
+		{"RFC 3339 timestamp", "token expired at 2026-10-16T08:00:00Z", "token expired at :time"},
+		{"timestamp with fraction and offset", "lock held since 2026-10-16 08:00:00.123+02:00", "lock held since :time"},
+		{"apostrophe", "can't connect to the database", "can't connect to the database"},
+		{"apostrophes", "user's cart doesn't exist", "user's cart doesn't exist"},
+		{"apostrophe and quote", "can't parse 'abc'", "can't parse :str"},
+		{"surrounding space", "  timeout  ", "timeout"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeMessage(tt.msg); got != tt.want {
+				t.Errorf("normalizeMessage(%q) = %q, want %q", tt.msg, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vigil/gitea"
+	"vigil/loki"
+	"vigil/notifier"
+)
+
+// metricLabel marks issues raised by metric alerts
+const metricLabel = "metric-alert"
+
+// metricStep is the resolution metric queries are evaluated at
+const metricStep = time.Minute
+
+// MetricAlert raises an issue when a LogQL metric query, e.g.
+// sum(rate({app="x"} |= "ERROR" [5m])), crosses a threshold. The issue is
+// keyed by the alert name.
+type MetricAlert struct {
+	Name      string
+	Query     string
+	Threshold float64 // the alert fires when any value exceeds it
+	Severity  string
+}
+
+// bugID identifies the issue of an alert
+func (a MetricAlert) bugID() string {
+	return sanitizeLabel("metric-" + a.Name)
+}
+
+// title is the issue title of an alert
+func (a MetricAlert) title() string {
+	return fmt.Sprintf("[METRIC] %s above %s", a.Name, formatValue(a.Threshold))
+}
+
+// metricAlerts tracks which alerts are firing and the issue each one
+// was last raised in
+type metricAlerts struct {
+	mu     sync.Mutex
+	firing map[string]int64 // alert name -> issue number
+}
+
+func newMetricAlerts() *metricAlerts {
+	return &metricAlerts{firing: make(map[string]int64)}
+}
+
+// runMetricAlerts evaluates the metric alerts every poll interval until the
+// context is cancelled
+func (p *Processor) runMetricAlerts(ctx context.Context) {
+	interval := p.pollInterval
+	if interval < metricStep {
+		interval = metricStep
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, alert := range p.metricAlerts {
+			p.evaluateMetricAlert(ctx, alert, time.Now(), interval)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluateMetricAlert queries an alert over the last interval and raises its
+// issue when the threshold is first crossed. The issue isn't updated again
+// until the values have dropped back below the threshold.
+func (p *Processor) evaluateMetricAlert(ctx context.Context, alert MetricAlert, now time.Time, interval time.Duration) {
+	series, err := p.lokiClient.QueryRangeMetric(ctx, alert.Query, now.Add(-interval), now, metricStep)
+	if err != nil {
+		log.Printf("Warning: failed to evaluate metric alert %s: %v", alert.Name, err)
+		return
+	}
+
+	peak, ok := peakValue(series)
+	firing := ok && peak > alert.Threshold
+
+	state := p.metricState
+	state.mu.Lock()
+	number, wasFiring := state.firing[alert.Name]
+	state.mu.Unlock()
+
+	switch {
+	case firing && !wasFiring:
+		issueNumber, err := p.raiseMetricAlert(alert, peak, now)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		state.mu.Lock()
+		state.firing[alert.Name] = issueNumber
+		state.mu.Unlock()
+	case !firing && wasFiring:
+		log.Printf("Metric alert %s recovered (issue #%d)", alert.Name, number)
+		comment := fmt.Sprintf("**Recovered** at `%s`: back at or below the threshold of %s", now.Format(time.RFC3339), formatValue(alert.Threshold))
+		if err := p.tracker.AddComment(number, comment); err != nil {
+			log.Printf("Warning: failed to comment on issue #%d: %v", number, err)
+		}
+		state.mu.Lock()
+		delete(state.firing, alert.Name)
+		state.mu.Unlock()
+	default:
+		p.debugf("Metric alert %s: peak %s (firing: %t)", alert.Name, formatValue(peak), firing)
+	}
+}
+
+// raiseMetricAlert creates the issue of an alert that crossed its threshold
+// or, if the alert already has one, comments on it and reopens it. It
+// returns the issue number.
+func (p *Processor) raiseMetricAlert(alert MetricAlert, peak float64, now time.Time) (int64, error) {
+	client := p.tracker
+	bugID := alert.bugID()
+	bugIDLabel := "bugid:" + bugID
+
+	unlock := p.bugLocks.Lock(p.cacheKey(client, bugID))
+	defer unlock()
+
+	issues, err := client.SearchIssues(bugIDLabel)
+	if err != nil {
+		return 0, fmt.Errorf("failed to search issues in %s: %w", client.Repo(), err)
+	}
+	issues = withLabel(issues, bugIDLabel)
+
+	info := notifier.IssueInfo{
+		Title:     alert.title(),
+		BugID:     bugID,
+		Severity:  alert.Severity,
+		FirstSeen: now,
+	}
+
+	if len(issues) == 0 {
+		if err := client.EnsureLabel(bugIDLabel, p.bugIDLabelColor(bugIDLabel)); err != nil {
+			log.Printf("Warning: failed to create bugid label: %v", err)
+		}
+		labels := append([]string{"auto-generated", bugIDLabel, metricLabel, "severity:" + alert.Severity}, p.defaultLabels...)
+
+		issue, err := client.CreateIssueFromRequest(gitea.CreateIssueRequest{
+			Title:     info.Title,
+			Body:      generateMetricBody(alert, peak, now),
+			Milestone: p.milestone,
+		}, labels)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create issue for metric alert %s: %w", alert.Name, err)
+		}
+		log.Printf("Metric alert %s fired: created issue #%d (peak %s)", alert.Name, issue.Number, formatValue(peak))
+
+		info.Number = issue.Number
+		info.URL = issue.HTMLURL
+		info.Labels = labels
+		info.Occurrences = 1
+		p.summary.record(client.Repo(), info, true, false)
+		for _, n := range p.notifiersFor(info.Severity) {
+			if err := n.NotifyNewIssue(&info); err != nil {
+				log.Printf("Error sending notification: %v", err)
+			}
+		}
+		return issue.Number, nil
+	}
+
+	existing := issues[0]
+	log.Printf("Metric alert %s fired again: issue #%d (peak %s)", alert.Name, existing.Number, formatValue(peak))
+
+	comment := fmt.Sprintf("**Threshold crossed again** at `%s`: peak %s (threshold %s)",
+		now.Format(time.RFC3339), formatValue(peak), formatValue(alert.Threshold))
+	if err := client.AddComment(existing.Number, comment); err != nil {
+		log.Printf("Warning: failed to comment on issue #%d: %v", existing.Number, err)
+	}
+
+	reopened := false
+	if existing.State == "closed" {
+		if err := client.ReopenIssue(existing.Number); err != nil {
+			log.Printf("Warning: failed to reopen issue #%d: %v", existing.Number, err)
+		} else {
+			reopened = true
+			log.Printf("Reopened issue #%d", existing.Number)
+			p.clearResolution(client, existing)
+		}
+	}
+
+	info.Number = existing.Number
+	info.Title = existing.Title
+	info.URL = existing.HTMLURL
+	info.Labels = existing.LabelNames()
+	p.summary.record(client.Repo(), info, false, reopened)
+	if reopened {
+		for _, n := range p.notifiersFor(info.Severity) {
+			if err := n.NotifyReopenedIssue(&info); err != nil {
+				log.Printf("Error sending notification: %v", err)
+			}
+		}
+	}
+	return existing.Number, nil
+}
+
+// generateMetricBody renders the issue body of a metric alert
+func generateMetricBody(alert MetricAlert, peak float64, now time.Time) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Metric Alert\n\n")
+	sb.WriteString(fmt.Sprintf("**Alert:** %s\n\n", alert.Name))
+	sb.WriteString(fmt.Sprintf("**Query:** `%s`\n", alert.Query))
+	sb.WriteString(fmt.Sprintf("**Threshold:** > %s\n", formatValue(alert.Threshold)))
+	sb.WriteString(fmt.Sprintf("**Peak Value:** %s\n", formatValue(peak)))
+
+	sb.WriteString("\n## Timeline\n\n")
+	sb.WriteString(fmt.Sprintf("- **First Crossed:** `%s`\n", now.Format(time.RFC3339)))
+
+	sb.WriteString("\n---\n")
+	sb.WriteString(fmt.Sprintf("*Bug ID: `%s`*\n", alert.bugID()))
+	sb.WriteString("*Auto-generated by issue-tracker*\n")
+	return sb.String()
+}
+
+// peakValue returns the highest value of all series, and false if there are
+// no values (e.g. a filtering query matched nothing)
+func peakValue(series []loki.Series) (float64, bool) {
+	var peak float64
+	found := false
+	for _, s := range series {
+		for _, v := range s.Values {
+			if !found || v.Value > peak {
+				peak = v.Value
+				found = true
+			}
+		}
+	}
+	return peak, found
+}
+
+// formatValue formats a metric value with at most three decimals
+func formatValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
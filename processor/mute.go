@@ -0,0 +1,31 @@
+package processor
+
+import (
+	"vigil/gitea"
+	"vigil/loki"
+)
+
+// MutedLabel marks an issue that is known and being worked on. Occurrences
+// of its bug ID are still counted, but the issue isn't commented on,
+// reopened or announced until the label is removed.
+const MutedLabel = "vigil:muted"
+
+// recordMuted counts an occurrence of a muted issue without writing to the
+// issue tracker. key is the repository-scoped cache key.
+func (p *Processor) recordMuted(client IssueTracker, existing gitea.Issue, entry loki.LogEntry, key string) {
+	baseline := p.knownOccurrences(existing, key)
+	occurrences := baseline + 1
+	if p.commentMode == CommentModeStats {
+		// Keep the rolling stats current so they're complete once unmuted
+		occurrences, _, _ = p.stats.record(key, entry, baseline)
+	}
+
+	p.cachePut(key, existing.Number, entry.Timestamp, occurrences)
+	p.debugf("Issue #%d is muted, counted occurrence %d without updating it", existing.Number, occurrences)
+	p.recordRecent(entry, OutcomeMuted, RecentEntry{
+		Title:       existing.Title,
+		Occurrences: occurrences,
+		Repo:        client.Repo(),
+		IssueNumber: existing.Number,
+	})
+}
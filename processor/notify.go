@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"vigil/notifier"
+)
+
+// ParseNotifyRoutes parses a comma-separated list of severity=notifiers
+// routes, where notifiers is a |-separated list of notifier names, e.g.
+// "critical=slack|twilio,error=slack"
+func ParseNotifyRoutes(spec string) (map[string][]string, error) {
+	routes := make(map[string][]string)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		severity, names, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route %q (expected severity=notifier|notifier)", item)
+		}
+		severity, err := ParseSeverity(severity)
+		if err != nil {
+			return nil, err
+		}
+
+		var list []string
+		for _, name := range strings.Split(names, "|") {
+			if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+				list = append(list, name)
+			}
+		}
+		routes[severity] = list
+	}
+
+	return routes, nil
+}
+
+// notifiersFor returns the notifiers an issue of the given severity is sent
+// to. Severities without a route go to all notifiers, except those whose
+// minimum severity it is below.
+func (p *Processor) notifiersFor(severity string) []notifier.Notifier {
+	if p.notificationsSuppressed() {
+		return nil
+	}
+
+	names, routed := p.notifyRoutes[severity]
+
+	var selected []notifier.Notifier
+	for _, n := range p.notifiers {
+		if routed && !contains(names, n.Name()) {
+			continue
+		}
+		if !notifier.MeetsSeverity(severity, p.notifierMinSev[n.Name()]) {
+			continue
+		}
+		selected = append(selected, n)
+	}
+	return selected
+}
+
+// summaryIssues returns the issues of a summary a notifier is sent, leaving
+// out those below its minimum severity
+func (p *Processor) summaryIssues(n notifier.Notifier, issues []*notifier.IssueInfo) []*notifier.IssueInfo {
+	minimum := p.notifierMinSev[n.Name()]
+	if minimum == "" {
+		return issues
+	}
+
+	var selected []*notifier.IssueInfo
+	for _, issue := range issues {
+		if notifier.MeetsSeverity(issue.Severity, minimum) {
+			selected = append(selected, issue)
+		}
+	}
+	return selected
+}
+
+// notifyOccurrence updates the messages notifiers keep for an issue with a
+// new occurrence
+func (p *Processor) notifyOccurrence(issue *notifier.IssueInfo) {
+	for _, n := range p.notifiersFor(issue.Severity) {
+		if err := notifier.SendOccurrence(n, issue); err != nil {
+			log.Printf("Warning: failed to update %s notification for issue #%d: %v", n.Name(), issue.Number, err)
+		}
+	}
+}
+
+// contains reports whether a list contains a string
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationsSuppressed reports whether no notifications are sent at the
+// moment, during a backfill or a maintenance window
+func (p *Processor) notificationsSuppressed() bool {
+	return p.muted || p.maintenance.suppressed()
+}
@@ -0,0 +1,100 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"vigil/gitea"
+)
+
+// occurrenceLabelPrefix prefixes the bucketed occurrence count labels
+const occurrenceLabelPrefix = "occurrences:"
+
+// occurrenceBuckets are the occurrence thresholds, highest first, and the
+// label applied once an issue reaches them
+var occurrenceBuckets = []struct {
+	min   int
+	label string
+}{
+	{1000, "occurrences:1000+"},
+	{100, "occurrences:100+"},
+	{10, "occurrences:10+"},
+	{1, "occurrences:1"},
+}
+
+// occurrenceLabelColors are the colors of the occurrence labels, getting
+// darker with impact
+var occurrenceLabelColors = map[string]string{
+	"occurrences:1":     "c5def5",
+	"occurrences:10+":   "84b6eb",
+	"occurrences:100+":  "1d76db",
+	"occurrences:1000+": "0e2a6b",
+}
+
+// occurrenceLabel returns the bucket label for an occurrence count
+func occurrenceLabel(occurrences int) string {
+	for _, bucket := range occurrenceBuckets {
+		if occurrences >= bucket.min {
+			return bucket.label
+		}
+	}
+	return occurrenceBuckets[len(occurrenceBuckets)-1].label
+}
+
+// updateOccurrenceLabel moves an issue to the bucket label for its
+// occurrence count, removing any other bucket label
+func (p *Processor) updateOccurrenceLabel(client IssueTracker, issue gitea.Issue, occurrences int) {
+	label := occurrenceLabel(occurrences)
+	if issue.HasLabel(label) {
+		return
+	}
+
+	for _, existing := range issue.Labels {
+		if strings.HasPrefix(existing.Name, occurrenceLabelPrefix) {
+			if err := client.RemoveLabel(issue.Number, existing.Name); err != nil {
+				log.Printf("Warning: failed to remove label %s from issue #%d: %v", existing.Name, issue.Number, err)
+			}
+		}
+	}
+
+	if err := client.AddLabels(issue.Number, []string{label}); err != nil {
+		log.Printf("Warning: failed to add label %s to issue #%d: %v", label, issue.Number, err)
+		return
+	}
+	p.debugf("Issue #%d moved to %s", issue.Number, label)
+}
+
+// occurrencesLine matches the "Occurrences" line of the issue body timeline,
+// which is kept up to date when occurrences aren't commented on
+var occurrencesLine = regexp.MustCompile("(?m)^- \\*\\*Occurrences:\\*\\* (\\d+)$")
+
+// knownOccurrences returns the occurrence count of an issue so far when it
+// can't be derived from its comments alone: the cached count, or else the
+// larger of the count in the body and the comments plus the original.
+// key is the repository-scoped cache key.
+func (p *Processor) knownOccurrences(existing gitea.Issue, key string) int {
+	if cached, err := p.cache.Get(key); err == nil && cached != nil && cached.Occurrences > 0 {
+		return cached.Occurrences
+	}
+
+	known := existing.Comments + 1
+	if m := occurrencesLine.FindStringSubmatch(existing.Body); m != nil {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > known {
+			known = n
+		}
+	}
+	return known
+}
+
+// withOccurrences sets the "Occurrences" line of the body timeline, adding
+// it after "Last Seen" if missing
+func withOccurrences(body string, occurrences int) string {
+	line := fmt.Sprintf("- **Occurrences:** %d", occurrences)
+	if occurrencesLine.MatchString(body) {
+		return occurrencesLine.ReplaceAllLiteralString(body, line)
+	}
+	return lastSeenLine.ReplaceAllString(body, "${0}\n"+line)
+}
@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"vigil/loki"
+)
+
+// Bounds for subdividing a query window that hit the result limit
+const (
+	minPaginateWindow = time.Second
+	maxPaginateDepth  = 8 // at most 2^8 sub-queries per window
+)
+
+// queryWindow queries the entries logged between start and end. When the
+// query hits the result limit and split is set, the window is split in half
+// and each half is queried again until every chunk fits.
+// Entries on the boundary of two chunks may be returned twice; the seen set
+// skips them.
+func (p *Processor) queryWindow(ctx context.Context, start, end time.Time, split bool, depth int) ([]loki.LogEntry, error) {
+	entries, truncated, err := p.lokiClient.QueryRange(ctx, p.query, start, end, p.queryLimit)
+	if err != nil || !truncated {
+		return entries, err
+	}
+
+	if !split {
+		log.Printf("Warning: Loki returned %d lines, the query limit; entries in the window may have been dropped (set LOKI_AUTO_PAGINATE, lower LOKI_POLL_INTERVAL or raise LOKI_QUERY_LIMIT)", p.queryLimit)
+		return entries, nil
+	}
+	if end.Sub(start) <= minPaginateWindow || depth >= maxPaginateDepth {
+		log.Printf("Warning: Loki returned %d lines, the query limit, for %s to %s and the window can't be split further; entries may have been dropped (raise LOKI_QUERY_LIMIT)",
+			p.queryLimit, start.Format(time.RFC3339Nano), end.Format(time.RFC3339Nano))
+		return entries, nil
+	}
+
+	mid := start.Add(end.Sub(start) / 2)
+	p.debugf("Query limit hit for %s to %s, splitting at %s", start.Format(time.RFC3339Nano), end.Format(time.RFC3339Nano), mid.Format(time.RFC3339Nano))
+
+	older, err := p.queryWindow(ctx, start, mid, true, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := p.queryWindow(ctx, mid, end, true, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return append(older, newer...), nil
+}
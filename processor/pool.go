@@ -0,0 +1,55 @@
+package processor
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"vigil/loki"
+)
+
+// processConcurrently processes entries on a pool of workers. Entries are
+// routed to workers by bug ID, so all entries for the same bug ID are handled
+// in order by a single worker and never race on search-then-create. Distinct
+// bug IDs are processed in parallel. Entries not yet dispatched when the
+// context is cancelled are dropped.
+func (p *Processor) processConcurrently(ctx context.Context, entries []loki.LogEntry) {
+	workers := p.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	queues := make([]chan loki.LogEntry, workers)
+	var wg sync.WaitGroup
+	for i := range queues {
+		queues[i] = make(chan loki.LogEntry, len(entries))
+		wg.Add(1)
+		go func(queue <-chan loki.LogEntry) {
+			defer wg.Done()
+			for entry := range queue {
+				p.process(ctx, entry)
+			}
+		}(queues[i])
+	}
+
+dispatch:
+	for _, entry := range entries {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case queues[workerFor(p.bugID(entry), workers)] <- entry:
+		}
+	}
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+}
+
+// workerFor maps a bug ID to a worker index
+func workerFor(bugID string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(bugID))
+	return int(h.Sum32() % uint32(workers))
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestProcessConcurrentlySameBugID checks that entries sharing a bug ID,
+// interleaved with others, create a single issue and are commented on in
+// the order they were logged even with several workers. Run with -race.
+func TestProcessConcurrentlySameBugID(t *testing.T) {
+	const repeats = 8
+
+	var lines []string
+	for i := 1; i <= repeats; i++ {
+		lines = append(lines,
+			fmt.Sprintf(`{"level":"error","msg":"checkout failed","method":"POST","action":"/api/checkout","status":500,"requestId":"same-%d"}`, i),
+			fmt.Sprintf(`{"level":"error","msg":"lookup failed","method":"GET","action":"/api/items/%c","status":503,"requestId":"other-%d"}`, 'a'+i, i),
+		)
+	}
+
+	loki := newLokiServer(t, func(r *http.Request) []byte { return lokiStreams(lokiTime, lines...) })
+	tracker := newFakeGitea(t)
+	p := newTestProcessor(tracker, Config{
+		LokiURL:     loki.URL,
+		Lookback:    time.Hour,
+		Concurrency: 4,
+	})
+	p.poll(context.Background())
+
+	var number int64
+	for i, title := range tracker.created() {
+		if !strings.Contains(title, "/api/checkout") {
+			continue
+		}
+		if number != 0 {
+			t.Fatalf("issue #%d duplicates issue #%d: %s", i+1, number, title)
+		}
+		number = int64(i + 1)
+	}
+	if number == 0 {
+		t.Fatalf("no issue created for the shared bug ID, created: %q", tracker.created())
+	}
+	if got, want := len(tracker.created()), 1+repeats; got != want {
+		t.Errorf("created %d issues, want %d", got, want)
+	}
+
+	comments := tracker.commentsOn(number)
+	if len(comments) != repeats-1 {
+		t.Fatalf("issue #%d has %d comments, want %d", number, len(comments), repeats-1)
+	}
+	for i, comment := range comments {
+		want := fmt.Sprintf("Request ID: `same-%d`", i+2)
+		if !strings.Contains(comment, want) {
+			t.Errorf("comment %d = %q, want it to contain %q", i+1, comment, want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// priorityLabelPrefix prefixes priority labels, e.g. "priority:p1"
+const priorityLabelPrefix = "priority:"
+
+// priorityLabelColors are the colors of the usual priority labels, from most
+// to least urgent; other priorities get a color derived from their name
+var priorityLabelColors = map[string]string{
+	"priority:p0": "b60205", // dark red
+	"priority:p1": "d93f0b", // red
+	"priority:p2": "fbca04", // yellow
+	"priority:p3": "0e8a16", // green
+	"priority:p4": "c5def5", // pale blue
+}
+
+// ParsePriorityLabels parses a comma-separated list of severity=priority
+// mappings, e.g. "critical=p1,error=p2,warning=p3", into severity ->
+// priority label
+func ParsePriorityLabels(spec string) (map[string]string, error) {
+	labels := make(map[string]string)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		severity, priority, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid priority %q (expected severity=priority)", item)
+		}
+		severity, err := ParseSeverity(severity)
+		if err != nil {
+			return nil, err
+		}
+
+		priority = strings.ToLower(strings.TrimSpace(priority))
+		if priority == "" {
+			return nil, fmt.Errorf("invalid priority %q (expected severity=priority)", item)
+		}
+		labels[severity] = sanitizeLabel(priorityLabelPrefix + priority)
+	}
+
+	return labels, nil
+}
+
+// priorityLabelColor returns the color of a priority label
+func priorityLabelColor(label string) string {
+	if color, ok := priorityLabelColors[label]; ok {
+		return color
+	}
+	return labelColor(label)
+}
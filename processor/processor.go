@@ -2,111 +2,654 @@ package processor
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"regexp"
 	"strings"
+	"text/template"
 	"time"
 
+	"vigil/cache"
 	"vigil/gitea"
 	"vigil/loki"
 	"vigil/notifier"
 )
 
-// Processor handles log processing and issue creation in Gitea
+// Processor handles log processing and issue creation in the issue tracker
 type Processor struct {
-	giteaClient  *gitea.Client
-	lokiClient   *loki.Client
-	notifiers    []notifier.Notifier
-	pollInterval time.Duration
-	lookback     time.Duration
-	lastPoll     time.Time
+	tracker          IssueTracker
+	repoRoutes       map[string]IssueTracker
+	unroutedLabel    string
+	unroutedServices *unroutedServices
+	cache            cache.Cache
+	queue            *Queue
+	dedupTTL         time.Duration
+	sampler          *occurrenceSampler
+	bugLocks         *keyedMutex
+	lokiClient       *loki.Client
+	query            string
+	notifiers        []notifier.Notifier
+	notifyRoutes     map[string][]string
+	notifierMinSev   map[string]string
+	muted            bool // no notifications are sent, e.g. during a backfill
+	maintenance      *maintenance
+	mode             string
+	minSeverity      string
+	severityKeywords []SeverityKeyword
+	createClosed     bool
+	debug            bool
+	bugIDFields      []string
+	dedupByTrace     bool
+	latencyThreshold int
+	pollInterval     time.Duration
+	pollJitter       time.Duration
+	lookback         time.Duration
+	overlap          time.Duration
+	queryLimit       int
+	autoPaginate     bool
+	lastPoll         time.Time
+	seen             *seenEntries
+	concurrency      int
+
+	defaultLabels []string
+	defaultEnv    string
+	labelFields   []string
+	priorities    map[string]string
+	milestone     int64
+	maxBodyBytes  int
+	stackLines    int
+	contextLines  int
+	relatedIssues bool
+	maxTitleLen   int
+	userMap       map[string]string
+	bodySections  []string
+
+	traceURLTemplate *template.Template
+	logsURLTemplate  *template.Template
+	rateWindow       time.Duration
+
+	notifyMode     string
+	digestInterval time.Duration
+	digest         *digest
+
+	digestSchedule *DigestSchedule
+	summary        *periodSummary
+
+	watchdogTimeout time.Duration
+	liveness        liveness
+
+	metricAlerts []MetricAlert
+	metricState  *metricAlerts
+
+	resolveAfter time.Duration
+	resolveClose bool
+	active       *activeIssues
+
+	deescalateAfter time.Duration
+	escalated       *activeIssues
+
+	closeCommentTemplate *template.Template
+
+	bugIDLabelColorMode string
+
+	commentMode          string
+	suppressOpenComments bool
+	stats                *statsTracker
+
+	reopenMode      string
+	reopenThreshold int
+	closedCounts    *closedOccurrences
+
+	storm *storm
+
+	recent *recentEntries
+
+	ignoreEndpoints []string
+	ignoreMessages  []*regexp.Regexp
 }
 
+// DefaultQueryLimit is the maximum number of lines a poll query returns
+const DefaultQueryLimit = 1000
+
+// Processing modes
+const (
+	ModePoll = "poll"
+	ModeTail = "tail"
+)
+
 // Config holds processor configuration
 type Config struct {
 	LokiURL      string
+	Mode         string // "poll" (default) or "tail"
 	PollInterval time.Duration
+	PollJitter   time.Duration // each poll interval is randomized by up to ±PollJitter
 	Lookback     time.Duration
+	Overlap      time.Duration // how far each query reaches back before the previous poll
+	QueryLimit   int           // maximum lines per poll query (default: DefaultQueryLimit)
+	AutoPaginate bool          // split poll windows that hit QueryLimit and query each part
+	Concurrency  int           // number of workers processing distinct bug IDs in parallel
+	MinSeverity  string        // entries below this severity are not turned into issues
+	CreateClosed bool          // track entries below MinSeverity in closed issues instead of skipping them
+	Debug        bool
+	BugIDFields  []string // fields hashed into auto-generated bug IDs (default: DefaultBugIDFields)
+	DedupByTrace bool     // process one entry per trace ID within a poll
+
+	// DedupTTL is how long after an issue was closed a recurrence still
+	// reopens it; later ones get a new issue (0 for no limit)
+	DedupTTL time.Duration
+
+	// SeverityKeywords infer the severity of entries without an HTTP status
+	// from their message, also making them errors (see
+	// ParseSeverityKeywords); the Query must select them (see
+	// BuildErrorQuery)
+	SeverityKeywords []SeverityKeyword
+
+	// BugIDLabelColorMode is "hash" (default) to give each bug ID label a
+	// stable color of its own or "fixed" to color them all blue
+	BugIDLabelColorMode string
+
+	// LatencyThresholdMs also tracks requests slower than this many
+	// milliseconds as issues (0 disables it); the Query must select them
+	// (see BuildErrorQuery)
+	LatencyThresholdMs int
+
+	// DefaultLabels are added to every created issue besides auto-generated
+	DefaultLabels []string
+	// DefaultEnv is the environment assumed for entries without one
+	DefaultEnv string
+	// LabelFields are log fields whose values are added as "field:value" labels
+	LabelFields []string
+	// PriorityLabels maps severities to the priority label of their issues
+	// (see ParsePriorityLabels)
+	PriorityLabels map[string]string
+	// Milestone is the Gitea milestone ID assigned to created issues (0 for none)
+	Milestone int64
+	// MaxBodyBytes limits the size of created issue bodies (0 for no limit)
+	MaxBodyBytes int
+	// MaxTitleLength limits the length of created issue titles in
+	// characters (default: DefaultMaxTitleLength, at most MaxTitleLength)
+	MaxTitleLength int
+	// StacktraceLines limits the stack trace lines shown in issue bodies
+	// (0 for no limit)
+	StacktraceLines int
+	// ContextLines is the number of lines of the entry's stream logged before
+	// and after it shown in issue bodies (0 to disable)
+	ContextLines int
+	// BodySections are the sections of created issue bodies in order (nil
+	// for DefaultBodySections)
+	BodySections []string
+	// RelatedIssues labels new issues with their source function and links
+	// them with the issues sharing their function or error type
+	RelatedIssues bool
+	// UserMap maps application user IDs to the issue tracker usernames
+	// mentioned in new issues (see LoadUserMap)
+	UserMap map[string]string
+
+	// TraceURLTemplate and LogsURLTemplate render deep links from the entry's
+	// trace ID and request ID (see ParseLinkTemplate)
+	TraceURLTemplate *template.Template
+	LogsURLTemplate  *template.Template
+
+	// RateWindow is the window over which the current error rate is counted
+	// for new issues (0 disables the lookup)
+	RateWindow time.Duration
+
+	// NotifyMode is "immediate" (default) or "digest", where reopen and
+	// occurrence notifications are summarized every DigestInterval
+	NotifyMode     string
+	DigestInterval time.Duration
+
+	// DigestSchedule, if set, sends a summary of new, reopened and most
+	// recurring issues on a schedule, independent of NotifyMode
+	DigestSchedule *DigestSchedule
+
+	// NotifyRoutes maps severities to the names of the notifiers their
+	// issues are sent to; severities without a route go to all notifiers
+	NotifyRoutes map[string][]string
+	// NotifierMinSeverity maps notifier names to the lowest severity they
+	// are sent; notifiers without one get every severity
+	NotifierMinSeverity map[string]string
+
+	// MaintenanceUntil and MaintenanceFile define a maintenance window in
+	// which issues are processed as usual but no notifications are sent:
+	// until the given time, and while the file exists
+	MaintenanceUntil time.Time
+	MaintenanceFile  string
+
+	// WatchdogTimeout exits vigil when no poll has succeeded for this long
+	// in poll mode (0 disables the watchdog)
+	WatchdogTimeout time.Duration
+
+	// MetricAlerts are LogQL metric queries evaluated every poll interval
+	// that raise an issue when they cross their threshold
+	MetricAlerts []MetricAlert
+
+	// ResolveAfter is the quiet period after which an issue with
+	// occurrences is announced as resolved (0 disables resolution);
+	// ResolveClose also closes it
+	ResolveAfter time.Duration
+	ResolveClose bool
+
+	// DeescalateAfter is the quiet period after which an open issue loses
+	// its EscalatedLabel (0 disables de-escalation)
+	DeescalateAfter time.Duration
+
+	// CloseCommentTemplate renders the comment posted when an issue is
+	// closed after the quiet period (nil for the default)
+	CloseCommentTemplate *template.Template
+
+	// CommentMode is "occurrence" (default) to comment on every occurrence
+	// or "stats" to maintain a single rolling stats comment
+	CommentMode string
+
+	// SuppressOpenComments stops commenting on occurrences of open issues
+	// in occurrence mode; only the count and Last Seen in the body are
+	// updated
+	SuppressOpenComments bool
+
+	// ReopenMode decides whether closed issues are reopened when their error
+	// recurs (see ParseReopenMode); in threshold mode they are reopened after
+	// ReopenThreshold occurrences since they were closed
+	ReopenMode      string
+	ReopenThreshold int
+
+	// StormThreshold is the number of distinct new bug IDs within
+	// StormWindow above which new errors are collapsed into a single storm
+	// issue (0 disables storm detection)
+	StormThreshold int
+	StormWindow    time.Duration
+
+	// RecentSize is the number of processed errors kept for Recent
+	// (0 disables the buffer)
+	RecentSize int
+
+	IgnoreEndpoints []string         // globs matched against the entry endpoint
+	IgnoreMessages  []*regexp.Regexp // patterns matched against the entry message
+
+	// Query is the LogQL query selecting candidate errors (see BuildErrorQuery)
+	Query       string
+	LokiOptions []loki.Option
+
+	// Cache maps bug IDs to issues to avoid searching Gitea (default: in-memory)
+	Cache cache.Cache
+
+	// SampleRate is the fraction of occurrences written to the issue tracker
+	// once a bug ID occurs more than SampleThreshold times a minute (default:
+	// DefaultSampleThreshold); the others are only counted. 0 disables
+	// sampling.
+	SampleRate      float64
+	SampleThreshold int
+
+	// Queue, if set, persists entries whose issue couldn't be created or
+	// updated and replays them in the background
+	Queue *Queue
+
+	// RepoRoutes maps service names to the client of the repository their
+	// issues are filed in; other entries go to the default repository
+	RepoRoutes map[string]IssueTracker
+
+	// UnroutedLabel is added to issues of services without a route
+	// (default: DefaultUnroutedLabel)
+	UnroutedLabel string
 }
 
 // NewProcessor creates a new log processor
-func NewProcessor(giteaClient *gitea.Client, cfg Config, notifiers []notifier.Notifier) *Processor {
+func NewProcessor(tracker IssueTracker, cfg Config, notifiers []notifier.Notifier) *Processor {
+	bugCache := cfg.Cache
+	if bugCache == nil {
+		bugCache = cache.NewMemory()
+	}
+
+	var errorStorm *storm
+	if cfg.StormThreshold > 0 {
+		errorStorm = newStorm(cfg.StormThreshold, cfg.StormWindow)
+	}
+
+	var recent *recentEntries
+	if cfg.RecentSize > 0 {
+		recent = newRecentEntries(cfg.RecentSize)
+	}
+
+	var summary *periodSummary
+	if cfg.DigestSchedule != nil {
+		summary = newPeriodSummary()
+	}
+
+	queryLimit := cfg.QueryLimit
+	if queryLimit <= 0 {
+		queryLimit = DefaultQueryLimit
+	}
+
+	unroutedLabel := cfg.UnroutedLabel
+	if unroutedLabel == "" {
+		unroutedLabel = DefaultUnroutedLabel
+	}
+
+	maxTitleLength := cfg.MaxTitleLength
+	if maxTitleLength <= 0 {
+		maxTitleLength = DefaultMaxTitleLength
+	}
+
+	// The context is only looked up when the body shows it
+	contextLines := cfg.ContextLines
+	if cfg.BodySections != nil && !contains(cfg.BodySections, SectionContext) {
+		contextLines = 0
+	}
+
+	query := cfg.Query
+	if query == "" {
+		query, _ = BuildErrorQuery("", "", cfg.LatencyThresholdMs, cfg.SeverityKeywords)
+	}
+
+	var sampler *occurrenceSampler
+	if cfg.SampleRate > 0 && cfg.SampleRate < 1 {
+		sampler = newOccurrenceSampler(cfg.SampleRate, cfg.SampleThreshold)
+	}
+
 	return &Processor{
-		giteaClient:  giteaClient,
-		lokiClient:   loki.NewClient(cfg.LokiURL),
-		notifiers:    notifiers,
-		pollInterval: cfg.PollInterval,
-		lookback:     cfg.Lookback,
-		lastPoll:     time.Now().Add(-cfg.Lookback),
+		tracker:          tracker,
+		repoRoutes:       cfg.RepoRoutes,
+		unroutedLabel:    unroutedLabel,
+		unroutedServices: newUnroutedServices(),
+		cache:            bugCache,
+		queue:            cfg.Queue,
+		dedupTTL:         cfg.DedupTTL,
+		sampler:          sampler,
+		bugLocks:         newKeyedMutex(),
+		lokiClient:       loki.NewClient(cfg.LokiURL, cfg.LokiOptions...),
+		query:            query,
+		notifiers:        notifiers,
+		notifyRoutes:     cfg.NotifyRoutes,
+		notifierMinSev:   cfg.NotifierMinSeverity,
+		maintenance:      newMaintenance(cfg.MaintenanceUntil, cfg.MaintenanceFile),
+		mode:             cfg.Mode,
+		minSeverity:      cfg.MinSeverity,
+		severityKeywords: cfg.SeverityKeywords,
+		createClosed:     cfg.CreateClosed,
+		debug:            cfg.Debug,
+		bugIDFields:      cfg.BugIDFields,
+		dedupByTrace:     cfg.DedupByTrace,
+		latencyThreshold: cfg.LatencyThresholdMs,
+		pollInterval:     cfg.PollInterval,
+		pollJitter:       cfg.PollJitter,
+		lookback:         cfg.Lookback,
+		overlap:          cfg.Overlap,
+		queryLimit:       queryLimit,
+		autoPaginate:     cfg.AutoPaginate,
+		lastPoll:         time.Now().Add(-cfg.Lookback),
+		seen:             newSeenEntries(),
+		concurrency:      cfg.Concurrency,
+
+		defaultLabels: cfg.DefaultLabels,
+		defaultEnv:    cfg.DefaultEnv,
+		labelFields:   cfg.LabelFields,
+		priorities:    cfg.PriorityLabels,
+		milestone:     cfg.Milestone,
+		maxBodyBytes:  cfg.MaxBodyBytes,
+		stackLines:    cfg.StacktraceLines,
+		contextLines:  contextLines,
+		relatedIssues: cfg.RelatedIssues,
+		maxTitleLen:   maxTitleLength,
+		userMap:       cfg.UserMap,
+		bodySections:  cfg.BodySections,
+
+		traceURLTemplate: cfg.TraceURLTemplate,
+		logsURLTemplate:  cfg.LogsURLTemplate,
+		rateWindow:       cfg.RateWindow,
+
+		notifyMode:     cfg.NotifyMode,
+		digestInterval: cfg.DigestInterval,
+		digest:         newDigest(),
+
+		digestSchedule: cfg.DigestSchedule,
+		summary:        summary,
+
+		watchdogTimeout: cfg.WatchdogTimeout,
+
+		metricAlerts: cfg.MetricAlerts,
+		metricState:  newMetricAlerts(),
+
+		resolveAfter: cfg.ResolveAfter,
+		resolveClose: cfg.ResolveClose,
+		active:       newActiveIssues(),
+
+		deescalateAfter: cfg.DeescalateAfter,
+		escalated:       newActiveIssues(),
+
+		closeCommentTemplate: cfg.CloseCommentTemplate,
+
+		bugIDLabelColorMode: cfg.BugIDLabelColorMode,
+
+		commentMode:          cfg.CommentMode,
+		suppressOpenComments: cfg.SuppressOpenComments,
+		stats:                newStatsTracker(),
+
+		reopenMode:      cfg.ReopenMode,
+		reopenThreshold: cfg.ReopenThreshold,
+		closedCounts:    newClosedOccurrences(),
+
+		storm: errorStorm,
+
+		recent: recent,
+
+		ignoreEndpoints: cfg.IgnoreEndpoints,
+		ignoreMessages:  cfg.IgnoreMessages,
 	}
 }
 
 // Start begins the log polling loop
 func (p *Processor) Start(ctx context.Context) {
-	log.Printf("Starting log processor (poll interval: %s, lookback: %s)", p.pollInterval, p.lookback)
+	if p.mode == ModeTail {
+		log.Printf("Starting log processor in tail mode (lookback: %s)", p.lookback)
+	} else {
+		log.Printf("Starting log processor (poll interval: %s, lookback: %s)", p.pollInterval, p.lookback)
+	}
 
-	// Test Gitea connection
-	if err := p.giteaClient.TestConnection(); err != nil {
-		log.Printf("WARNING: Gitea connection test failed: %v", err)
+	// Test issue tracker connection
+	if err := p.tracker.TestConnection(); err != nil {
+		log.Printf("WARNING: issue tracker connection test failed: %v", err)
 		log.Println("Will retry on first poll...")
 	} else {
-		log.Println("Gitea connection successful")
-		// Ensure required labels exist
-		p.ensureLabels()
+		log.Printf("Issue tracker connection successful (%s)", p.tracker.Repo())
+		// Ensure required labels exist in every repository issues are filed in
+		for _, client := range p.clients() {
+			p.ensureLabels(client)
+		}
 	}
 
-	ticker := time.NewTicker(p.pollInterval)
-	defer ticker.Stop()
+	if p.notifyMode == NotifyModeDigest {
+		log.Printf("Digest notifications enabled (interval: %s)", p.digestInterval)
+		go p.runDigest(ctx)
+	}
+
+	if p.digestSchedule != nil {
+		log.Printf("Scheduled digest enabled (%s)", p.digestSchedule)
+		go p.runScheduledDigest(ctx)
+	}
+
+	if p.resolveAfter > 0 {
+		log.Printf("Resolution tracking enabled (quiet period: %s, close: %t)", p.resolveAfter, p.resolveClose)
+		go p.runResolver(ctx)
+	}
+
+	if p.deescalateAfter > 0 {
+		log.Printf("De-escalation enabled (quiet period: %s)", p.deescalateAfter)
+		go p.runDeescalator(ctx)
+	}
+
+	if len(p.metricAlerts) > 0 {
+		log.Printf("Metric alerts enabled (%d alerts)", len(p.metricAlerts))
+		go p.runMetricAlerts(ctx)
+	}
+
+	if p.queue != nil {
+		go p.runQueue(ctx)
+	}
+
+	p.liveness.mu.Lock()
+	p.liveness.started = time.Now()
+	p.liveness.mu.Unlock()
+
+	if p.mode == ModeTail {
+		p.tail(ctx)
+		return
+	}
+
+	if p.watchdogTimeout > 0 {
+		log.Printf("Watchdog enabled (timeout: %s)", p.watchdogTimeout)
+		go p.runWatchdog(ctx)
+	}
 
 	// Initial poll
-	p.poll()
+	p.poll(ctx)
+
+	// A timer reset after every poll rather than a ticker, so each interval
+	// can be jittered independently
+	timer := time.NewTimer(p.nextPollDelay())
+	defer timer.Stop()
 
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Stopping log processor")
 			return
-		case <-ticker.C:
-			p.poll()
+		case <-timer.C:
+			p.poll(ctx)
+			timer.Reset(p.nextPollDelay())
 		}
 	}
 }
 
-// ensureLabels creates required labels if they don't exist
-func (p *Processor) ensureLabels() {
+// nextPollDelay returns the poll interval randomized by up to ±pollJitter
+func (p *Processor) nextPollDelay() time.Duration {
+	if p.pollJitter <= 0 {
+		return p.pollInterval
+	}
+
+	delay := p.pollInterval + time.Duration(rand.Int63n(int64(2*p.pollJitter)+1)) - p.pollJitter
+	if delay < time.Second {
+		delay = time.Second
+	}
+	return delay
+}
+
+// debugf logs a message only when debug logging is enabled
+func (p *Processor) debugf(format string, args ...interface{}) {
+	if p.debug {
+		log.Printf("DEBUG: "+format, args...)
+	}
+}
+
+// ensureLabels creates required labels in a repository if they don't exist
+func (p *Processor) ensureLabels(client IssueTracker) {
 	labels := map[string]string{
 		"auto-generated":    "808080", // gray
+		performanceLabel:    "5319e7", // purple
+		metricLabel:         "006b75", // teal
+		MutedLabel:          "cccccc", // light gray
 		"severity:critical": "ff0000", // red
 		"severity:error":    "ff9900", // orange
+		"severity:warning":  "ffcc00", // yellow
+	}
+
+	for name, color := range categoryLabelColors {
+		labels[name] = color
+	}
+
+	for name, color := range occurrenceLabelColors {
+		labels[name] = color
+	}
+
+	for name, color := range resolutionLabelColors {
+		labels[name] = color
+	}
+
+	if p.deescalateAfter > 0 {
+		labels[EscalatedLabel] = "b60205" // dark red
+	}
+
+	for _, name := range p.priorities {
+		labels[name] = priorityLabelColor(name)
+	}
+
+	if len(p.repoRoutes) > 0 && client.Repo() == p.tracker.Repo() {
+		labels[p.unroutedLabel] = "e99695" // pink
+	}
+
+	for _, name := range p.defaultLabels {
+		if _, ok := labels[name]; !ok {
+			labels[name] = "808080" // gray
+		}
 	}
 
 	for name, color := range labels {
-		if err := p.giteaClient.EnsureLabel(name, color); err != nil {
-			log.Printf("Warning: failed to ensure label %s: %v", name, err)
+		if err := client.EnsureLabel(name, color); err != nil {
+			log.Printf("Warning: failed to ensure label %s in %s: %v", name, client.Repo(), err)
+		}
+	}
+}
+
+// tail streams error logs from Loki, reconnecting with backoff on disconnect
+func (p *Processor) tail(ctx context.Context) {
+	const (
+		minBackoff = time.Second
+		maxBackoff = time.Minute
+	)
+	backoff := minBackoff
+
+	for {
+		connectedAt := time.Now()
+		err := p.lokiClient.Tail(ctx, p.query, p.lastPoll, func(entry loki.LogEntry) {
+			// Resume just after the last seen entry on reconnect
+			p.lastPoll = entry.Timestamp.Add(time.Nanosecond)
+			p.liveness.polled(time.Now(), true)
+			p.handleEntry(ctx, entry)
+		})
+		if ctx.Err() != nil {
+			log.Println("Stopping log processor")
+			return
+		}
+
+		// Reset backoff if the connection was healthy for a while
+		if time.Since(connectedAt) > maxBackoff {
+			backoff = minBackoff
+		}
+
+		log.Printf("Loki tail disconnected: %v (reconnecting in %s)", err, backoff)
+		select {
+		case <-ctx.Done():
+			log.Println("Stopping log processor")
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
 		}
 	}
 }
 
 // poll queries Loki for new error logs
-func (p *Processor) poll() {
+func (p *Processor) poll(ctx context.Context) {
 	now := time.Now()
-	start := p.lastPoll
 
-	// Query for error logs - use line filter first (more reliable), then parse JSON
-	// The Go code will do final filtering via IsError()
-	query := `{container=~".+"} |~ "ERROR|\"status\":5[0-9]{2}" | json`
+	// Reach back before the last poll to catch entries that were ingested late
+	start := p.lastPoll.Add(-p.overlap)
 
-	entries, err := p.lokiClient.QueryRange(query, start, now, 1000)
+	entries, err := p.queryWindow(ctx, start, now, p.autoPaginate, 0)
 	if err != nil {
 		log.Printf("Error querying Loki: %v", err)
+		p.liveness.polled(time.Now(), false)
 		return
 	}
+	// The poll completes once its entries have been processed
+	defer func() { p.liveness.polled(time.Now(), true) }()
 
 	p.lastPoll = now
+	p.seen.prune(start)
 
 	if len(entries) == 0 {
 		log.Printf("No entries found from Loki query")
@@ -115,157 +658,659 @@ func (p *Processor) poll() {
 
 	log.Printf("Found %d entries from Loki, filtering for errors...", len(entries))
 
+	if errorCount := p.processBatch(ctx, entries); errorCount > 0 {
+		log.Printf("Processed %d error entries", errorCount)
+	}
+}
+
+// processBatch filters the entries returned by a query and processes the
+// remaining errors concurrently. It returns the number of error entries.
+func (p *Processor) processBatch(ctx context.Context, entries []loki.LogEntry) int {
 	errorCount := 0
+	var pending []loki.LogEntry
 	for _, entry := range entries {
-		if entry.IsError() {
+		// Skip entries already handled by a previous, overlapping query
+		if !p.seen.add(entry) {
+			continue
+		}
+		isError, process := p.filterEntry(entry)
+		if isError {
 			errorCount++
-			log.Printf("Processing error: level=%s status=%d msg=%s", entry.Level, entry.Status, entry.Message)
-			if err := p.processEntry(entry); err != nil {
-				log.Printf("Error processing log entry: %v", err)
-			}
+		}
+		if process {
+			pending = append(pending, entry)
 		}
 	}
 
-	if errorCount > 0 {
-		log.Printf("Processed %d error entries", errorCount)
+	if p.dedupByTrace {
+		var dropped int
+		if pending, dropped = dedupByTrace(pending, p.severity); dropped > 0 {
+			p.debugf("Collapsed %d entries sharing a trace ID", dropped)
+		}
+	}
+
+	p.processConcurrently(ctx, pending)
+	return errorCount
+}
+
+// handleEntry processes an entry if it is an error and reports whether it was one
+func (p *Processor) handleEntry(ctx context.Context, entry loki.LogEntry) bool {
+	isError, process := p.filterEntry(entry)
+	if process {
+		p.process(ctx, entry)
+	}
+	return isError
+}
+
+// filterEntry reports whether an entry is an error and whether it should be
+// turned into an issue
+func (p *Processor) filterEntry(entry loki.LogEntry) (isError, process bool) {
+	if !p.isError(entry) && !p.slow(entry) {
+		return false, false
+	}
+
+	if reason := p.ignoreReason(entry); reason != "" {
+		p.debugf("Ignoring error: %s", reason)
+		p.recordRecent(entry, OutcomeIgnored, RecentEntry{Reason: reason})
+		return true, false
+	}
+
+	if severity := p.severity(entry); !meetsSeverity(severity, p.minSeverity) {
+		if p.createClosed {
+			p.debugf("Tracking error below minimum severity in a closed issue (%s < %s)", severity, p.minSeverity)
+			return true, true
+		}
+		log.Printf("Skipping error below minimum severity (%s < %s): msg=%s", severity, p.minSeverity, entry.Message)
+		p.recordRecent(entry, OutcomeIgnored, RecentEntry{
+			Reason: fmt.Sprintf("below minimum severity (%s < %s)", severity, p.minSeverity),
+		})
+		return true, false
+	}
+
+	return true, true
+}
+
+// Submit processes an entry pushed directly to vigil rather than read from
+// Loki. It applies the same filtering as polled entries and reports whether
+// the entry was turned into an issue.
+func (p *Processor) Submit(ctx context.Context, entry loki.LogEntry) (bool, error) {
+	if _, process := p.filterEntry(entry); !process {
+		return false, nil
+	}
+
+	log.Printf("Processing submitted error: level=%s status=%d msg=%s", entry.Level, entry.Status, entry.Message)
+	if err := p.processEntry(ctx, entry); err != nil {
+		if p.enqueue(entry, err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// process turns an error entry into a new or updated issue, logging failures
+// and queueing the entry for retry if a queue is set
+func (p *Processor) process(ctx context.Context, entry loki.LogEntry) {
+	log.Printf("Processing error: level=%s status=%d msg=%s", entry.Level, entry.Status, entry.Message)
+	if err := p.processEntry(ctx, entry); err != nil {
+		if !p.enqueue(entry, err) {
+			log.Printf("Error processing log entry: %v", err)
+		}
+	}
+}
+
+// bugID returns the bug ID for an entry using the configured fields
+func (p *Processor) bugID(entry loki.LogEntry) string {
+	if p.slow(entry) && entry.BugID == "" {
+		return slowBugID(entry)
 	}
+	return GenerateBugIDWithFields(entry, p.bugIDFields)
+}
+
+// environment returns the environment of an entry, or the default one if
+// it has none. It is only shown and labelled: bug IDs are generated from the
+// entry's own environment, so setting DEFAULT_ENV doesn't re-key existing
+// issues.
+func (p *Processor) environment(entry loki.LogEntry) string {
+	if entry.Environment == "" {
+		return p.defaultEnv
+	}
+	return entry.Environment
 }
 
 // processEntry processes a single log entry
-func (p *Processor) processEntry(entry loki.LogEntry) error {
-	bugID := GenerateBugID(entry)
+func (p *Processor) processEntry(ctx context.Context, entry loki.LogEntry) (err error) {
+	bugID := p.bugID(entry)
 	bugIDLabel := fmt.Sprintf("bugid:%s", bugID)
+	client := p.clientFor(entry)
+	key := p.cacheKey(client, bugID)
 
-	// Search for existing issue with this bugId
-	issues, err := p.giteaClient.SearchIssues(bugIDLabel)
-	if err != nil {
-		return fmt.Errorf("failed to search issues: %w", err)
+	defer func() {
+		if err != nil {
+			p.recordRecent(entry, OutcomeFailed, RecentEntry{BugID: bugID, Repo: client.Repo(), Reason: err.Error()})
+		}
+	}()
+
+	// Serialize search-then-create per bug ID so concurrent workers or
+	// overlapping polls can't both create an issue. This only protects a
+	// single vigil instance.
+	// A new issue is linked from its related issues once the bug ID lock is
+	// released (deferred calls run last to first), as that takes their locks
+	var link *relatedLink
+	defer func() { p.linkRelated(link) }()
+
+	unlock := p.bugLocks.Lock(key)
+	defer unlock()
+
+	// Occurrences of high-volume bug IDs may only be counted
+	if p.sampleOccurrence(client, entry, key) {
+		return nil
 	}
 
-	if len(issues) == 0 {
+	// Use the cached issue when the bug ID is known
+	existing := p.cachedIssue(client, key, bugIDLabel)
+	if existing == nil {
+		// Search for existing issue with this bugId
+		issues, err := client.SearchIssues(bugIDLabel)
+		if err != nil {
+			return fmt.Errorf("failed to search issues in %s: %w", client.Repo(), err)
+		}
+
+		// Only trust issues that still carry the exact bug ID label; a label
+		// removed by hand means the issue no longer tracks this bug
+		issues = withLabel(issues, bugIDLabel)
+		if len(issues) > 0 {
+			existing = p.latestIssue(issues)
+		}
+	}
+
+	// Errors recurring long after their issue was closed get a new issue
+	var previous *gitea.Issue
+	if existing != nil && p.pastDedupTTL(*existing) {
+		log.Printf("Issue #%d was closed more than %s ago, creating a new issue", existing.Number, p.dedupTTL)
+		previous, existing = existing, nil
+	}
+
+	if existing == nil {
+		// During an error storm new errors are collected in one issue
+		if collapsed, err := p.collapseIntoStorm(entry, bugID); err != nil {
+			log.Printf("Warning: %v", err)
+		} else if collapsed {
+			return nil
+		}
+
 		// New issue - create it
-		return p.createNewIssue(entry, bugID, bugIDLabel)
+		link, err = p.createNewIssue(ctx, client, entry, bugID, bugIDLabel, previous)
+		return err
+	}
+
+	// Muted issues are only counted, without touching the issue
+	if existing.HasLabel(MutedLabel) {
+		p.recordMuted(client, *existing, entry, key)
+		return nil
 	}
 
 	// Existing issue - add comment and potentially reopen
-	existing := issues[0]
-	return p.updateExistingIssue(existing, entry)
+	return p.updateExistingIssue(client, *existing, entry, key)
 }
 
-// createNewIssue creates a new issue in Gitea
-func (p *Processor) createNewIssue(entry loki.LogEntry, bugID, bugIDLabel string) error {
-	title := generateTitle(entry)
-	body := generateBody(entry, bugID)
+// cachedIssue returns the issue cached for a bug ID (scoped by cacheKey), or
+// nil on a cache miss. The cached mapping is validated lazily by fetching the
+// issue and checking it still carries the bug ID label; stale entries are
+// evicted.
+func (p *Processor) cachedIssue(client IssueTracker, bugID, bugIDLabel string) *gitea.Issue {
+	cached, err := p.cache.Get(bugID)
+	if err != nil {
+		log.Printf("Warning: cache lookup failed for %s: %v", bugID, err)
+		return nil
+	}
+	if cached == nil {
+		return nil
+	}
+
+	issue, err := client.GetIssue(cached.IssueNumber)
+	if err != nil || !issue.HasLabel(bugIDLabel) {
+		p.debugf("Evicting stale cache entry %s -> #%d", bugID, cached.IssueNumber)
+		if err := p.cache.Delete(bugID); err != nil {
+			log.Printf("Warning: failed to evict cache entry %s: %v", bugID, err)
+		}
+		return nil
+	}
+
+	return issue
+}
+
+// withLabel returns the issues carrying the given label
+func withLabel(issues []gitea.Issue, label string) []gitea.Issue {
+	var matching []gitea.Issue
+	for _, issue := range issues {
+		if issue.HasLabel(label) {
+			matching = append(matching, issue)
+		}
+	}
+	return matching
+}
+
+// cachePut records the issue and occurrence state for a bug ID
+func (p *Processor) cachePut(bugID string, issueNumber int64, lastSeen time.Time, occurrences int) {
+	if err := p.cache.Put(cache.Entry{
+		BugID:       bugID,
+		IssueNumber: issueNumber,
+		LastSeen:    lastSeen,
+		Occurrences: occurrences,
+	}); err != nil {
+		log.Printf("Warning: failed to update cache for %s: %v", bugID, err)
+	}
+}
+
+// createNewIssue creates a new issue in the client's repository, linked to
+// the previous issue of the bug ID if it was closed past the dedup TTL. With
+// related issues enabled, it returns the issues to link the new one from.
+func (p *Processor) createNewIssue(ctx context.Context, client IssueTracker, entry loki.LogEntry, bugID, bugIDLabel string, previous *gitea.Issue) (*relatedLink, error) {
+	title := p.title(entry)
+	links := p.links(entry)
+
+	var related []gitea.Issue
+	if p.relatedIssues {
+		related = p.findRelated(client, entry, bugIDLabel)
+	}
+
+	// The body shows the default environment, but the bug ID is unchanged
+	shown := entry
+	shown.Environment = p.environment(entry)
+	body := generateBody(shown, bugID, bodyExtras{
+		Slow:       p.slow(entry),
+		Links:      links,
+		Rate:       p.currentRate(ctx, entry),
+		MaxBytes:   p.maxBodyBytes,
+		StackLines: p.stackLines,
+		Context:    p.contextFor(ctx, entry),
+		Related:    related,
+		User:       p.trackerUser(entry),
+		Previous:   previous,
+		Sections:   p.bodySections,
+	})
 
 	// Determine labels
-	labels := []string{"auto-generated", bugIDLabel}
-	if entry.Status >= 500 {
-		labels = append(labels, "severity:critical")
+	labels := []string{"auto-generated", bugIDLabel, "severity:" + p.severity(entry), occurrenceLabel(1)}
+	if p.slow(entry) {
+		labels = append(labels, performanceLabel)
 	} else {
-		labels = append(labels, "severity:error")
+		labels = append(labels, categoryLabel(entry))
+	}
+	if priority, ok := p.priorities[p.severity(entry)]; ok {
+		labels = append(labels, priority)
 	}
+	labels = append(labels, p.defaultLabels...)
 
 	// Ensure bugid label exists
-	if err := p.giteaClient.EnsureLabel(bugIDLabel, "0366d6"); err != nil { // blue
+	if err := client.EnsureLabel(bugIDLabel, p.bugIDLabelColor(bugIDLabel)); err != nil {
 		log.Printf("Warning: failed to create bugid label: %v", err)
 	}
 
-	issue, err := p.giteaClient.CreateIssue(title, body, labels)
+	if entry.Service != "" {
+		serviceLabel := "service:" + entry.Service
+		if err := client.EnsureLabel(serviceLabel, "5319e7"); err != nil { // purple
+			log.Printf("Warning: failed to create service label: %v", err)
+		}
+		labels = append(labels, serviceLabel)
+	}
+	if p.unrouted(entry) {
+		labels = append(labels, p.unroutedLabel)
+	}
+
+	if env := p.environment(entry); env != "" {
+		envLabel := "env:" + env
+		if err := client.EnsureLabel(envLabel, "1d76db"); err != nil { // light blue
+			log.Printf("Warning: failed to create env label: %v", err)
+		}
+		labels = append(labels, envLabel)
+	}
+
+	if label := errorTypeLabel(entry); label != "" {
+		if err := client.EnsureLabel(label, labelColor(label)); err != nil {
+			log.Printf("Warning: failed to create error type label: %v", err)
+		}
+		labels = append(labels, label)
+	}
+
+	if label := functionLabel(entry); label != "" && p.relatedIssues {
+		if err := client.EnsureLabel(label, labelColor(label)); err != nil {
+			log.Printf("Warning: failed to create function label: %v", err)
+		}
+		labels = append(labels, label)
+	}
+
+	labels = append(labels, p.ensureFieldLabels(client, entry)...)
+
+	req := gitea.CreateIssueRequest{Title: title, Body: body}
+	if client.Repo() == p.tracker.Repo() {
+		// Milestone IDs are per repository, so it only applies to the default one
+		req.Milestone = p.milestone
+	}
+
+	issue, err := client.CreateIssueFromRequest(req, labels)
 	if err != nil {
-		return fmt.Errorf("failed to create issue: %w", err)
+		return nil, fmt.Errorf("failed to create issue in %s: %w", client.Repo(), err)
 	}
 
-	log.Printf("Created new issue #%d: %s (bugId: %s)", issue.Number, title, bugID)
+	var link *relatedLink
+	if len(related) > 0 {
+		link = &relatedLink{client: client, issue: relatedIssue{Number: issue.Number, Title: title}, related: related}
+	}
+
+	log.Printf("Created new issue %s#%d: %s (bugId: %s)", client.Repo(), issue.Number, title, bugID)
+	if previous != nil {
+		linkPrevious(client, *previous, issue.Number)
+	}
+	if p.storm != nil {
+		p.storm.recordCreated(title, issue.HTMLURL)
+	}
+	key := p.cacheKey(client, bugID)
+	p.cachePut(key, issue.Number, entry.Timestamp, 1)
+	if p.commentMode == CommentModeStats {
+		p.stats.record(key, entry, 0)
+	}
+
+	// Sub-threshold entries only leave a paper trail: close the issue right
+	// away (not every backend accepts an initial state) and don't notify
+	if p.tracksClosed(entry) {
+		if err := client.CloseIssue(issue.Number); err != nil {
+			log.Printf("Warning: failed to close issue #%d: %v", issue.Number, err)
+		} else {
+			log.Printf("Closed issue #%d (below minimum severity)", issue.Number)
+		}
+		p.recordRecent(entry, OutcomeClosed, RecentEntry{BugID: bugID, Title: title, Occurrences: 1, Repo: client.Repo(), IssueNumber: issue.Number})
+		return link, nil
+	}
+
+	p.recordRecent(entry, OutcomeCreated, RecentEntry{BugID: bugID, Title: title, Occurrences: 1, Repo: client.Repo(), IssueNumber: issue.Number})
+
+	info := &notifier.IssueInfo{
+		Number:      issue.Number,
+		Title:       title,
+		URL:         issue.HTMLURL,
+		BugID:       bugID,
+		Service:     entry.Service,
+		Environment: p.environment(entry),
+		Severity:    p.severity(entry),
+		Category:    p.category(entry),
+		Endpoint:    entry.Action,
+		HTTPMethod:  entry.Method,
+		StatusCode:  entry.Status,
+		FirstSeen:   seenTime(entry),
+		TraceURL:    links.Trace,
+		LogsURL:     links.Logs,
+		Labels:      labels,
+	}
+	p.trackOccurrence(activeIssue{key: key, client: client, info: *info, lastSeen: seenTime(entry), occurrences: 1})
+
+	p.summary.record(client.Repo(), *info, true, false)
 
 	// Send notifications
-	for _, n := range p.notifiers {
-		if err := n.NotifyNewIssue(&notifier.IssueInfo{
-			Number:     issue.Number,
-			Title:      title,
-			BugID:      bugID,
-			Endpoint:   entry.Action,
-			HTTPMethod: entry.Method,
-			StatusCode: entry.Status,
-			FirstSeen:  entry.Timestamp,
-		}); err != nil {
+	for _, n := range p.notifiersFor(info.Severity) {
+		if err := n.NotifyNewIssue(info); err != nil {
 			log.Printf("Error sending notification: %v", err)
 		}
 	}
 
-	return nil
+	return link, nil
 }
 
-// updateExistingIssue adds a comment to an existing issue and reopens if closed
-func (p *Processor) updateExistingIssue(existing gitea.Issue, entry loki.LogEntry) error {
-	// Get occurrence count (comments + 1 for original)
-	occurrences := existing.Comments + 2 // +1 for original, +1 for this occurrence
+// updateExistingIssue adds a comment to an existing issue and reopens if
+// closed. bugID is the repository-scoped cache key.
+func (p *Processor) updateExistingIssue(client IssueTracker, existing gitea.Issue, entry loki.LogEntry, bugID string) error {
+	// Closed issues are reopened as the reopen mode allows, unless the entry
+	// is only tracked in closed issues
+	reopening := p.shouldReopen(existing, entry, bugID)
+
+	// Occurrences sampled out since the last one written are already counted
+	skipped := 0
+	if p.sampler != nil {
+		skipped = p.sampler.take(bugID)
+	}
+
+	var occurrences, bodyOccurrences int
+	if p.commentMode == CommentModeStats {
+		var err error
+		if occurrences, err = p.updateStatsComment(client, existing, entry, bugID); err != nil {
+			return err
+		}
+		if reopening {
+			if err := client.AddComment(existing.Number, generateReopenNote(existing.Body, entry)); err != nil {
+				log.Printf("Warning: failed to add reopen comment to issue #%d: %v", existing.Number, err)
+			}
+		}
+	} else if p.suppressOpenComments && existing.State != "closed" {
+		// Open issues only get their count and Last Seen updated in the body
+		occurrences = p.knownOccurrences(existing, bugID) + 1
+		bodyOccurrences = occurrences
+	} else {
+		if p.sampler != nil {
+			// Sampled out occurrences have no comment, so the count is kept
+			// in the cache and the body
+			occurrences = p.knownOccurrences(existing, bugID) + 1
+			bodyOccurrences = occurrences
+		} else {
+			// Get occurrence count (comments + 1 for original)
+			occurrences = existing.Comments + 2 // +1 for original, +1 for this occurrence
+		}
 
-	// Add comment
-	comment := generateComment(entry, occurrences)
-	if err := p.giteaClient.AddComment(existing.Number, comment); err != nil {
-		return fmt.Errorf("failed to add comment: %w", err)
+		// Add comment
+		comment := generateComment(entry, occurrences)
+		if skipped > 0 {
+			comment += fmt.Sprintf("- Sampled out since the last comment: %d occurrences\n", skipped)
+		}
+		if reopening {
+			comment = generateReopenNote(existing.Body, entry) + "\n" + comment
+		}
+		if err := client.AddComment(existing.Number, comment); err != nil {
+			return fmt.Errorf("failed to add comment: %w", err)
+		}
+	}
+	p.cachePut(bugID, existing.Number, entry.Timestamp, occurrences)
+	p.updateLastSeen(client, existing, entry, bodyOccurrences)
+	p.updateOccurrenceLabel(client, existing, occurrences)
+	if !p.tracksClosed(entry) && (existing.State != "closed" || reopening) {
+		active := activeIssue{
+			key:    bugID,
+			client: client,
+			info: notifier.IssueInfo{
+				Number:      existing.Number,
+				Title:       existing.Title,
+				URL:         existing.HTMLURL,
+				BugID:       p.bugID(entry),
+				Service:     entry.Service,
+				Environment: p.environment(entry),
+				Severity:    p.severity(entry),
+				Category:    p.category(entry),
+				Labels:      existing.LabelNames(),
+			},
+			lastSeen:    seenTime(entry),
+			occurrences: occurrences,
+		}
+		p.trackOccurrence(active)
+		p.trackEscalated(active)
 	}
 
 	// Reopen if closed
-	if existing.State == "closed" {
-		if err := p.giteaClient.ReopenIssue(existing.Number); err != nil {
+	reopened := false
+	if reopening {
+		if err := client.ReopenIssue(existing.Number); err != nil {
 			log.Printf("Warning: failed to reopen issue #%d: %v", existing.Number, err)
 		} else {
+			reopened = true
 			log.Printf("Reopened issue #%d", existing.Number)
+			p.clearResolution(client, existing)
+			// Notify with the issue's current title, which may have been
+			// edited by hand since it was fetched
+			if current, err := client.GetIssue(existing.Number); err == nil {
+				existing.Title = current.Title
+				existing.HTMLURL = current.HTMLURL
+			}
+		}
+	}
+
+	if !p.tracksClosed(entry) {
+		p.summary.record(client.Repo(), notifier.IssueInfo{
+			Number:      existing.Number,
+			Title:       existing.Title,
+			URL:         existing.HTMLURL,
+			BugID:       p.bugID(entry),
+			Service:     entry.Service,
+			Severity:    p.severity(entry),
+			Occurrences: occurrences,
+			Labels:      existing.LabelNames(),
+		}, false, reopened)
+	}
 
-			// Notify about reopened issue
-			for _, n := range p.notifiers {
-				if err := n.NotifyReopenedIssue(&notifier.IssueInfo{
-					Number:      existing.Number,
-					Title:       existing.Title,
-					Occurrences: occurrences,
-				}); err != nil {
-					log.Printf("Error sending notification: %v", err)
-				}
+	if p.notifyMode == NotifyModeDigest {
+		p.digest.record(client.Repo(), notifier.IssueInfo{
+			Number:      existing.Number,
+			Title:       existing.Title,
+			URL:         existing.HTMLURL,
+			Service:     entry.Service,
+			Occurrences: occurrences,
+			Labels:      existing.LabelNames(),
+		}, reopened)
+	} else if reopened {
+		// Notify about reopened issue
+		for _, n := range p.notifiersFor(p.severity(entry)) {
+			if err := n.NotifyReopenedIssue(&notifier.IssueInfo{
+				Number:      existing.Number,
+				Title:       existing.Title,
+				URL:         existing.HTMLURL,
+				BugID:       p.bugID(entry),
+				Environment: p.environment(entry),
+				Severity:    p.severity(entry),
+				Category:    p.category(entry),
+				Occurrences: occurrences,
+				Labels:      existing.LabelNames(),
+			}); err != nil {
+				log.Printf("Error sending notification: %v", err)
 			}
 		}
 	}
 
+	if !reopened && existing.State != "closed" {
+		p.notifyOccurrence(&notifier.IssueInfo{
+			Number:      existing.Number,
+			Title:       existing.Title,
+			URL:         existing.HTMLURL,
+			BugID:       p.bugID(entry),
+			Service:     entry.Service,
+			Severity:    p.severity(entry),
+			Occurrences: occurrences,
+			Labels:      existing.LabelNames(),
+		})
+	}
+
+	outcome := OutcomeUpdated
+	switch {
+	case reopened:
+		outcome = OutcomeReopened
+	case existing.State == "closed":
+		outcome = OutcomeClosed
+	}
+	p.recordRecent(entry, outcome, RecentEntry{
+		Title:       existing.Title,
+		Occurrences: occurrences,
+		Repo:        client.Repo(),
+		IssueNumber: existing.Number,
+	})
+
 	log.Printf("Updated issue #%d (occurrence #%d)", existing.Number, occurrences)
 	return nil
 }
 
-// GenerateBugID creates a unique bug ID from log entry
-func GenerateBugID(entry loki.LogEntry) string {
-	// If explicit bugId is provided in the log, use it
-	if entry.BugID != "" {
-		return entry.BugID
+// lastSeenLine matches the "Last Seen" line of the issue body timeline
+var lastSeenLine = regexp.MustCompile("(?m)^- \\*\\*Last Seen:\\*\\* `([^`]*)`$")
+
+// updateLastSeen refreshes the "Last Seen" timestamp in the issue body, and
+// the "Occurrences" count when occurrences is positive. Issues created
+// before the timeline existed are left untouched, and the timestamp never
+// moves backwards (e.g. when backfilling older entries).
+func (p *Processor) updateLastSeen(client IssueTracker, existing gitea.Issue, entry loki.LogEntry, occurrences int) {
+	m := lastSeenLine.FindStringSubmatch(existing.Body)
+	if m == nil {
+		return
 	}
 
-	// Auto-generate from log fields
-	endpoint := normalizeEndpoint(entry.Action)
+	body := existing.Body
+	if last, err := time.Parse(time.RFC3339, m[1]); err != nil || !seenTime(entry).Before(last) {
+		line := fmt.Sprintf("- **Last Seen:** `%s`", seenTime(entry).Format(time.RFC3339))
+		body = lastSeenLine.ReplaceAllLiteralString(body, line)
+	}
+	if occurrences > 0 {
+		body = withOccurrences(body, occurrences)
+	}
+	if body == existing.Body {
+		return
+	}
 
-	data := fmt.Sprintf("%s|%s|%d|%s",
-		entry.Method,
-		endpoint,
-		entry.Status,
-		entry.Source.Function,
-	)
+	if err := client.UpdateIssueBody(existing.Number, body); err != nil {
+		log.Printf("Warning: failed to update last seen for issue #%d: %v", existing.Number, err)
+	}
+}
 
-	hash := sha256.Sum256([]byte(data))
-	return hex.EncodeToString(hash[:8]) // Shorter for readability
+// seenTime returns when an entry was logged, preferring the log's own time
+// over the stream timestamp and falling back to now if unknown
+func seenTime(entry loki.LogEntry) time.Time {
+	if !entry.LogTime.IsZero() {
+		return entry.LogTime
+	}
+	if entry.Timestamp.IsZero() {
+		return time.Now()
+	}
+	return entry.Timestamp
 }
 
-// normalizeEndpoint replaces dynamic path segments with placeholders
+// Patterns for dynamic path segments, matched against whole segments
+var (
+	numericSegment = regexp.MustCompile(`^\d+$`)
+	uuidSegment    = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	hexSegment     = regexp.MustCompile(`^[0-9a-fA-F]{16,}$`)
+	slugSegment    = regexp.MustCompile(`^[a-zA-Z0-9]+(-[a-zA-Z0-9]+)+$`)
+	hasDigit       = regexp.MustCompile(`\d`)
+)
+
+// normalizeEndpoint replaces dynamic path segments with placeholders and
+// strips query strings, fragments and trailing slashes
 func normalizeEndpoint(endpoint string) string {
-	// Replace numeric IDs
-	numericID := regexp.MustCompile(`/\d+`)
-	endpoint = numericID.ReplaceAllString(endpoint, "/:id")
+	// Drop query string and fragment
+	if i := strings.IndexAny(endpoint, "?#"); i >= 0 {
+		endpoint = endpoint[:i]
+	}
 
-	// Replace UUIDs
-	uuid := regexp.MustCompile(`/[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`)
-	endpoint = uuid.ReplaceAllString(endpoint, "/:uuid")
+	// Normalize trailing slashes (but keep the root path)
+	if trimmed := strings.TrimRight(endpoint, "/"); trimmed != "" || endpoint == "" {
+		endpoint = trimmed
+	} else {
+		endpoint = "/"
+	}
+
+	segments := strings.Split(endpoint, "/")
+	for i, segment := range segments {
+		switch {
+		case segment == "":
+			continue
+		case uuidSegment.MatchString(segment):
+			segments[i] = ":uuid"
+		case numericSegment.MatchString(segment):
+			segments[i] = ":id"
+		case hexSegment.MatchString(segment):
+			segments[i] = ":hex"
+		case slugSegment.MatchString(segment) && hasDigit.MatchString(segment):
+			segments[i] = ":slug"
+		}
+	}
 
-	return endpoint
+	return strings.Join(segments, "/")
 }
 
-// generateTitle creates a title for the issue
-func generateTitle(entry loki.LogEntry) string {
+// generateTitle creates a title for the issue of at most maxLength
+// characters
+func generateTitle(entry loki.LogEntry, maxLength int) string {
 	var parts []string
 
 	if entry.Status >= 500 {
@@ -274,31 +1319,127 @@ func generateTitle(entry loki.LogEntry) string {
 		parts = append(parts, fmt.Sprintf("[%s]", strings.ToUpper(entry.Level)))
 	}
 
+	if entry.Service != "" {
+		parts = append(parts, fmt.Sprintf("[%s]", entry.Service))
+	}
+
 	if entry.Method != "" && entry.Action != "" {
 		parts = append(parts, fmt.Sprintf("%s %s", entry.Method, normalizeEndpoint(entry.Action)))
 	}
 
-	if entry.Message != "" && len(entry.Message) < 80 {
-		parts = append(parts, entry.Message)
+	// The status or level, service and request are kept when shortening
+	leading := len(parts)
+
+	if errType := errorType(entry); errType != "" {
+		parts = append(parts, errType)
+	}
+
+	// Without a request, the function and message are all that identify
+	// the error, so long messages are kept (and truncated with the title)
+	worker := !hasRequest(entry)
+	if worker && entry.Source.Function != "" {
+		parts = append(parts, entry.Source.Function)
+	}
+
+	// The normalized message describes every occurrence grouped in the
+	// issue, not just the first one
+	if msg := normalizeMessage(entry.Message); msg != "" && (len(msg) < 80 || worker) {
+		parts = append(parts, msg)
 	}
 
 	if len(parts) == 0 {
 		return "Unknown error"
 	}
 
-	return strings.Join(parts, " - ")
+	return fitTitle(parts[:leading], parts[leading:], maxLength)
 }
 
-// generateBody creates the issue body in Markdown
-func generateBody(entry loki.LogEntry, bugID string) string {
-	var sb strings.Builder
+// bodyExtras holds issue body content that is not derived from the entry itself
+type bodyExtras struct {
+	Slow       bool // the entry is a slow request rather than an error
+	Links      entryLinks
+	Rate       *errorRate
+	MaxBytes   int // body size limit, 0 for none
+	StackLines int // stack trace line limit, 0 for none
+	Context    *logContext
+	Related    []gitea.Issue
+	User       string       // issue tracker username of the affected user, if mapped
+	Previous   *gitea.Issue // issue of the bug ID closed past the dedup TTL, if any
+	Sections   []string     // body sections in order, nil for DefaultBodySections
+}
+
+// generateBody creates the issue body in Markdown, with the sections of
+// extras.Sections in order
+func generateBody(entry loki.LogEntry, bugID string, extras bodyExtras) string {
+	sections := extras.Sections
+	if sections == nil {
+		sections = DefaultBodySections
+	}
+
+	// The sample log is the part shrunk to fit the size limit, so the
+	// sections before and after it are rendered apart
+	var before, after strings.Builder
+	hasSample := false
+	for _, name := range sections {
+		if name == SectionSample {
+			hasSample = true
+			continue
+		}
+		if hasSample {
+			writeBodySection(&after, name, entry, extras)
+		} else {
+			writeBodySection(&before, name, entry, extras)
+		}
+	}
 
-	sb.WriteString("## Error Details\n\n")
+	head := before.String()
+	var sample, tail string
+	if hasSample {
+		head += "\n## Sample Log\n\n```json\n"
+		if jsonBytes, err := json.MarshalIndent(withoutStacktrace(entry), "", "  "); err == nil {
+			sample = string(jsonBytes)
+		}
+		tail = "\n```\n"
+	}
+	head = strings.TrimPrefix(head, "\n")
+
+	tail += after.String() +
+		"\n---\n" +
+		fmt.Sprintf("*Bug ID: `%s`*\n", bugID) +
+		"*Auto-generated by issue-tracker*\n"
+
+	// Shrink the sample log first, then the body as a whole, to stay
+	// within the size limit
+	if max := extras.MaxBytes; max > 0 && hasSample && len(head)+len(sample)+len(tail) > max {
+		budget := max - len(head) - len(tail) - len(truncatedMarker)
+		sample = truncateBytes(sample, budget) + truncatedMarker
+	}
+
+	body := head + sample + tail
+	if max := extras.MaxBytes; max > 0 && len(body) > max {
+		body = truncateBytes(body, max-len(truncatedMarker)) + truncatedMarker
+	}
+
+	return body
+}
+
+// writeErrorDetails renders the error details section of an issue body
+func writeErrorDetails(sb *strings.Builder, entry loki.LogEntry, extras bodyExtras) {
+	sb.WriteString("\n## Error Details\n\n")
 
 	if entry.Message != "" {
 		sb.WriteString(fmt.Sprintf("**Message:** %s\n\n", entry.Message))
 	}
 
+	if entry.ErrorType != "" && entry.ErrorType != entry.Message {
+		sb.WriteString(fmt.Sprintf("**Type:** `%s`\n", entry.ErrorType))
+	}
+	if extras.Slow {
+		sb.WriteString(fmt.Sprintf("**Latency:** %.0f ms\n", entry.ElapsedMs))
+	} else {
+		sb.WriteString(fmt.Sprintf("**Category:** %s\n", entry.ErrorCategory()))
+	}
+
 	if entry.Source.Function != "" {
 		sb.WriteString(fmt.Sprintf("**Source:** `%s`\n", entry.Source.Function))
 	}
@@ -306,9 +1447,22 @@ func generateBody(entry loki.LogEntry, bugID string) string {
 	if entry.Source.File != "" {
 		sb.WriteString(fmt.Sprintf("**File:** `%s:%d`\n", entry.Source.File, entry.Source.Line))
 	}
+}
 
+// writeRequestInfo renders the request info section of an issue body
+func writeRequestInfo(sb *strings.Builder, entry loki.LogEntry, extras bodyExtras) {
 	sb.WriteString("\n## Request Info\n\n")
 
+	if entry.Service != "" {
+		sb.WriteString(fmt.Sprintf("- **Service:** %s\n", entry.Service))
+	}
+	if entry.Environment != "" {
+		sb.WriteString(fmt.Sprintf("- **Environment:** %s\n", entry.Environment))
+	}
+	if stream := streamSelector(entry); stream != "" {
+		sb.WriteString(fmt.Sprintf("- **Stream:** `%s`\n", stream))
+	}
+
 	if entry.Method != "" {
 		sb.WriteString(fmt.Sprintf("- **Method:** %s\n", entry.Method))
 	}
@@ -318,6 +1472,9 @@ func generateBody(entry loki.LogEntry, bugID string) string {
 	if entry.Status > 0 {
 		sb.WriteString(fmt.Sprintf("- **Status Code:** %d\n", entry.Status))
 	}
+	if entry.ElapsedMs > 0 {
+		sb.WriteString(fmt.Sprintf("- **Elapsed:** %.0f ms\n", entry.ElapsedMs))
+	}
 	if entry.RequestID != "" {
 		sb.WriteString(fmt.Sprintf("- **Request ID:** `%s`\n", entry.RequestID))
 	}
@@ -325,27 +1482,49 @@ func generateBody(entry loki.LogEntry, bugID string) string {
 		sb.WriteString(fmt.Sprintf("- **Trace ID:** `%s`\n", entry.TraceID))
 	}
 	if entry.UserID != "" {
-		sb.WriteString(fmt.Sprintf("- **User ID:** %s\n", entry.UserID))
+		if extras.User != "" {
+			sb.WriteString(fmt.Sprintf("- **User ID:** %s (@%s)\n", entry.UserID, extras.User))
+		} else {
+			sb.WriteString(fmt.Sprintf("- **User ID:** %s\n", entry.UserID))
+		}
 	}
+}
 
-	sb.WriteString("\n## Sample Log\n\n```json\n")
-	if jsonBytes, err := json.MarshalIndent(entry.Parsed, "", "  "); err == nil {
-		sb.Write(jsonBytes)
+// writeLinks renders the trace and logs links section of an issue body, if
+// there are any
+func writeLinks(sb *strings.Builder, links entryLinks) {
+	if links.Trace == "" && links.Logs == "" {
+		return
 	}
-	sb.WriteString("\n```\n")
-
-	sb.WriteString("\n---\n")
-	sb.WriteString(fmt.Sprintf("*Bug ID: `%s`*\n", bugID))
-	sb.WriteString("*Auto-generated by issue-tracker*\n")
+	sb.WriteString("\n## Links\n\n")
+	if links.Trace != "" {
+		sb.WriteString(fmt.Sprintf("- [View trace](%s)\n", links.Trace))
+	}
+	if links.Logs != "" {
+		sb.WriteString(fmt.Sprintf("- [View logs](%s)\n", links.Logs))
+	}
+}
 
-	return sb.String()
+// writeTimeline renders the timeline section of an issue body
+func writeTimeline(sb *strings.Builder, entry loki.LogEntry, extras bodyExtras) {
+	seen := seenTime(entry).Format(time.RFC3339)
+	sb.WriteString("\n## Timeline\n\n")
+	sb.WriteString(fmt.Sprintf("- **First Seen:** `%s`\n", seen))
+	sb.WriteString(fmt.Sprintf("- **Last Seen:** `%s`\n", seen))
+	if extras.Rate != nil {
+		sb.WriteString(fmt.Sprintf("- **Current Rate:** %s\n", extras.Rate))
+	}
+	if extras.Previous != nil {
+		sb.WriteString(fmt.Sprintf("- **Previous Issue:** #%d (closed `%s`)\n",
+			extras.Previous.Number, closedTime(*extras.Previous).Format(time.RFC3339)))
+	}
 }
 
 // generateComment creates a comment for duplicate occurrences
 func generateComment(entry loki.LogEntry, occurrences int) string {
 	var sb strings.Builder
 
-	sb.WriteString(fmt.Sprintf("**Occurred again** at `%s`\n\n", entry.Timestamp.Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("**Occurred again** at `%s`\n\n", seenTime(entry).Format(time.RFC3339)))
 
 	if entry.RequestID != "" {
 		sb.WriteString(fmt.Sprintf("- Request ID: `%s`\n", entry.RequestID))
@@ -356,6 +1535,9 @@ func generateComment(entry loki.LogEntry, occurrences int) string {
 	if entry.UserID != "" {
 		sb.WriteString(fmt.Sprintf("- User ID: %s\n", entry.UserID))
 	}
+	if entry.ElapsedMs > 0 {
+		sb.WriteString(fmt.Sprintf("- Elapsed: %.0f ms\n", entry.ElapsedMs))
+	}
 
 	sb.WriteString(fmt.Sprintf("- Total occurrences: **%d**\n", occurrences))
 
@@ -0,0 +1,602 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"vigil/gitea"
+	"vigil/loki"
+	"vigil/notifier"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestMain(m *testing.M) {
+	// Timestamps in bodies and notifications are rendered in local time
+	time.Local = time.UTC
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+// TestGolden runs each Loki fixture in testdata through a poll and compares
+// the requests the Gitea client sent and the notifications with its golden
+// file. Run with -update to rewrite the golden files after an intended
+// change.
+func TestGolden(t *testing.T) {
+	fixtures, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatal("no fixtures in testdata")
+	}
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".json")
+		t.Run(name, func(t *testing.T) {
+			response, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			loki := newLokiServer(t, func(r *http.Request) []byte { return response })
+			tracker := newFakeGitea(t)
+			recorder := &recordingNotifier{}
+
+			p := newTestProcessor(tracker, Config{
+				LokiURL:  loki.URL,
+				Lookback: time.Hour,
+				// COMMENT_ON_OPEN defaults to true in main
+				SuppressOpenComments: false,
+			}, recorder)
+			p.poll(context.Background())
+
+			assertGolden(t, filepath.Join("testdata", name+".golden"), tracker.String()+recorder.String())
+		})
+	}
+}
+
+// assertGolden compares got with the golden file at path, or rewrites it
+// with -update
+func assertGolden(t *testing.T, path, got string) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s (run with -update to accept it)\n--- got\n%s\n--- want\n%s", path, got, want)
+	}
+}
+
+// newTestProcessor creates a processor filing issues through a Gitea client
+// talking to the fake Gitea. The labels created at startup are ensured
+// first and left out of the recorded requests.
+func newTestProcessor(tracker *fakeGitea, cfg Config, notifiers ...notifier.Notifier) *Processor {
+	client := gitea.NewClient(tracker.URL, "token", "owner", "repo", gitea.WithHTTPClient(tracker.Client()))
+	p := NewProcessor(client, cfg, notifiers)
+	p.ensureLabels(client)
+	tracker.reset()
+	return p
+}
+
+// newLokiServer starts a Loki stub answering range queries with the
+// query_range response returned by respond
+func newLokiServer(t *testing.T, respond func(r *http.Request) []byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/query_range" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(respond(r))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// lokiTime is the timestamp of the first line of lokiStreams responses
+var lokiTime = time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+
+// lokiLine is a log line served by a Loki stub
+type lokiLine struct {
+	ts   time.Time
+	line string
+}
+
+// lokiStreams returns a query_range response with the given log lines in a
+// single stream, one second apart from ts on
+func lokiStreams(ts time.Time, lines ...string) []byte {
+	timed := make([]lokiLine, 0, len(lines))
+	for i, line := range lines {
+		timed = append(timed, lokiLine{ts: ts.Add(time.Duration(i) * time.Second), line: line})
+	}
+	return lokiResponse(timed)
+}
+
+// lokiResponse returns a query_range response with the given lines in a
+// single stream
+func lokiResponse(lines []lokiLine) []byte {
+	values := make([][]string, 0, len(lines))
+	for _, l := range lines {
+		values = append(values, []string{strconv.FormatInt(l.ts.UnixNano(), 10), l.line})
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"status": "success",
+		"data": map[string]interface{}{
+			"resultType": "streams",
+			"result":     []interface{}{map[string]interface{}{"stream": map[string]string{"app": "shop"}, "values": values}},
+		},
+	})
+	return data
+}
+
+// fakeGitea is an in-memory Gitea API serving a single repository,
+// owner/repo. It records the requests changing it, which are the shape of
+// what vigil files.
+type fakeGitea struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	issues   []*gitea.Issue
+	labels   []gitea.Label
+	comments map[int64][]gitea.Comment
+	commentN int64
+	log      strings.Builder
+}
+
+// fakeGiteaRepo is the API path of the fake repository
+const fakeGiteaRepo = "/api/v1/repos/owner/repo"
+
+func newFakeGitea(t *testing.T) *fakeGitea {
+	t.Helper()
+	f := &fakeGitea{comments: make(map[int64][]gitea.Comment)}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// String returns the recorded requests
+func (f *fakeGitea) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.log.String()
+}
+
+// reset forgets the requests recorded so far
+func (f *fakeGitea) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.log.Reset()
+}
+
+// created returns the titles of the created issues in order
+func (f *fakeGitea) created() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var titles []string
+	for _, issue := range f.issues {
+		titles = append(titles, issue.Title)
+	}
+	return titles
+}
+
+// commentsOn returns the bodies of the comments on an issue in order
+func (f *fakeGitea) commentsOn(number int64) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var bodies []string
+	for _, comment := range f.comments[number] {
+		bodies = append(bodies, comment.Body)
+	}
+	return bodies
+}
+
+func (f *fakeGitea) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	path := strings.TrimPrefix(r.URL.Path, fakeGiteaRepo)
+	if path == r.URL.Path {
+		http.NotFound(w, r)
+		return
+	}
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+
+	var req map[string]interface{}
+	if r.Body != nil {
+		json.NewDecoder(r.Body).Decode(&req)
+	}
+	if r.Method != http.MethodGet {
+		f.record(r, parts, req)
+	}
+
+	switch {
+	case len(parts) == 1 && parts[0] == "issues" && r.Method == http.MethodGet:
+		var found []gitea.Issue
+		for _, issue := range f.issues {
+			if issue.HasLabel(r.URL.Query().Get("labels")) {
+				found = append(found, *issue)
+			}
+		}
+		writeJSON(w, http.StatusOK, found)
+
+	case len(parts) == 1 && parts[0] == "issues" && r.Method == http.MethodPost:
+		number := int64(len(f.issues) + 1)
+		issue := &gitea.Issue{
+			ID:      number,
+			Number:  number,
+			Title:   stringValue(req["title"]),
+			Body:    stringValue(req["body"]),
+			State:   "open",
+			HTMLURL: fmt.Sprintf("https://gitea.example.com/owner/repo/issues/%d", number),
+		}
+		f.issues = append(f.issues, issue)
+		writeJSON(w, http.StatusCreated, issue)
+
+	case len(parts) == 2 && parts[0] == "issues":
+		issue := f.issue(parts[1])
+		if issue == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPatch {
+			if title, ok := req["title"].(string); ok {
+				issue.Title = title
+			}
+			if body, ok := req["body"].(string); ok {
+				issue.Body = body
+			}
+			if state, ok := req["state"].(string); ok {
+				issue.State = state
+			}
+			writeJSON(w, http.StatusCreated, issue)
+			return
+		}
+		writeJSON(w, http.StatusOK, issue)
+
+	case len(parts) == 3 && parts[0] == "issues" && parts[1] == "comments" && r.Method == http.MethodPatch:
+		for number, comments := range f.comments {
+			for i := range comments {
+				if strconv.FormatInt(comments[i].ID, 10) == parts[2] {
+					comments[i].Body = stringValue(req["body"])
+					writeJSON(w, http.StatusOK, f.comments[number][i])
+					return
+				}
+			}
+		}
+		http.NotFound(w, r)
+
+	case len(parts) == 3 && parts[0] == "issues" && parts[2] == "comments":
+		issue := f.issue(parts[1])
+		if issue == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == http.MethodPost {
+			f.commentN++
+			comment := gitea.Comment{ID: f.commentN, Body: stringValue(req["body"])}
+			f.comments[issue.Number] = append(f.comments[issue.Number], comment)
+			issue.Comments++
+			writeJSON(w, http.StatusCreated, comment)
+			return
+		}
+		writePage(w, r, f.comments[issue.Number])
+
+	case len(parts) == 3 && parts[0] == "issues" && parts[2] == "labels" && r.Method == http.MethodPost:
+		issue := f.issue(parts[1])
+		if issue == nil {
+			http.NotFound(w, r)
+			return
+		}
+		ids, _ := req["labels"].([]interface{})
+		for _, id := range ids {
+			if label := f.label(int64(id.(float64))); label != nil && !issue.HasLabel(label.Name) {
+				issue.Labels = append(issue.Labels, *label)
+			}
+		}
+		writeJSON(w, http.StatusOK, issue.Labels)
+
+	case len(parts) == 4 && parts[0] == "issues" && parts[2] == "labels" && r.Method == http.MethodDelete:
+		issue := f.issue(parts[1])
+		if issue == nil {
+			http.NotFound(w, r)
+			return
+		}
+		kept := issue.Labels[:0]
+		for _, label := range issue.Labels {
+			if strconv.FormatInt(label.ID, 10) != parts[3] {
+				kept = append(kept, label)
+			}
+		}
+		issue.Labels = kept
+		w.WriteHeader(http.StatusNoContent)
+
+	case len(parts) == 1 && parts[0] == "labels" && r.Method == http.MethodGet:
+		writePage(w, r, f.labels)
+
+	case len(parts) == 1 && parts[0] == "labels" && r.Method == http.MethodPost:
+		name := stringValue(req["name"])
+		for _, label := range f.labels {
+			if label.Name == name {
+				w.WriteHeader(http.StatusConflict)
+				return
+			}
+		}
+		label := gitea.Label{ID: int64(len(f.labels) + 1), Name: name, Color: stringValue(req["color"])}
+		f.labels = append(f.labels, label)
+		writeJSON(w, http.StatusCreated, label)
+
+	case len(parts) == 2 && parts[0] == "labels" && r.Method == http.MethodPatch:
+		id, _ := strconv.ParseInt(parts[1], 10, 64)
+		label := f.label(id)
+		if label == nil {
+			http.NotFound(w, r)
+			return
+		}
+		label.Color = stringValue(req["color"])
+		writeJSON(w, http.StatusOK, label)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// record logs a request changing the fake, with label IDs replaced by
+// their names so the log doesn't depend on the order labels were created
+func (f *fakeGitea) record(r *http.Request, parts []string, req map[string]interface{}) {
+	path := r.URL.Path
+	if len(parts) == 4 && parts[2] == "labels" {
+		id, _ := strconv.ParseInt(parts[3], 10, 64)
+		if label := f.label(id); label != nil {
+			path = strings.TrimSuffix(path, parts[3]) + label.Name
+		}
+	}
+	fmt.Fprintf(&f.log, "=== %s %s\n", r.Method, path)
+
+	keys := make([]string, 0, len(req))
+	for key := range req {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := req[key]
+		if ids, ok := value.([]interface{}); ok && key == "labels" {
+			var names []string
+			for _, id := range ids {
+				if label := f.label(int64(id.(float64))); label != nil {
+					names = append(names, label.Name)
+				}
+			}
+			value = strings.Join(names, ", ")
+		}
+
+		if s, ok := value.(string); ok && strings.Contains(s, "\n") {
+			fmt.Fprintf(&f.log, "--- %s\n%s\n", key, s)
+		} else if s, ok := value.(string); ok {
+			fmt.Fprintf(&f.log, "%s: %s\n", key, s)
+		} else {
+			data, _ := json.Marshal(value)
+			fmt.Fprintf(&f.log, "%s: %s\n", key, data)
+		}
+	}
+}
+
+func (f *fakeGitea) issue(number string) *gitea.Issue {
+	for _, issue := range f.issues {
+		if strconv.FormatInt(issue.Number, 10) == number {
+			return issue
+		}
+	}
+	return nil
+}
+
+func (f *fakeGitea) label(id int64) *gitea.Label {
+	for i := range f.labels {
+		if f.labels[i].ID == id {
+			return &f.labels[i]
+		}
+	}
+	return nil
+}
+
+// writePage writes the page of items selected by the page and limit query
+// parameters, as Gitea's list endpoints do
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = len(items)
+	}
+
+	start, end := (page-1)*limit, page*limit
+	if start > len(items) {
+		start = len(items)
+	}
+	if end > len(items) {
+		end = len(items)
+	}
+
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+	writeJSON(w, http.StatusOK, append([]T{}, items[start:end]...))
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func stringValue(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
+
+// recordingNotifier records the notifications sent to it
+type recordingNotifier struct {
+	mu  sync.Mutex
+	log strings.Builder
+}
+
+// String returns the recorded notifications
+func (r *recordingNotifier) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.log.String()
+}
+
+func (r *recordingNotifier) record(kind string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprintf(&r.log, "=== notify %s\n%s\n", kind, data)
+	return nil
+}
+
+func (r *recordingNotifier) NotifyNewIssue(issue *notifier.IssueInfo) error {
+	return r.record("new issue", issue)
+}
+
+func (r *recordingNotifier) NotifyReopenedIssue(issue *notifier.IssueInfo) error {
+	return r.record("reopened issue", issue)
+}
+
+func (r *recordingNotifier) NotifyResolvedIssue(issue *notifier.IssueInfo) error {
+	return r.record("resolved issue", issue)
+}
+
+func (r *recordingNotifier) NotifySummary(issues []*notifier.IssueInfo) error {
+	return r.record("summary", issues)
+}
+
+func (r *recordingNotifier) Name() string { return "recorder" }
+
+func TestNormalizeEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		want     string
+	}{
+		{"empty", "", ""},
+		{"root", "/", "/"},
+		{"root with trailing slashes", "///", "/"},
+		{"static path", "/api/health", "/api/health"},
+		{"query string", "/api/orders?page=2&sort=desc", "/api/orders"},
+		{"fragment", "/docs/setup#install", "/docs/setup"},
+		{"trailing slash", "/api/orders/", "/api/orders"},
+		{"trailing slash before query", "/api/orders/?page=2", "/api/orders"},
+		{"numeric ID", "/api/orders/8812", "/api/orders/:id"},
+		{"UUID", "/api/users/3f2b8c1e-9a4d-4e6f-b7c2-1d5e8f9a0b3c", "/api/users/:uuid"},
+		{"mixed numeric and UUID segments", "/api/users/42/sessions/3f2b8c1e-9a4d-4e6f-b7c2-1d5e8f9a0b3c/events/7", "/api/users/:id/sessions/:uuid/events/:id"},
+		{"hex", "/commits/9fceb02d0ae598e95dc970b74767f19372d61af8", "/commits/:hex"},
+		{"slug with digits", "/products/blue-shirt-42", "/products/:slug"},
+		{"version segment kept", "/api/v1/orders", "/api/v1/orders"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeEndpoint(tt.endpoint); got != tt.want {
+				t.Errorf("normalizeEndpoint(%q) = %q, want %q", tt.endpoint, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateBodyMaxBytes(t *testing.T) {
+	// An oversized payload with multibyte characters, so cuts can land
+	// inside a character
+	parsed := map[string]interface{}{
+		"level":   "error",
+		"msg":     "failed to import catalog",
+		"payload": strings.Repeat("Größenüberschreitung ✓ ", 5000),
+		"items":   []interface{}{strings.Repeat("日本語", 2000), strings.Repeat("x", 10000)},
+		"request": map[string]interface{}{"body": strings.Repeat("é", 20000)},
+	}
+	entry := loki.LogEntry{
+		Timestamp: lokiTime,
+		Parsed:    parsed,
+		Level:     "error",
+		Message:   "failed to import catalog",
+		Service:   "catalog",
+	}
+
+	for _, maxBytes := range []int{1000, 1001, 1002, 1003, 4096, DefaultMaxBodyBytes} {
+		t.Run(strconv.Itoa(maxBytes), func(t *testing.T) {
+			body := generateBody(entry, "abc123", bodyExtras{MaxBytes: maxBytes})
+			if len(body) > maxBytes {
+				t.Errorf("body is %d bytes, want at most %d", len(body), maxBytes)
+			}
+			if !utf8.ValidString(body) {
+				t.Error("body is not valid UTF-8")
+			}
+			if !strings.Contains(body, truncatedMarker) {
+				t.Errorf("body has no %q marker", truncatedMarker)
+			}
+			// The sample log is shrunk first, so the footer is kept
+			if !strings.HasSuffix(body, "*Auto-generated by issue-tracker*\n") {
+				t.Errorf("body lost its footer:\n%s", body[len(body)-200:])
+			}
+		})
+	}
+
+	t.Run("within the limit", func(t *testing.T) {
+		small := entry
+		small.Parsed = map[string]interface{}{"level": "error", "msg": "failed to import catalog"}
+		body := generateBody(small, "abc123", bodyExtras{MaxBytes: DefaultMaxBodyBytes})
+		if strings.Contains(body, truncatedMarker) {
+			t.Errorf("body within the limit was truncated:\n%s", body)
+		}
+	})
+
+	t.Run("no limit", func(t *testing.T) {
+		body := generateBody(entry, "abc123", bodyExtras{})
+		if strings.Contains(body, truncatedMarker) || len(body) < 100000 {
+			t.Errorf("body without a limit was truncated to %d bytes", len(body))
+		}
+	})
+}
+
+func TestSeenTime(t *testing.T) {
+	streamTime := time.Date(2026, 10, 16, 8, 0, 0, 0, time.UTC)
+	logTime := time.Date(2026, 10, 16, 7, 59, 30, 0, time.UTC)
+
+	if got := seenTime(loki.LogEntry{Timestamp: streamTime, LogTime: logTime}); !got.Equal(logTime) {
+		t.Errorf("seenTime with log time = %v, want %v", got, logTime)
+	}
+	if got := seenTime(loki.LogEntry{Timestamp: streamTime}); !got.Equal(streamTime) {
+		t.Errorf("seenTime without log time = %v, want %v", got, streamTime)
+	}
+	if got := seenTime(loki.LogEntry{}); got.IsZero() {
+		t.Error("seenTime without any time = zero, want now")
+	}
+}
@@ -0,0 +1,58 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// DefaultLabelSelector matches every stream with a container label
+const DefaultLabelSelector = `container=~".+"`
+
+// errorLinePattern is the line filter applied before parsing JSON (more
+// reliable and cheaper); the Go code does final filtering via IsError()
+const errorLinePattern = `ERROR|"status":5[0-9]{2}`
+
+// slowLinePattern additionally matches lines whose elapsed_ms has at least
+// as many integer digits as the latency threshold; IsSlow does the exact
+// check
+const slowLinePattern = `|"elapsed_ms":[0-9]{%d,}`
+
+// BuildErrorQuery builds the LogQL query selecting candidate error logs.
+// selector is the stream selector with or without braces (default:
+// DefaultLabelSelector) and extraFilters is appended after the pipeline.
+// A positive latencyThresholdMs also selects requests that may be slower, and
+// keywords also select lines containing any of the severity keywords.
+func BuildErrorQuery(selector, extraFilters string, latencyThresholdMs int, keywords []SeverityKeyword) (string, error) {
+	selector = strings.TrimSpace(selector)
+	selector = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(selector, "{"), "}"))
+	if selector == "" {
+		selector = DefaultLabelSelector
+	}
+	if !strings.ContainsAny(selector, "=~") {
+		return "", fmt.Errorf("label selector %q has no label matchers", selector)
+	}
+
+	pattern := errorLinePattern
+	if latencyThresholdMs > 0 {
+		pattern += fmt.Sprintf(slowLinePattern, len(strconv.Itoa(latencyThresholdMs)))
+	}
+	if len(keywords) > 0 {
+		quoted := make([]string, len(keywords))
+		for i, k := range keywords {
+			quoted[i] = regexp.QuoteMeta(k.Keyword)
+		}
+		pattern += "|(?i:" + strings.Join(quoted, "|") + ")"
+	}
+	query := fmt.Sprintf("{%s} |~ %s | json", selector, strconv.Quote(pattern))
+
+	if extraFilters = strings.TrimSpace(extraFilters); extraFilters != "" {
+		if !strings.HasPrefix(extraFilters, "|") {
+			extraFilters = "| " + extraFilters
+		}
+		query += " " + extraFilters
+	}
+
+	return query, nil
+}
@@ -0,0 +1,264 @@
+package processor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"vigil/loki"
+)
+
+// Queue file names within the queue directory. Entries are appended to the
+// queue file; the drainer renames it to the replay file before replaying it,
+// so a replay interrupted by a restart is picked up again.
+const (
+	queueFile  = "queue.jsonl"
+	replayFile = "replay.jsonl"
+)
+
+// Queue drain backoff bounds; the delay doubles after every drain that
+// found the issue tracker still unreachable
+const (
+	queueMinBackoff = 30 * time.Second
+	queueMaxBackoff = 10 * time.Minute
+)
+
+// maxQueueAttempts is how often a queued entry is replayed while the issue
+// tracker is reachable before it is dropped, so an entry the tracker keeps
+// rejecting doesn't stay queued forever
+const maxQueueAttempts = 10
+
+// queuedEntry is an entry whose issue couldn't be created or updated
+type queuedEntry struct {
+	Entry    loki.LogEntry `json:"entry"`
+	QueuedAt time.Time     `json:"queued_at"`
+	Error    string        `json:"error"`
+	Attempts int           `json:"attempts"`
+}
+
+// Queue persists entries whose issue couldn't be created or updated, e.g.
+// while Gitea is down, in an append-only file so they are replayed once the
+// issue tracker is back, also across restarts
+type Queue struct {
+	mu      sync.Mutex
+	dir     string
+	pending int
+}
+
+// OpenQueue opens (or creates) the queue in dir
+func OpenQueue(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+
+	q := &Queue{dir: dir}
+	for _, name := range []string{replayFile, queueFile} {
+		entries, err := q.read(name)
+		if err != nil {
+			return nil, err
+		}
+		q.pending += len(entries)
+	}
+	return q, nil
+}
+
+// Len returns the number of queued entries
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.pending
+}
+
+// push appends entries to the queue file
+func (q *Queue) push(entries ...queuedEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.append(entries)
+}
+
+// append writes entries to the queue file and syncs it; q.mu must be held
+func (q *Queue) append(entries []queuedEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(q.dir, queueFile), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open queue: %w", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write queue: %w", err)
+		}
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to write queue: %w", err)
+	}
+	q.pending += len(entries)
+	return nil
+}
+
+// take returns the entries to replay: those of a replay interrupted by a
+// restart, or else the queued entries, which are moved to the replay file.
+// Entries queued meanwhile go to a new queue file. The replay must be
+// completed with finish.
+func (q *Queue) take() ([]queuedEntry, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	replayPath := filepath.Join(q.dir, replayFile)
+	if _, err := os.Stat(replayPath); errors.Is(err, fs.ErrNotExist) {
+		err := os.Rename(filepath.Join(q.dir, queueFile), replayPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to move queue for replay: %w", err)
+		}
+	}
+	return q.read(replayFile)
+}
+
+// finish completes a replay of the taken entries, queueing the ones to retry
+// again. Entries replayed before a crash between the two steps are replayed
+// twice rather than lost.
+func (q *Queue) finish(taken, retry []queuedEntry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if err := q.append(retry); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(q.dir, replayFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove replayed queue: %w", err)
+	}
+	q.pending -= len(taken)
+	return nil
+}
+
+// read decodes the entries of a queue file. A truncated last entry, left by
+// a crash while it was written, is skipped.
+func (q *Queue) read(name string) ([]queuedEntry, error) {
+	f, err := os.Open(filepath.Join(q.dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open queue: %w", err)
+	}
+	defer f.Close()
+
+	var entries []queuedEntry
+	dec := json.NewDecoder(f)
+	for {
+		var entry queuedEntry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			log.Printf("Warning: ignoring unreadable entries at the end of %s: %v", name, err)
+			return entries, nil
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// enqueue queues an entry that failed to be processed. It reports whether
+// the entry was queued.
+func (p *Processor) enqueue(entry loki.LogEntry, cause error) bool {
+	if p.queue == nil {
+		return false
+	}
+
+	err := p.queue.push(queuedEntry{Entry: entry, QueuedAt: time.Now(), Error: cause.Error()})
+	if err != nil {
+		log.Printf("Warning: failed to queue log entry: %v", err)
+		return false
+	}
+	log.Printf("Queued log entry for retry (%d pending): %v", p.queue.Len(), cause)
+	return true
+}
+
+// runQueue replays queued entries until the context is cancelled, backing
+// off while the issue tracker is unreachable
+func (p *Processor) runQueue(ctx context.Context) {
+	delay := queueMinBackoff
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		if p.queue.Len() == 0 || p.drainQueue(ctx) {
+			delay = queueMinBackoff
+			continue
+		}
+
+		delay *= 2
+		if delay > queueMaxBackoff {
+			delay = queueMaxBackoff
+		}
+		log.Printf("Issue tracker still unavailable, retrying %d queued entries in %s", p.queue.Len(), delay)
+	}
+}
+
+// drainQueue replays the queued entries in order through the usual
+// processing, so an issue created meanwhile is updated rather than
+// duplicated. It stops at the first failure while the issue tracker is
+// unreachable and reports whether the tracker was reachable throughout.
+func (p *Processor) drainQueue(ctx context.Context) bool {
+	entries, err := p.queue.take()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		return false
+	}
+	if len(entries) == 0 {
+		return true
+	}
+
+	log.Printf("Replaying %d queued log entries", len(entries))
+	reachable := true
+	replayed := 0
+	var retry []queuedEntry
+	for i, queued := range entries {
+		err := p.processEntry(ctx, queued.Entry)
+		if err == nil {
+			replayed++
+			continue
+		}
+
+		// Keep this and the remaining entries while the tracker is down
+		if connErr := p.tracker.TestConnection(); connErr != nil {
+			reachable = false
+			retry = append(retry, entries[i:]...)
+			break
+		}
+
+		queued.Attempts++
+		queued.Error = err.Error()
+		if queued.Attempts >= maxQueueAttempts {
+			log.Printf("Warning: dropping queued log entry after %d attempts: %v", queued.Attempts, err)
+			continue
+		}
+		retry = append(retry, queued)
+	}
+
+	if err := p.queue.finish(entries, retry); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	log.Printf("Replayed %d queued log entries (%d pending)", replayed, p.queue.Len())
+	return reachable
+}
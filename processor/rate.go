@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"vigil/loki"
+)
+
+// DefaultRateWindow is the window used to compute the current error rate
+const DefaultRateWindow = 5 * time.Minute
+
+// errorRate is the recent rate of an error as reported by Loki
+type errorRate struct {
+	PerMinute float64
+	Window    time.Duration
+}
+
+// String formats the rate for the issue body, e.g. "12/min (last 5m)"
+func (r errorRate) String() string {
+	perMinute := strconv.FormatFloat(math.Round(r.PerMinute*10)/10, 'f', -1, 64)
+	return fmt.Sprintf("%s/min (last %s)", perMinute, shortDuration(r.Window))
+}
+
+// currentRate counts matching errors in Loki over the rate window. It returns
+// nil if rate lookup is disabled, no query can be built for the entry or
+// the query fails.
+func (p *Processor) currentRate(ctx context.Context, entry loki.LogEntry) *errorRate {
+	if p.rateWindow <= 0 {
+		return nil
+	}
+
+	query := rateQuery(p.query, entry, p.rateWindow)
+	if query == "" {
+		return nil
+	}
+
+	result, err := p.lokiClient.Query(ctx, query, time.Now())
+	if err != nil {
+		log.Printf("Warning: failed to query current error rate: %v", err)
+		return nil
+	}
+
+	var count float64
+	for _, sample := range result.Vector {
+		count += sample.Value.Value
+	}
+
+	return &errorRate{
+		PerMinute: count / p.rateWindow.Minutes(),
+		Window:    p.rateWindow,
+	}
+}
+
+// rateQuery builds a LogQL metric query counting lines of the error query
+// that match the entry's method, endpoint and status (or its message for
+// non-HTTP errors)
+func rateQuery(errorQuery string, entry loki.LogEntry, window time.Duration) string {
+	var filters []string
+
+	if entry.Method != "" {
+		if label := jsonLabel(entry.Parsed, "method", "request.method"); label != "" {
+			filters = append(filters, fmt.Sprintf("%s = %s", label, strconv.Quote(entry.Method)))
+		}
+	}
+	if entry.Action != "" {
+		if label := jsonLabel(entry.Parsed, "action", "request.path"); label != "" {
+			filters = append(filters, fmt.Sprintf("%s =~ %s", label, strconv.Quote(endpointPattern(entry.Action))))
+		}
+	}
+	if entry.Status > 0 {
+		if label := jsonLabel(entry.Parsed, "status", "response.status", "request.status"); label != "" {
+			filters = append(filters, fmt.Sprintf("%s = %d", label, entry.Status))
+		}
+	}
+	if len(filters) == 0 && entry.Message != "" {
+		filters = append(filters, fmt.Sprintf("msg = %s", strconv.Quote(entry.Message)))
+	}
+	if len(filters) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("sum(count_over_time(%s | %s [%ds]))",
+		errorQuery, strings.Join(filters, " | "), int(window.Seconds()))
+}
+
+// jsonLabel returns the label name Loki's json parser assigns to the first
+// of the dotted paths present in the parsed log, or "" if none is present
+func jsonLabel(parsed map[string]interface{}, paths ...string) string {
+	for _, path := range paths {
+		if value, ok := loki.LookupPath(parsed, path); ok && value != nil {
+			return strings.ReplaceAll(path, ".", "_")
+		}
+	}
+	return ""
+}
+
+// endpointPattern turns an endpoint into a regexp matching every endpoint
+// that normalizes to the same pattern
+func endpointPattern(endpoint string) string {
+	segments := strings.Split(normalizeEndpoint(endpoint), "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "[^/]+"
+		} else {
+			segments[i] = regexp.QuoteMeta(segment)
+		}
+	}
+	return strings.Join(segments, "/") + `/?([?#].*)?`
+}
+
+// shortDuration formats a duration without zero trailing units, e.g. "5m"
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
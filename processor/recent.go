@@ -0,0 +1,104 @@
+package processor
+
+import (
+	"sync"
+	"time"
+
+	"vigil/loki"
+)
+
+// Outcomes of a processed entry, as shown by Recent
+const (
+	OutcomeCreated  = "created"  // a new issue was created
+	OutcomeUpdated  = "updated"  // an occurrence was added to an open issue
+	OutcomeReopened = "reopened" // a closed issue was reopened
+	OutcomeClosed   = "closed"   // tracked in a closed issue (below minimum severity)
+	OutcomeStorm    = "storm"    // collapsed into the storm issue
+	OutcomeMuted    = "muted"    // counted without updating the muted issue
+	OutcomeSampled  = "sampled"  // counted without updating the issue (occurrence sampling)
+	OutcomeIgnored  = "ignored"  // dropped by an ignore pattern or minimum severity
+	OutcomeFailed   = "failed"   // the issue tracker returned an error
+)
+
+// RecentEntry is a processed error as shown by Recent
+type RecentEntry struct {
+	BugID       string    `json:"bug_id"`
+	Title       string    `json:"title"`
+	Service     string    `json:"service,omitempty"`
+	Severity    string    `json:"severity"`
+	Outcome     string    `json:"outcome"`
+	Reason      string    `json:"reason,omitempty"`
+	Occurrences int       `json:"occurrences,omitempty"`
+	LastSeen    time.Time `json:"last_seen"`
+	Repo        string    `json:"repo,omitempty"`
+	IssueNumber int64     `json:"issue_number,omitempty"`
+}
+
+// recentEntries is a fixed-size ring buffer of the last processed errors
+type recentEntries struct {
+	mu      sync.Mutex
+	entries []RecentEntry
+	next    int
+	full    bool
+}
+
+func newRecentEntries(size int) *recentEntries {
+	return &recentEntries{entries: make([]RecentEntry, size)}
+}
+
+// add records an entry, overwriting the oldest one when the buffer is full
+func (r *recentEntries) add(e RecentEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// list returns the recorded entries, newest first
+func (r *recentEntries) list() []RecentEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := r.next
+	if r.full {
+		n = len(r.entries)
+	}
+
+	list := make([]RecentEntry, 0, n)
+	for i := 1; i <= n; i++ {
+		list = append(list, r.entries[(r.next-i+len(r.entries))%len(r.entries)])
+	}
+	return list
+}
+
+// Recent returns the last processed errors, newest first. It returns nil
+// when the recent buffer is disabled.
+func (p *Processor) Recent() []RecentEntry {
+	if p.recent == nil {
+		return nil
+	}
+	return p.recent.list()
+}
+
+// recordRecent adds a processed entry to the recent buffer, if enabled
+func (p *Processor) recordRecent(entry loki.LogEntry, outcome string, e RecentEntry) {
+	if p.recent == nil {
+		return
+	}
+
+	if e.BugID == "" {
+		e.BugID = p.bugID(entry)
+	}
+	if e.Title == "" {
+		e.Title = p.title(entry)
+	}
+	e.Service = entry.Service
+	e.Severity = p.severity(entry)
+	e.Outcome = outcome
+	e.LastSeen = seenTime(entry)
+	p.recent.add(e)
+}
@@ -0,0 +1,168 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+
+	"vigil/gitea"
+	"vigil/loki"
+)
+
+// maxRelatedIssues limits the related issues listed in a new issue
+const maxRelatedIssues = 10
+
+// relatedHeading starts the related issues section of an issue body
+const relatedHeading = "\n## Related\n\n"
+
+// relatedIssue is an issue listed in the related section of another
+type relatedIssue struct {
+	Number int64
+	Title  string
+}
+
+// relatedLink is a newly created issue to add to the related sections of
+// its related issues
+type relatedLink struct {
+	client  IssueTracker
+	issue   relatedIssue
+	related []gitea.Issue
+}
+
+// functionLabel returns the "fn:" label of an entry, or "" if it has no
+// source function. The package path is left out to keep labels short.
+func functionLabel(entry loki.LogEntry) string {
+	if entry.Source.Function == "" {
+		return ""
+	}
+	return sanitizeLabel("fn:" + path.Base(entry.Source.Function))
+}
+
+// relatedLabels returns the labels shared by issues likely to have the same
+// root cause as an entry: its source function and error type
+func relatedLabels(entry loki.LogEntry) []string {
+	var labels []string
+	if label := functionLabel(entry); label != "" {
+		labels = append(labels, label)
+	}
+	if label := errorTypeLabel(entry); label != "" {
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// findRelated returns the issues sharing a related label with an entry,
+// other than the issues of its own bug ID, oldest first
+func (p *Processor) findRelated(client IssueTracker, entry loki.LogEntry, bugIDLabel string) []gitea.Issue {
+	seen := make(map[int64]bool)
+	var related []gitea.Issue
+	for _, label := range relatedLabels(entry) {
+		issues, err := client.SearchIssues(label)
+		if err != nil {
+			log.Printf("Warning: failed to search related issues in %s: %v", client.Repo(), err)
+			continue
+		}
+		for _, issue := range withLabel(issues, label) {
+			if seen[issue.Number] || issue.HasLabel(bugIDLabel) {
+				continue
+			}
+			seen[issue.Number] = true
+			related = append(related, issue)
+		}
+	}
+
+	sort.Slice(related, func(i, j int) bool { return related[i].Number < related[j].Number })
+	if len(related) > maxRelatedIssues {
+		related = related[:maxRelatedIssues]
+	}
+	return related
+}
+
+// linkRelated adds a new issue to the related sections of its related
+// issues. It takes the bug ID lock of each issue, so it must be called
+// without holding one.
+func (p *Processor) linkRelated(link *relatedLink) {
+	if link == nil {
+		return
+	}
+
+	for _, related := range link.related {
+		p.addRelated(link.client, related, link.issue)
+	}
+}
+
+// addRelated adds an issue to the related section of an existing issue
+func (p *Processor) addRelated(client IssueTracker, existing gitea.Issue, issue relatedIssue) {
+	if bugID := issueBugID(existing); bugID != "" {
+		unlock := p.bugLocks.Lock(p.cacheKey(client, bugID))
+		defer unlock()
+	}
+
+	// Fetch the issue again, as its body may have changed since the search
+	current, err := client.GetIssue(existing.Number)
+	if err != nil {
+		log.Printf("Warning: failed to fetch issue #%d: %v", existing.Number, err)
+		return
+	}
+
+	body := withRelated(current.Body, issue)
+	if body == current.Body {
+		return
+	}
+	if err := client.UpdateIssueBody(current.Number, body); err != nil {
+		log.Printf("Warning: failed to link issue #%d from #%d: %v", issue.Number, current.Number, err)
+		return
+	}
+	p.debugf("Linked issue #%d from related issue #%d", issue.Number, current.Number)
+}
+
+// issueBugID returns the bug ID of an issue from its bugid label
+func issueBugID(issue gitea.Issue) string {
+	for _, name := range issue.LabelNames() {
+		if strings.HasPrefix(name, "bugid:") {
+			return strings.TrimPrefix(name, "bugid:")
+		}
+	}
+	return ""
+}
+
+// relatedLine renders an issue in a related section
+func relatedLine(issue relatedIssue) string {
+	return fmt.Sprintf("- #%d %s\n", issue.Number, issue.Title)
+}
+
+// writeRelated renders the related section of a new issue body
+func writeRelated(sb *strings.Builder, related []gitea.Issue) {
+	sb.WriteString(relatedHeading)
+	for _, issue := range related {
+		sb.WriteString(relatedLine(relatedIssue{Number: issue.Number, Title: issue.Title}))
+	}
+}
+
+// withRelated adds an issue to the related section of a body, adding the
+// section before the timeline if there is none. Bodies already listing the
+// issue, or without a timeline to place the section before, are returned
+// unchanged.
+func withRelated(body string, issue relatedIssue) string {
+	line := relatedLine(issue)
+
+	if start := strings.Index(body, relatedHeading); start >= 0 {
+		sectionStart := start + len(relatedHeading)
+		end := len(body)
+		if next := strings.Index(body[sectionStart:], "\n## "); next >= 0 {
+			end = sectionStart + next
+		}
+		if strings.Contains(body[sectionStart:end], fmt.Sprintf("- #%d ", issue.Number)) {
+			return body
+		}
+		return body[:end] + line + body[end:]
+	}
+
+	timeline := strings.Index(body, "\n## Timeline\n")
+	if timeline < 0 {
+		return body
+	}
+	return body[:timeline] + relatedHeading + line + body[timeline:]
+}
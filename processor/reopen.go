@@ -0,0 +1,88 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"vigil/loki"
+)
+
+// Lines of the issue body holding the signature of the original occurrence
+var (
+	bodyStatusLine   = regexp.MustCompile(`(?m)^- \*\*Status Code:\*\* (\d+)$`)
+	bodySourceLine   = regexp.MustCompile("(?m)^\\*\\*Source:\\*\\* `([^`]*)`$")
+	bodyFileLine     = regexp.MustCompile("(?m)^\\*\\*File:\\*\\* `([^`]*)`$")
+	bodyEndpointLine = regexp.MustCompile(`(?m)^- \*\*Endpoint:\*\* (.+)$`)
+)
+
+// issueSignature is what the original occurrence of an issue looked like,
+// as recorded in the issue body
+type issueSignature struct {
+	Status   int
+	Function string
+	File     string // "file:line"
+	Endpoint string
+}
+
+// parseSignature extracts the original occurrence's signature from an issue
+// body written by generateBody. Missing lines leave fields empty.
+func parseSignature(body string) issueSignature {
+	var sig issueSignature
+	if m := bodyStatusLine.FindStringSubmatch(body); m != nil {
+		sig.Status, _ = strconv.Atoi(m[1])
+	}
+	if m := bodySourceLine.FindStringSubmatch(body); m != nil {
+		sig.Function = m[1]
+	}
+	if m := bodyFileLine.FindStringSubmatch(body); m != nil {
+		sig.File = m[1]
+	}
+	if m := bodyEndpointLine.FindStringSubmatch(body); m != nil {
+		sig.Endpoint = m[1]
+	}
+	return sig
+}
+
+// signatureChanges describes how an entry differs from the original
+// occurrence. Fields that the entry or the original lack are not compared.
+func signatureChanges(sig issueSignature, entry loki.LogEntry) []string {
+	var changes []string
+
+	if entry.Status > 0 && sig.Status > 0 && entry.Status != sig.Status {
+		changes = append(changes, fmt.Sprintf("now also failing with status %d (originally %d)", entry.Status, sig.Status))
+	}
+	if entry.Source.Function != "" && sig.Function != "" && entry.Source.Function != sig.Function {
+		changes = append(changes, fmt.Sprintf("now raised from `%s` (originally `%s`)", entry.Source.Function, sig.Function))
+	}
+	if entry.Source.File != "" && sig.File != "" {
+		if file := fmt.Sprintf("%s:%d", entry.Source.File, entry.Source.Line); file != sig.File {
+			changes = append(changes, fmt.Sprintf("source location moved to `%s` (originally `%s`)", file, sig.File))
+		}
+	}
+	// Compare normalized endpoints so differing IDs in the path don't count
+	if entry.Action != "" && sig.Endpoint != "" && normalizeEndpoint(entry.Action) != normalizeEndpoint(sig.Endpoint) {
+		changes = append(changes, fmt.Sprintf("now seen on endpoint %s (originally %s)", entry.Action, sig.Endpoint))
+	}
+
+	return changes
+}
+
+// generateReopenNote renders the part of a comment explaining a reopen,
+// including what changed since the original occurrence
+func generateReopenNote(body string, entry loki.LogEntry) string {
+	var sb strings.Builder
+
+	sb.WriteString("**Reopened:** this error came back after the issue was closed.\n")
+
+	changes := signatureChanges(parseSignature(body), entry)
+	if len(changes) > 0 {
+		sb.WriteString("\n**Changes since the original occurrence:**\n\n")
+		for _, change := range changes {
+			sb.WriteString(fmt.Sprintf("- %s\n", change))
+		}
+	}
+
+	return sb.String()
+}
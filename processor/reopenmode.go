@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vigil/gitea"
+	"vigil/loki"
+)
+
+// Reopen modes, deciding whether a closed issue is reopened when its error
+// recurs
+const (
+	ReopenAlways    = "always"    // reopen on the first new occurrence (default)
+	ReopenNever     = "never"     // comment on the closed issue but leave it closed
+	ReopenThreshold = "threshold" // reopen after a number of occurrences since it was closed
+)
+
+// ParseReopenMode parses a reopen mode: "always", "never" or "threshold:N"
+// with N a positive number of occurrences. It returns the mode and, for
+// threshold mode, N.
+func ParseReopenMode(spec string) (string, int, error) {
+	mode, arg, hasArg := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	switch mode {
+	case ReopenAlways, ReopenNever:
+		if !hasArg {
+			return mode, 0, nil
+		}
+	case ReopenThreshold:
+		if n, err := strconv.Atoi(arg); err == nil && n > 0 {
+			return mode, n, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unknown reopen mode %q (expected always, never or threshold:N)", spec)
+}
+
+// closedOccurrences counts the occurrences of closed issues since they were
+// closed, for threshold mode. Counts are kept in memory only.
+type closedOccurrences struct {
+	mu     sync.Mutex
+	counts map[string]closedCount // cache key -> count
+}
+
+// closedCount is the number of occurrences since an issue was closed
+type closedCount struct {
+	closedAt time.Time
+	count    int
+}
+
+func newClosedOccurrences() *closedOccurrences {
+	return &closedOccurrences{counts: make(map[string]closedCount)}
+}
+
+// add counts an occurrence of a closed issue and returns the number of
+// occurrences since it was closed. An issue closed again since the last
+// occurrence starts over.
+func (c *closedOccurrences) add(key string, closedAt time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := c.counts[key]
+	if !current.closedAt.Equal(closedAt) {
+		current = closedCount{closedAt: closedAt}
+	}
+	current.count++
+	c.counts[key] = current
+	return current.count
+}
+
+// reset forgets the count of an issue, e.g. once it is reopened
+func (c *closedOccurrences) reset(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counts, key)
+}
+
+// shouldReopen decides whether a closed issue is reopened for an entry,
+// according to the reopen mode. Entries only tracked in closed issues never
+// reopen them. key is the repository-scoped cache key.
+func (p *Processor) shouldReopen(existing gitea.Issue, entry loki.LogEntry, key string) bool {
+	if existing.State != "closed" || p.tracksClosed(entry) {
+		return false
+	}
+
+	switch p.reopenMode {
+	case ReopenNever:
+		p.debugf("Leaving issue #%d closed (reopen mode never)", existing.Number)
+		return false
+	case ReopenThreshold:
+		var closedAt time.Time
+		if existing.ClosedAt != nil {
+			closedAt = *existing.ClosedAt
+		}
+		count := p.closedCounts.add(key, closedAt)
+		if count < p.reopenThreshold {
+			p.debugf("Leaving issue #%d closed (%d of %d occurrences to reopen)", existing.Number, count, p.reopenThreshold)
+			return false
+		}
+		p.closedCounts.reset(key)
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"vigil/gitea"
+	"vigil/notifier"
+)
+
+// Resolution labels record why vigil closed an issue, so issues closed
+// automatically can be told apart from issues closed by hand. They are
+// removed when the issue is reopened.
+const (
+	resolutionLabelPrefix = "resolution:"
+	ResolutionAutoStale   = resolutionLabelPrefix + "auto-stale" // closed after the quiet period
+)
+
+// resolutionLabelColors are the colors of the resolution labels
+var resolutionLabelColors = map[string]string{
+	ResolutionAutoStale: "c5def5", // light blue
+}
+
+// DefaultCloseCommentTemplate is the comment posted when an issue is
+// closed after the quiet period
+const DefaultCloseCommentTemplate = "**Resolved:** no occurrences for {{.QuietFor}} (last seen `{{.LastSeen}}`). " +
+	"Closing automatically; the issue is reopened if the error recurs."
+
+// closeComment is the data the close comment template is rendered with
+type closeComment struct {
+	Number      int64
+	Title       string
+	BugID       string
+	Resolution  string // the resolution label, e.g. resolution:auto-stale
+	QuietFor    time.Duration
+	LastSeen    string // RFC 3339
+	Occurrences int
+}
+
+// ParseCloseCommentTemplate parses the template of the comment posted when
+// an issue is closed, e.g. "Closed after {{.QuietFor}} without errors"
+func ParseCloseCommentTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("close_comment").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid close comment template: %w", err)
+	}
+	return tmpl, nil
+}
+
+var defaultCloseCommentTemplate = template.Must(ParseCloseCommentTemplate(DefaultCloseCommentTemplate))
+
+// activeIssue is an issue that had occurrences since vigil started
+type activeIssue struct {
+	key         string // repository-scoped bug ID
+	client      IssueTracker
+	info        notifier.IssueInfo
+	lastSeen    time.Time
+	occurrences int
+}
+
+// activeIssues tracks when each active issue last occurred
+type activeIssues struct {
+	mu     sync.Mutex
+	issues map[string]*activeIssue
+}
+
+func newActiveIssues() *activeIssues {
+	return &activeIssues{issues: make(map[string]*activeIssue)}
+}
+
+// touch records an occurrence of an issue
+func (a *activeIssues) touch(issue activeIssue) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.issues[issue.key] = &issue
+}
+
+// seen records a further occurrence of a tracked issue; untracked issues
+// are left alone
+func (a *activeIssues) seen(key string, lastSeen time.Time, occurrences int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if issue, ok := a.issues[key]; ok {
+		issue.lastSeen = lastSeen
+		issue.occurrences = occurrences
+	}
+}
+
+// has reports whether an issue is being tracked
+func (a *activeIssues) has(key string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, ok := a.issues[key]
+	return ok
+}
+
+// expire removes and returns the issues not seen since before cutoff
+func (a *activeIssues) expire(cutoff time.Time) []activeIssue {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var expired []activeIssue
+	for key, issue := range a.issues {
+		if issue.lastSeen.Before(cutoff) {
+			expired = append(expired, *issue)
+			delete(a.issues, key)
+		}
+	}
+	return expired
+}
+
+// trackOccurrence records an occurrence for resolution tracking, if enabled
+func (p *Processor) trackOccurrence(issue activeIssue) {
+	if p.resolveAfter <= 0 {
+		return
+	}
+	p.active.touch(issue)
+}
+
+// runResolver periodically resolves issues that have gone quiet until the
+// context is cancelled
+func (p *Processor) runResolver(ctx context.Context) {
+	interval := p.resolveAfter / 4
+	if interval > time.Minute {
+		interval = time.Minute
+	} else if interval < time.Second {
+		interval = time.Second
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, issue := range p.active.expire(time.Now().Add(-p.resolveAfter)) {
+				p.resolve(issue)
+			}
+		}
+	}
+}
+
+// resolve notifies that an issue has had no occurrences for the quiet
+// period and optionally closes it
+func (p *Processor) resolve(issue activeIssue) {
+	unlock := p.bugLocks.Lock(issue.key)
+	defer unlock()
+
+	// An occurrence processed since the issue expired keeps it active
+	if p.active.has(issue.key) {
+		return
+	}
+
+	log.Printf("Issue %s#%d resolved (no occurrences for %s)", issue.client.Repo(), issue.info.Number, p.resolveAfter)
+
+	if p.resolveClose {
+		comment := p.closeComment(closeComment{
+			Number:      issue.info.Number,
+			Title:       issue.info.Title,
+			BugID:       issue.info.BugID,
+			Resolution:  ResolutionAutoStale,
+			QuietFor:    p.resolveAfter,
+			LastSeen:    issue.lastSeen.Format(time.RFC3339),
+			Occurrences: issue.occurrences,
+		})
+		if err := issue.client.AddComment(issue.info.Number, comment); err != nil {
+			log.Printf("Warning: failed to comment on resolved issue #%d: %v", issue.info.Number, err)
+		}
+		if err := issue.client.CloseIssue(issue.info.Number); err != nil {
+			log.Printf("Warning: failed to close resolved issue #%d: %v", issue.info.Number, err)
+		} else {
+			issue.info.Closed = true
+			if err := issue.client.AddLabels(issue.info.Number, []string{ResolutionAutoStale}); err != nil {
+				log.Printf("Warning: failed to add label %s to issue #%d: %v", ResolutionAutoStale, issue.info.Number, err)
+			}
+		}
+	}
+
+	info := issue.info
+	info.Occurrences = issue.occurrences
+	info.QuietFor = p.resolveAfter
+	for _, n := range p.notifiersFor(info.Severity) {
+		if err := n.NotifyResolvedIssue(&info); err != nil {
+			log.Printf("Error sending notification: %v", err)
+		}
+	}
+}
+
+// closeComment renders the comment posted when closing an issue, falling
+// back to the default template if the configured one fails
+func (p *Processor) closeComment(data closeComment) string {
+	tmpl := p.closeCommentTemplate
+	if tmpl == nil {
+		tmpl = defaultCloseCommentTemplate
+	}
+
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		log.Printf("Warning: failed to render close comment for issue #%d: %v", data.Number, err)
+		sb.Reset()
+		defaultCloseCommentTemplate.Execute(&sb, data)
+	}
+	return sb.String()
+}
+
+// clearResolution removes the resolution labels from an issue being
+// reopened, since the reason it was closed no longer holds
+func (p *Processor) clearResolution(client IssueTracker, issue gitea.Issue) {
+	for _, label := range issue.Labels {
+		if strings.HasPrefix(label.Name, resolutionLabelPrefix) {
+			if err := client.RemoveLabel(issue.Number, label.Name); err != nil {
+				log.Printf("Warning: failed to remove label %s from issue #%d: %v", label.Name, issue.Number, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"vigil/gitea"
+	"vigil/loki"
+)
+
+// ParseRepoRoutes parses a comma-separated list of service=repo routes, where
+// repo is "owner/repo" or just "repo" in defaultOwner. Services routed to the
+// same repository share one client.
+func ParseRepoRoutes(spec string, base *gitea.Client, defaultOwner string) (map[string]IssueTracker, error) {
+	routes := make(map[string]IssueTracker)
+	clients := make(map[string]IssueTracker)
+
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		service, repo, ok := strings.Cut(item, "=")
+		service = strings.TrimSpace(service)
+		repo = strings.TrimSpace(repo)
+		if !ok || service == "" || repo == "" {
+			return nil, fmt.Errorf("invalid route %q (expected service=owner/repo)", item)
+		}
+
+		owner, name, ok := strings.Cut(repo, "/")
+		if !ok {
+			owner, name = defaultOwner, repo
+		}
+		if owner == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid repository %q for service %s", repo, service)
+		}
+
+		key := owner + "/" + name
+		client, ok := clients[key]
+		if !ok {
+			client = base.ForRepo(owner, name)
+			clients[key] = client
+		}
+		routes[service] = client
+	}
+
+	return routes, nil
+}
+
+// DefaultUnroutedLabel marks issues of services without a route that were
+// filed in the default repository
+const DefaultUnroutedLabel = "unrouted"
+
+// unroutedServices remembers the services without a route that have already
+// been warned about
+type unroutedServices struct {
+	mu     sync.Mutex
+	warned map[string]bool
+}
+
+func newUnroutedServices() *unroutedServices {
+	return &unroutedServices{warned: make(map[string]bool)}
+}
+
+// add records a service and reports whether it was seen for the first time
+func (u *unroutedServices) add(service string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.warned[service] {
+		return false
+	}
+	u.warned[service] = true
+	return true
+}
+
+// clientFor returns the Gitea client for the repository an entry is routed
+// to, falling back to the default repository
+func (p *Processor) clientFor(entry loki.LogEntry) IssueTracker {
+	if client, ok := p.repoRoutes[entry.Service]; ok && entry.Service != "" {
+		return client
+	}
+	if p.unrouted(entry) && p.unroutedServices.add(entry.Service) {
+		log.Printf("Warning: service %s has no route in REPO_ROUTES, filing its errors in %s with label %s",
+			entry.Service, p.tracker.Repo(), p.unroutedLabel)
+	}
+	return p.tracker
+}
+
+// unrouted reports whether an entry names a service that has no route while
+// routing is configured. Entries without a service can't be routed and are
+// not considered unrouted.
+func (p *Processor) unrouted(entry loki.LogEntry) bool {
+	if len(p.repoRoutes) == 0 || entry.Service == "" {
+		return false
+	}
+	_, ok := p.repoRoutes[entry.Service]
+	return !ok
+}
+
+// clients returns the default client followed by each distinct routed client
+func (p *Processor) clients() []IssueTracker {
+	clients := []IssueTracker{p.tracker}
+	seen := map[string]bool{p.tracker.Repo(): true}
+	for _, client := range p.repoRoutes {
+		if !seen[client.Repo()] {
+			seen[client.Repo()] = true
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
+
+// cacheKey scopes a bug ID to its repository. Bug IDs in the default
+// repository are used as-is so existing cache entries stay valid.
+func (p *Processor) cacheKey(client IssueTracker, bugID string) string {
+	if client.Repo() == p.tracker.Repo() {
+		return bugID
+	}
+	return client.Repo() + ":" + bugID
+}
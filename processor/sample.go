@@ -0,0 +1,116 @@
+package processor
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"vigil/loki"
+)
+
+// DefaultSampleThreshold is the number of occurrences of a bug ID per
+// minute above which occurrences are sampled
+const DefaultSampleThreshold = 60
+
+// sampleWindow is the window occurrences are counted in for sampling
+const sampleWindow = time.Minute
+
+// sampledBug is the sampling state of a bug ID
+type sampledBug struct {
+	windowStart time.Time
+	count       int // occurrences in the current window
+	skipped     int // occurrences skipped since the last one written
+}
+
+// occurrenceSampler decides which occurrences of high-volume bug IDs are
+// written to the issue tracker: above threshold occurrences per minute,
+// only a rate fraction of them is
+type occurrenceSampler struct {
+	mu        sync.Mutex
+	rate      float64
+	threshold int
+	bugs      map[string]*sampledBug
+}
+
+func newOccurrenceSampler(rate float64, threshold int) *occurrenceSampler {
+	if threshold <= 0 {
+		threshold = DefaultSampleThreshold
+	}
+	return &occurrenceSampler{rate: rate, threshold: threshold, bugs: make(map[string]*sampledBug)}
+}
+
+// skip counts an occurrence of a bug ID seen at t and reports whether it is
+// sampled out
+func (s *occurrenceSampler) skip(key string, t time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bug, ok := s.bugs[key]
+	if !ok {
+		bug = &sampledBug{windowStart: t}
+		s.bugs[key] = bug
+	}
+	if t.Sub(bug.windowStart) >= sampleWindow || t.Before(bug.windowStart) {
+		bug.windowStart = t
+		bug.count = 0
+	}
+
+	bug.count++
+	if bug.count <= s.threshold || rand.Float64() < s.rate {
+		return false
+	}
+	bug.skipped++
+	return true
+}
+
+// take returns and resets the number of occurrences of a bug ID skipped
+// since the last one written
+func (s *occurrenceSampler) take(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bug, ok := s.bugs[key]
+	if !ok {
+		return 0
+	}
+	skipped := bug.skipped
+	bug.skipped = 0
+	return skipped
+}
+
+// sampleOccurrence counts an occurrence of an issue already written to
+// without writing to the issue tracker, if sampling skips it. It reports
+// whether the occurrence was skipped. key is the repository-scoped cache
+// key.
+func (p *Processor) sampleOccurrence(client IssueTracker, entry loki.LogEntry, key string) bool {
+	if p.sampler == nil {
+		return false
+	}
+
+	// Only occurrences of issues with a known count are sampled
+	cached, err := p.cache.Get(key)
+	if err != nil || cached == nil || cached.Occurrences == 0 {
+		return false
+	}
+	if !p.sampler.skip(key, seenTime(entry)) {
+		return false
+	}
+
+	occurrences := cached.Occurrences + 1
+	if p.commentMode == CommentModeStats {
+		occurrences, _, _ = p.stats.record(key, entry, cached.Occurrences)
+	}
+	p.cachePut(key, cached.IssueNumber, entry.Timestamp, occurrences)
+
+	// Skipped occurrences still keep the issue from going quiet
+	p.active.seen(key, seenTime(entry), occurrences)
+	p.escalated.seen(key, seenTime(entry), occurrences)
+
+	p.debugf("Sampled out occurrence %d of issue #%d", occurrences, cached.IssueNumber)
+	p.recordRecent(entry, OutcomeSampled, RecentEntry{
+		Occurrences: occurrences,
+		Repo:        client.Repo(),
+		IssueNumber: cached.IssueNumber,
+	})
+	return true
+}
@@ -0,0 +1,89 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DigestSchedule is when the scheduled digest is sent: at a fixed interval,
+// or daily or weekly at a time of day (in local time)
+type DigestSchedule struct {
+	Every   time.Duration // interval schedules
+	Weekly  bool
+	Weekday time.Weekday
+	Hour    int
+	Minute  int
+}
+
+// weekdays maps weekday names and their three-letter abbreviations
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseDigestSchedule parses a digest schedule: an interval like "24h",
+// "daily HH:MM" or "weekly DAY HH:MM" (e.g. "weekly mon 09:00")
+func ParseDigestSchedule(spec string) (DigestSchedule, error) {
+	fields := strings.Fields(strings.ToLower(spec))
+	invalid := fmt.Errorf("invalid schedule %q (expected an interval like 24h, daily HH:MM or weekly DAY HH:MM)", spec)
+
+	if len(fields) == 1 {
+		d, err := time.ParseDuration(fields[0])
+		if err != nil || d < time.Minute {
+			return DigestSchedule{}, invalid
+		}
+		return DigestSchedule{Every: d}, nil
+	}
+
+	var schedule DigestSchedule
+	var clock string
+	switch {
+	case len(fields) == 2 && fields[0] == "daily":
+		clock = fields[1]
+	case len(fields) == 3 && fields[0] == "weekly":
+		day, ok := weekdays[fields[1]]
+		if !ok && len(fields[1]) > 3 {
+			day, ok = weekdays[fields[1][:3]]
+		}
+		if !ok {
+			return DigestSchedule{}, invalid
+		}
+		schedule.Weekly, schedule.Weekday = true, day
+		clock = fields[2]
+	default:
+		return DigestSchedule{}, invalid
+	}
+
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return DigestSchedule{}, invalid
+	}
+	schedule.Hour, schedule.Minute = t.Hour(), t.Minute()
+	return schedule, nil
+}
+
+// Next returns the first time after after that the digest is due
+func (s DigestSchedule) Next(after time.Time) time.Time {
+	if s.Every > 0 {
+		return after.Add(s.Every)
+	}
+
+	next := time.Date(after.Year(), after.Month(), after.Day(), s.Hour, s.Minute, 0, 0, after.Location())
+	for !next.After(after) || (s.Weekly && next.Weekday() != s.Weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// String describes the schedule
+func (s DigestSchedule) String() string {
+	switch {
+	case s.Every > 0:
+		return "every " + s.Every.String()
+	case s.Weekly:
+		return fmt.Sprintf("weekly on %s at %02d:%02d", s.Weekday, s.Hour, s.Minute)
+	default:
+		return fmt.Sprintf("daily at %02d:%02d", s.Hour, s.Minute)
+	}
+}
@@ -0,0 +1,86 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"vigil/loki"
+)
+
+// Issue body sections
+const (
+	SectionDetails    = "details"
+	SectionRequest    = "request"
+	SectionStacktrace = "stacktrace"
+	SectionLinks      = "links"
+	SectionRelated    = "related"
+	SectionTimeline   = "timeline"
+	SectionContext    = "context"
+	SectionSample     = "sample"
+)
+
+// DefaultBodySections are the sections of an issue body, in order
+var DefaultBodySections = []string{
+	SectionDetails,
+	SectionRequest,
+	SectionStacktrace,
+	SectionLinks,
+	SectionRelated,
+	SectionTimeline,
+	SectionContext,
+	SectionSample,
+}
+
+// ParseBodySections parses a comma-separated, ordered list of issue body
+// sections, e.g. "details,links,request,timeline"
+func ParseBodySections(spec string) ([]string, error) {
+	var sections []string
+	seen := make(map[string]bool)
+
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if !contains(DefaultBodySections, name) {
+			return nil, fmt.Errorf("unknown section %q (expected %s)", name, strings.Join(DefaultBodySections, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate section %q", name)
+		}
+		seen[name] = true
+		sections = append(sections, name)
+	}
+
+	if len(sections) == 0 {
+		return nil, fmt.Errorf("no sections")
+	}
+	return sections, nil
+}
+
+// writeBodySection renders a section of an issue body other than the
+// sample log. Sections without content for the entry are left out.
+func writeBodySection(sb *strings.Builder, name string, entry loki.LogEntry, extras bodyExtras) {
+	switch name {
+	case SectionDetails:
+		writeErrorDetails(sb, entry, extras)
+	case SectionRequest:
+		writeRequestInfo(sb, entry, extras)
+	case SectionStacktrace:
+		if entry.Stacktrace != "" {
+			sb.WriteString("\n" + generateStacktrace(entry.Stacktrace, extras.StackLines))
+		}
+	case SectionLinks:
+		writeLinks(sb, extras.Links)
+	case SectionRelated:
+		if len(extras.Related) > 0 {
+			writeRelated(sb, extras.Related)
+		}
+	case SectionTimeline:
+		writeTimeline(sb, entry, extras)
+	case SectionContext:
+		if extras.Context != nil {
+			writeContext(sb, extras.Context)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package processor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"vigil/loki"
+)
+
+// seenEntries remembers entries already handled so that overlapping query
+// windows don't process the same log line twice
+type seenEntries struct {
+	entries map[string]time.Time
+}
+
+func newSeenEntries() *seenEntries {
+	return &seenEntries{entries: make(map[string]time.Time)}
+}
+
+// add records an entry and reports whether it was not seen before
+func (s *seenEntries) add(entry loki.LogEntry) bool {
+	key := entryKey(entry)
+	if _, ok := s.entries[key]; ok {
+		return false
+	}
+	s.entries[key] = entry.Timestamp
+	return true
+}
+
+// prune forgets entries older than cutoff, which can no longer be returned
+// by a query
+func (s *seenEntries) prune(cutoff time.Time) {
+	for key, ts := range s.entries {
+		if ts.Before(cutoff) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// entryKey identifies a log line by its timestamp and content
+func entryKey(entry loki.LogEntry) string {
+	hash := sha256.Sum256([]byte(fmt.Sprintf("%d|%s", entry.Timestamp.UnixNano(), entry.Raw)))
+	return hex.EncodeToString(hash[:16])
+}
@@ -0,0 +1,65 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestPollOverlapCatchesLateEntries checks that an entry ingested after the
+// poll covering its timestamp is caught by the next poll's overlap, and
+// that entries returned again by the overlapping query are not processed
+// twice
+func TestPollOverlapCatchesLateEntries(t *testing.T) {
+	var mu sync.Mutex
+	var available []lokiLine
+
+	// The stub only returns the lines ingested so far within the queried
+	// range, like Loki
+	loki := newLokiServer(t, func(r *http.Request) []byte {
+		start, _ := strconv.ParseInt(r.URL.Query().Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(r.URL.Query().Get("end"), 10, 64)
+		mu.Lock()
+		defer mu.Unlock()
+		var lines []lokiLine
+		for _, l := range available {
+			if ts := l.ts.UnixNano(); ts >= start && ts <= end {
+				lines = append(lines, l)
+			}
+		}
+		return lokiResponse(lines)
+	})
+	ingest := func(l lokiLine) {
+		mu.Lock()
+		defer mu.Unlock()
+		available = append(available, l)
+	}
+
+	tracker := newFakeGitea(t)
+	p := newTestProcessor(tracker, Config{
+		LokiURL:  loki.URL,
+		Lookback: time.Minute,
+		Overlap:  30 * time.Second,
+	})
+
+	ingest(lokiLine{ts: time.Now().Add(-20 * time.Second), line: `{"level":"error","msg":"early","method":"GET","action":"/api/early","status":500}`})
+	p.poll(context.Background())
+	firstPoll := p.lastPoll
+
+	// Logged before the first poll ended, but only queryable after it
+	ingest(lokiLine{ts: firstPoll.Add(-5 * time.Second), line: `{"level":"error","msg":"late","method":"GET","action":"/api/late","status":500}`})
+	p.poll(context.Background())
+
+	created := tracker.created()
+	if len(created) != 2 {
+		t.Fatalf("created %d issues, want 2 (early and late): %q", len(created), created)
+	}
+	for number := int64(1); number <= 2; number++ {
+		if comments := tracker.commentsOn(number); len(comments) != 0 {
+			t.Errorf("issue #%d has %d comments, want none as each entry is processed once", number, len(comments))
+		}
+	}
+}
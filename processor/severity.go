@@ -0,0 +1,53 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"vigil/loki"
+	"vigil/notifier"
+)
+
+// Severity levels, ordered from least to most severe
+const (
+	SeverityWarning  = notifier.SeverityWarning
+	SeverityError    = notifier.SeverityError
+	SeverityCritical = notifier.SeverityCritical
+)
+
+// ParseSeverity validates a severity name, returning it in canonical form
+func ParseSeverity(s string) (string, error) {
+	severity := strings.ToLower(strings.TrimSpace(s))
+	if notifier.SeverityRank(severity) == 0 {
+		return "", fmt.Errorf("unknown severity %q (expected warning, error or critical)", s)
+	}
+	return severity, nil
+}
+
+// entrySeverity determines the severity of a log entry from its error
+// category: panics and server errors are critical, client errors are
+// warnings, and other errors follow the log level
+func entrySeverity(entry loki.LogEntry) string {
+	switch entry.ErrorCategory() {
+	case loki.CategoryPanic, loki.CategoryServerError:
+		return SeverityCritical
+	case loki.CategoryClientError:
+		return SeverityWarning
+	}
+	if strings.EqualFold(entry.Level, "error") {
+		return SeverityError
+	}
+	return SeverityWarning
+}
+
+// tracksClosed reports whether an entry is below the minimum severity and
+// only tracked in a closed issue (CreateClosed)
+func (p *Processor) tracksClosed(entry loki.LogEntry) bool {
+	return p.createClosed && !meetsSeverity(p.severity(entry), p.minSeverity)
+}
+
+// meetsSeverity reports whether severity is at or above the minimum.
+// An empty minimum accepts everything.
+func meetsSeverity(severity, minimum string) bool {
+	return notifier.MeetsSeverity(severity, minimum)
+}
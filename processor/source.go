@@ -0,0 +1,66 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"vigil/loki"
+)
+
+// Log sources
+const (
+	SourceLoki = "loki" // query Loki (default)
+	SourceFile = "file" // read log lines from a file or stdin
+)
+
+// maxSourceLineBytes limits the size of a log line read from a file
+const maxSourceLineBytes = 1 << 20
+
+// ProcessLines reads log lines from r, one per line, and runs them through
+// the same parsing and processing as entries queried from Loki, in order,
+// until r is exhausted or the context is cancelled. parse builds an entry
+// from a line; lines without a timestamp field get the time they are read.
+//
+// As with a backfill, storm detection is disabled since the lines are
+// processed far faster than they were logged.
+func (p *Processor) ProcessLines(ctx context.Context, r io.Reader, parse func(ts time.Time, line string) loki.LogEntry) error {
+	if err := p.tracker.TestConnection(); err != nil {
+		return fmt.Errorf("issue tracker connection test failed: %w", err)
+	}
+	for _, client := range p.clients() {
+		p.ensureLabels(client)
+	}
+
+	p.storm = nil
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxSourceLineBytes)
+
+	lines, errorCount := 0, 0
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lines++
+
+		if p.handleEntry(ctx, parse(time.Now(), line)) {
+			errorCount++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read log lines: %w", err)
+	}
+
+	log.Printf("Processed %d lines: %d error entries", lines, errorCount)
+	return nil
+}
@@ -0,0 +1,61 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"vigil/loki"
+)
+
+// DefaultStacktraceLines is the number of stack trace lines shown in an
+// issue body
+const DefaultStacktraceLines = 50
+
+// generateStacktrace renders a stack trace as a collapsed <details> block,
+// keeping at most maxLines lines (0 for no limit)
+func generateStacktrace(stack string, maxLines int) string {
+	lines := strings.Split(strings.TrimRight(stack, "\n"), "\n")
+
+	summary := fmt.Sprintf("Stack trace (%d lines)", len(lines))
+	if len(lines) == 1 {
+		summary = "Stack trace (1 line)"
+	}
+
+	omitted := 0
+	if maxLines > 0 && len(lines) > maxLines {
+		omitted = len(lines) - maxLines
+		lines = lines[:maxLines]
+	}
+
+	var sb strings.Builder
+	sb.WriteString("<details>\n")
+	sb.WriteString(fmt.Sprintf("<summary>%s</summary>\n\n", summary))
+	sb.WriteString("```\n")
+	for _, line := range lines {
+		// A fence inside the trace would end the code block early
+		sb.WriteString(strings.ReplaceAll(line, "```", "'''") + "\n")
+	}
+	if omitted > 0 {
+		sb.WriteString(fmt.Sprintf("... (%d more lines)\n", omitted))
+	}
+	sb.WriteString("```\n\n")
+	sb.WriteString("</details>\n")
+	return sb.String()
+}
+
+// withoutStacktrace returns the parsed log without its stack trace fields,
+// which are rendered separately
+func withoutStacktrace(entry loki.LogEntry) map[string]interface{} {
+	if entry.Stacktrace == "" {
+		return entry.Parsed
+	}
+
+	parsed := make(map[string]interface{}, len(entry.Parsed))
+	for key, value := range entry.Parsed {
+		parsed[key] = value
+	}
+	for _, field := range loki.StacktraceFields {
+		delete(parsed, field)
+	}
+	return parsed
+}
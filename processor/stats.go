@@ -0,0 +1,183 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"vigil/gitea"
+	"vigil/loki"
+)
+
+// Comment modes
+const (
+	CommentModeOccurrence = "occurrence" // one comment per occurrence
+	CommentModeStats      = "stats"      // a single rolling stats comment
+)
+
+// statsMarker identifies the stats comment so it can be found again after
+// a restart
+const statsMarker = "<!-- vigil:stats -->"
+
+// statsHours is the number of hourly buckets shown in the sparkline
+const statsHours = 24
+
+// sparkBars are the sparkline characters from lowest to highest
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// bugStats aggregates the occurrences of one bug ID
+type bugStats struct {
+	commentID int64
+	total     int
+	users     map[string]struct{}
+	requests  map[string]struct{}
+	hourly    map[int64]int // occurrences by Unix hour
+	lastSeen  time.Time
+}
+
+// statsTracker holds the aggregates of every bug ID in stats comment mode
+type statsTracker struct {
+	mu   sync.Mutex
+	bugs map[string]*bugStats
+}
+
+func newStatsTracker() *statsTracker {
+	return &statsTracker{bugs: make(map[string]*bugStats)}
+}
+
+// record adds an occurrence of a bug ID and returns the new total, the
+// rendered stats comment and the ID of the stats comment (0 if none yet).
+// baseline is the number of earlier occurrences when the bug ID is not yet
+// tracked.
+func (t *statsTracker) record(key string, entry loki.LogEntry, baseline int) (int, string, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	stats, ok := t.bugs[key]
+	if !ok {
+		stats = &bugStats{
+			total:    baseline,
+			users:    make(map[string]struct{}),
+			requests: make(map[string]struct{}),
+			hourly:   make(map[int64]int),
+		}
+		t.bugs[key] = stats
+	}
+
+	seen := seenTime(entry)
+	stats.total++
+	stats.lastSeen = seen
+	if entry.UserID != "" {
+		stats.users[entry.UserID] = struct{}{}
+	}
+	if entry.RequestID != "" {
+		stats.requests[entry.RequestID] = struct{}{}
+	}
+
+	hour := seen.Unix() / 3600
+	stats.hourly[hour]++
+	for h := range stats.hourly {
+		if h <= hour-statsHours {
+			delete(stats.hourly, h)
+		}
+	}
+
+	return stats.total, generateStatsComment(stats, hour), stats.commentID
+}
+
+// setCommentID remembers the stats comment of a bug ID
+func (t *statsTracker) setCommentID(key string, commentID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if stats, ok := t.bugs[key]; ok {
+		stats.commentID = commentID
+	}
+}
+
+// updateStatsComment records an occurrence in stats mode and creates or
+// updates the issue's stats comment. It returns the total occurrences.
+func (p *Processor) updateStatsComment(client IssueTracker, existing gitea.Issue, entry loki.LogEntry, key string) (int, error) {
+	// Untracked bug IDs (e.g. after a restart) continue from the cached
+	// count, or from the per-occurrence comment count
+	baseline := existing.Comments + 1
+	if cached, err := p.cache.Get(key); err == nil && cached != nil && cached.Occurrences > 0 {
+		baseline = cached.Occurrences
+	}
+
+	occurrences, body, commentID := p.stats.record(key, entry, baseline)
+
+	if commentID == 0 {
+		if commentID = findStatsComment(client, existing.Number); commentID != 0 {
+			p.stats.setCommentID(key, commentID)
+		}
+	}
+
+	if commentID != 0 {
+		err := client.UpdateComment(commentID, body)
+		if err == nil {
+			return occurrences, nil
+		}
+		log.Printf("Warning: failed to update stats comment on issue #%d, creating a new one: %v", existing.Number, err)
+	}
+
+	comment, err := client.CreateComment(existing.Number, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to add stats comment: %w", err)
+	}
+	p.stats.setCommentID(key, comment.ID)
+	return occurrences, nil
+}
+
+// findStatsComment returns the ID of an issue's existing stats comment, or 0
+func findStatsComment(client IssueTracker, issueNumber int64) int64 {
+	comments, err := client.ListComments(issueNumber)
+	if err != nil {
+		log.Printf("Warning: failed to list comments on issue #%d: %v", issueNumber, err)
+		return 0
+	}
+	for i := len(comments) - 1; i >= 0; i-- {
+		if strings.Contains(comments[i].Body, statsMarker) {
+			return comments[i].ID
+		}
+	}
+	return 0
+}
+
+// generateStatsComment renders the rolling stats comment
+func generateStatsComment(stats *bugStats, hour int64) string {
+	var sb strings.Builder
+
+	sb.WriteString(statsMarker + "\n## Occurrence Stats\n\n")
+	sb.WriteString(fmt.Sprintf("- **Total occurrences:** %d\n", stats.total))
+	sb.WriteString(fmt.Sprintf("- **Unique users affected:** %d\n", len(stats.users)))
+	sb.WriteString(fmt.Sprintf("- **Unique request IDs:** %d\n", len(stats.requests)))
+	sb.WriteString(fmt.Sprintf("- **Last occurrence:** `%s`\n", stats.lastSeen.Format(time.RFC3339)))
+
+	sb.WriteString(fmt.Sprintf("\n**Occurrences per hour (last %dh):** `%s`\n", statsHours, sparkline(stats.hourly, hour)))
+
+	sb.WriteString("\n*Updated automatically by issue-tracker*\n")
+	return sb.String()
+}
+
+// sparkline renders hourly counts for the statsHours hours up to hour
+func sparkline(hourly map[int64]int, hour int64) string {
+	max := 0
+	for _, count := range hourly {
+		if count > max {
+			max = count
+		}
+	}
+
+	var sb strings.Builder
+	for h := hour - statsHours + 1; h <= hour; h++ {
+		count := hourly[h]
+		if max == 0 || count == 0 {
+			sb.WriteRune(sparkBars[0])
+			continue
+		}
+		sb.WriteRune(sparkBars[(count*(len(sparkBars)-1)+max-1)/max])
+	}
+	return sb.String()
+}
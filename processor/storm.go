@@ -0,0 +1,224 @@
+package processor
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vigil/gitea"
+	"vigil/loki"
+	"vigil/notifier"
+)
+
+// DefaultStormWindow is the window in which distinct new bug IDs are counted
+// for storm detection
+const DefaultStormWindow = 5 * time.Minute
+
+// stormLabel marks the aggregate issue created during an error storm
+const stormLabel = "storm"
+
+// stormError is an error collapsed into the storm issue instead of getting
+// its own issue
+type stormError struct {
+	bugID       string
+	title       string
+	service     string
+	severity    string
+	occurrences int
+	firstSeen   time.Time
+}
+
+// createdIssue is a new issue created in the current storm window
+type createdIssue struct {
+	at    time.Time
+	title string
+	url   string
+}
+
+// storm tracks new bug IDs to detect error storms. Once more than threshold
+// distinct bug IDs appear within window, further new bug IDs are collapsed
+// into a single storm issue until none has appeared for a whole window.
+type storm struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+
+	created []createdIssue // issues created within the window
+	before  []createdIssue // issues created in the window the storm started in
+
+	issue     *gitea.Issue // current storm issue, nil when no storm is active
+	client    IssueTracker
+	started   time.Time
+	lastError time.Time
+	errors    map[string]*stormError
+}
+
+func newStorm(threshold int, window time.Duration) *storm {
+	if window <= 0 {
+		window = DefaultStormWindow
+	}
+	return &storm{threshold: threshold, window: window}
+}
+
+// prune drops created issues outside the window and ends a storm that has
+// been quiet for a whole window. Must be called with s.mu held.
+func (s *storm) prune(now time.Time) {
+	cutoff := now.Add(-s.window)
+	i := 0
+	for i < len(s.created) && s.created[i].at.Before(cutoff) {
+		i++
+	}
+	s.created = s.created[i:]
+
+	if s.issue != nil && s.lastError.Before(cutoff) {
+		log.Printf("Error storm over (storm issue #%d, %d distinct errors)", s.issue.Number, len(s.errors))
+		s.issue = nil
+		s.client = nil
+		s.errors = nil
+		s.before = nil
+	}
+}
+
+// recordCreated counts a newly created issue towards storm detection
+func (s *storm) recordCreated(title, url string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.created = append(s.created, createdIssue{at: time.Now(), title: title, url: url})
+}
+
+// collapseIntoStorm adds a new bug ID to the storm issue if a storm is active
+// or this bug ID starts one. It reports whether the entry was collapsed; if
+// not, a regular issue should be created.
+func (p *Processor) collapseIntoStorm(entry loki.LogEntry, bugID string) (bool, error) {
+	if p.storm == nil {
+		return false, nil
+	}
+
+	s := p.storm
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.prune(now)
+
+	if s.issue == nil {
+		// This bug ID would be the threshold+1-th distinct new one in the window
+		if len(s.created) < s.threshold {
+			return false, nil
+		}
+		if err := p.startStorm(now); err != nil {
+			return false, err
+		}
+	}
+
+	e, ok := s.errors[bugID]
+	if !ok {
+		e = &stormError{
+			bugID:     bugID,
+			title:     p.title(entry),
+			service:   entry.Service,
+			severity:  p.severity(entry),
+			firstSeen: seenTime(entry),
+		}
+		s.errors[bugID] = e
+	}
+	e.occurrences++
+	s.lastError = now
+
+	if err := s.client.UpdateIssueBody(s.issue.Number, generateStormBody(s)); err != nil {
+		log.Printf("Warning: failed to update storm issue #%d: %v", s.issue.Number, err)
+	}
+	p.debugf("Collapsed bug ID %s into storm issue #%d", bugID, s.issue.Number)
+	p.recordRecent(entry, OutcomeStorm, RecentEntry{
+		BugID:       bugID,
+		Title:       e.title,
+		Occurrences: e.occurrences,
+		Repo:        s.client.Repo(),
+		IssueNumber: s.issue.Number,
+	})
+	return true, nil
+}
+
+// startStorm creates the storm issue in the default repository and notifies
+// about it. Must be called with p.storm.mu held.
+func (p *Processor) startStorm(now time.Time) error {
+	s := p.storm
+	s.started = now
+	s.errors = make(map[string]*stormError)
+	s.before = append([]createdIssue(nil), s.created...)
+
+	title := fmt.Sprintf("Error storm: more than %d distinct errors within %s", s.threshold, shortDuration(s.window))
+	if err := p.tracker.EnsureLabel(stormLabel, "b60205"); err != nil { // dark red
+		log.Printf("Warning: failed to create storm label: %v", err)
+	}
+
+	issue, err := p.tracker.CreateIssueFromRequest(
+		gitea.CreateIssueRequest{Title: title, Body: generateStormBody(s)},
+		[]string{"auto-generated", stormLabel, "severity:" + SeverityCritical},
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create storm issue: %w", err)
+	}
+	s.issue = issue
+	s.client = p.tracker
+	log.Printf("Error storm detected: created storm issue #%d", issue.Number)
+
+	info := &notifier.IssueInfo{
+		Number:    issue.Number,
+		Title:     title,
+		URL:       issue.HTMLURL,
+		Severity:  SeverityCritical,
+		FirstSeen: now,
+	}
+	for _, n := range p.notifiersFor(info.Severity) {
+		if err := n.NotifyNewIssue(info); err != nil {
+			log.Printf("Error sending notification: %v", err)
+		}
+	}
+	return nil
+}
+
+// generateStormBody renders the storm issue body: a table of the collapsed
+// errors followed by the issues created earlier in the window
+func generateStormBody(s *storm) string {
+	var sb strings.Builder
+
+	sb.WriteString("## Error Storm\n\n")
+	sb.WriteString(fmt.Sprintf("More than %d distinct errors appeared within %s, starting `%s`. ",
+		s.threshold, shortDuration(s.window), s.started.Format(time.RFC3339)))
+	sb.WriteString("New errors are collected here instead of getting their own issues until the storm subsides.\n\n")
+
+	collapsed := make([]*stormError, 0, len(s.errors))
+	for _, e := range s.errors {
+		collapsed = append(collapsed, e)
+	}
+	sort.Slice(collapsed, func(i, j int) bool { return collapsed[i].firstSeen.Before(collapsed[j].firstSeen) })
+
+	sb.WriteString("| Bug ID | Error | Service | Severity | Occurrences | First Seen |\n")
+	sb.WriteString("|--------|-------|---------|----------|-------------|------------|\n")
+	for _, e := range collapsed {
+		sb.WriteString(fmt.Sprintf("| `%s` | %s | %s | %s | %d | `%s` |\n",
+			e.bugID, tableCell(e.title), tableCell(e.service), e.severity, e.occurrences, e.firstSeen.Format(time.RFC3339)))
+	}
+
+	if len(s.before) > 0 {
+		sb.WriteString("\n### Issues created before the storm was detected\n\n")
+		for _, c := range s.before {
+			sb.WriteString(fmt.Sprintf("- [%s](%s)\n", c.title, c.url))
+		}
+	}
+
+	sb.WriteString("\n---\n*Updated automatically by issue-tracker*\n")
+	return sb.String()
+}
+
+// tableCell escapes a value for a markdown table cell
+func tableCell(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return strings.NewReplacer("|", "\\|", "\n", " ").Replace(s)
+}
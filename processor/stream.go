@@ -0,0 +1,50 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vigil/loki"
+)
+
+// invalidLabelChars matches characters Loki replaces in extracted label names
+var invalidLabelChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// streamSelector builds a LogQL stream selector matching the stream an
+// entry was read from, e.g. {namespace="prod", pod="api-1"}, or "" if its
+// stream labels are unknown. Labels added by the query's json stage and by
+// Loki itself are left out, as they are not stream labels.
+func streamSelector(entry loki.LogEntry) string {
+	extracted := make(map[string]bool)
+	jsonLabelNames(entry.Parsed, "", extracted)
+
+	var matchers []string
+	for name, value := range entry.Labels {
+		if strings.HasPrefix(name, "__") || name == "detected_level" || strings.HasSuffix(name, "_extracted") || extracted[name] {
+			continue
+		}
+		matchers = append(matchers, fmt.Sprintf("%s=%s", name, strconv.Quote(value)))
+	}
+	if len(matchers) == 0 {
+		return ""
+	}
+
+	sort.Strings(matchers)
+	return "{" + strings.Join(matchers, ", ") + "}"
+}
+
+// jsonLabelNames adds the label names Loki's json parser extracts from a
+// parsed log line: nested keys are joined with '_'
+func jsonLabelNames(parsed map[string]interface{}, prefix string, names map[string]bool) {
+	for key, value := range parsed {
+		name := prefix + invalidLabelChars.ReplaceAllString(key, "_")
+		if nested, ok := value.(map[string]interface{}); ok {
+			jsonLabelNames(nested, name+"_", names)
+			continue
+		}
+		names[name] = true
+	}
+}
@@ -0,0 +1,137 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"vigil/notifier"
+)
+
+// maxTopRecurring is the number of most recurring issues in a digest
+const maxTopRecurring = 10
+
+// periodSummary accumulates issue activity for the scheduled digest
+type periodSummary struct {
+	mu     sync.Mutex
+	from   time.Time
+	issues map[string]*periodIssue // keyed by "owner/repo#number"
+}
+
+// periodIssue is the activity on one issue within the period
+type periodIssue struct {
+	info     notifier.IssueInfo
+	created  bool
+	reopened bool
+}
+
+func newPeriodSummary() *periodSummary {
+	return &periodSummary{from: time.Now(), issues: make(map[string]*periodIssue)}
+}
+
+// record adds an occurrence of an issue in repo. info describes the issue
+// with its total occurrences; created and reopened tell what the occurrence
+// did to it.
+func (s *periodSummary) record(repo string, info notifier.IssueInfo, created, reopened bool) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := fmt.Sprintf("%s#%d", repo, info.Number)
+	issue, ok := s.issues[key]
+	if !ok {
+		issue = &periodIssue{}
+		s.issues[key] = issue
+	}
+	newOccurrences := issue.info.NewOccurrences + 1
+	issue.info = info
+	issue.info.NewOccurrences = newOccurrences
+	issue.created = issue.created || created
+	issue.reopened = issue.reopened || reopened
+}
+
+// drain returns the summary of the period ending at to and starts a new one
+func (s *periodSummary) drain(to time.Time) *notifier.DigestSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	summary := &notifier.DigestSummary{From: s.from, To: to}
+	var all []*notifier.IssueInfo
+	for _, issue := range s.issues {
+		info := issue.info
+		info.Reopened = issue.reopened
+		if issue.created {
+			summary.NewIssues = append(summary.NewIssues, &info)
+		}
+		if issue.reopened {
+			summary.ReopenedIssues = append(summary.ReopenedIssues, &info)
+		}
+		all = append(all, &info)
+		summary.TotalOccurrences += info.NewOccurrences
+	}
+	s.from = to
+	s.issues = make(map[string]*periodIssue)
+
+	byNumber := func(issues []*notifier.IssueInfo) {
+		sort.Slice(issues, func(i, j int) bool { return issues[i].Number < issues[j].Number })
+	}
+	byNumber(summary.NewIssues)
+	byNumber(summary.ReopenedIssues)
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].NewOccurrences != all[j].NewOccurrences {
+			return all[i].NewOccurrences > all[j].NewOccurrences
+		}
+		return all[i].Number < all[j].Number
+	})
+	if len(all) > maxTopRecurring {
+		all = all[:maxTopRecurring]
+	}
+	summary.TopRecurring = all
+
+	return summary
+}
+
+// runScheduledDigest sends the scheduled digest when it is due until the
+// context is cancelled
+func (p *Processor) runScheduledDigest(ctx context.Context) {
+	for {
+		next := p.digestSchedule.Next(time.Now())
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case now := <-timer.C:
+			p.sendScheduledDigest(now)
+		}
+	}
+}
+
+// sendScheduledDigest sends the activity since the last digest to every
+// notifier that supports digests
+func (p *Processor) sendScheduledDigest(now time.Time) {
+	summary := p.summary.drain(now)
+	if summary.TotalOccurrences == 0 {
+		p.debugf("No activity since %s, skipping the scheduled digest", summary.From.Format(time.RFC3339))
+		return
+	}
+	if p.notificationsSuppressed() {
+		log.Printf("Dropping the scheduled digest: notifications are suppressed")
+		return
+	}
+
+	log.Printf("Sending scheduled digest: %d new, %d reopened, %d occurrences",
+		len(summary.NewIssues), len(summary.ReopenedIssues), summary.TotalOccurrences)
+	for _, n := range p.notifiers {
+		if err := notifier.SendDigest(n, summary); err != nil {
+			log.Printf("Error sending %s scheduled digest: %v", n.Name(), err)
+		}
+	}
+}
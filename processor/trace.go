@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"vigil/loki"
+	"vigil/notifier"
+)
+
+// dedupByTrace collapses entries sharing a trace ID into one representative
+// entry, so a request failing through several layers counts as a single
+// occurrence. The most severe entry of a trace is kept, the earliest one on
+// ties. Entries without a trace ID are kept as they are. severity returns
+// the severity of an entry. It returns the remaining entries and the number
+// of entries dropped.
+func dedupByTrace(entries []loki.LogEntry, severity func(loki.LogEntry) string) ([]loki.LogEntry, int) {
+	deduped := make([]loki.LogEntry, 0, len(entries))
+	index := make(map[string]int) // trace ID -> position in deduped
+
+	for _, entry := range entries {
+		if entry.TraceID == "" {
+			deduped = append(deduped, entry)
+			continue
+		}
+
+		i, ok := index[entry.TraceID]
+		if !ok {
+			index[entry.TraceID] = len(deduped)
+			deduped = append(deduped, entry)
+			continue
+		}
+
+		if representsTraceBetter(entry, deduped[i], severity) {
+			deduped[i] = entry
+		}
+	}
+
+	return deduped, len(entries) - len(deduped)
+}
+
+// representsTraceBetter reports whether entry should replace current as the
+// representative of their trace
+func representsTraceBetter(entry, current loki.LogEntry, severity func(loki.LogEntry) string) bool {
+	rank, currentRank := notifier.SeverityRank(severity(entry)), notifier.SeverityRank(severity(current))
+	if rank != currentRank {
+		return rank > currentRank
+	}
+	return entry.Timestamp.Before(current.Timestamp)
+}
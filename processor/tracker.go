@@ -0,0 +1,27 @@
+package processor
+
+import "vigil/gitea"
+
+// IssueTracker is the backend issues are filed in. *gitea.Client implements
+// it; other backends (e.g. GitLab) map their API onto the gitea types.
+type IssueTracker interface {
+	// Repo names the repository or project issues are filed in
+	Repo() string
+	TestConnection() error
+
+	SearchIssues(label string) ([]gitea.Issue, error)
+	GetIssue(issueNumber int64) (*gitea.Issue, error)
+	CreateIssueFromRequest(req gitea.CreateIssueRequest, labels []string) (*gitea.Issue, error)
+	UpdateIssueBody(issueNumber int64, body string) error
+	ReopenIssue(issueNumber int64) error
+	CloseIssue(issueNumber int64) error
+
+	AddComment(issueNumber int64, body string) error
+	CreateComment(issueNumber int64, body string) (*gitea.Comment, error)
+	ListComments(issueNumber int64) ([]gitea.Comment, error)
+	UpdateComment(commentID int64, body string) error
+
+	EnsureLabel(name, color string) error
+	AddLabels(issueNumber int64, labels []string) error
+	RemoveLabel(issueNumber int64, label string) error
+}
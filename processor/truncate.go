@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxBodyBytes keeps issue bodies safely below Gitea's size limit
+const DefaultMaxBodyBytes = 60000
+
+// MaxTitleLength is the longest issue title Gitea accepts
+const MaxTitleLength = 255
+
+// DefaultMaxTitleLength is the default length titles are shortened to
+const DefaultMaxTitleLength = 120
+
+// titleSeparator joins the parts of a title
+const titleSeparator = " - "
+
+// truncatedMarker is appended where content was cut
+const truncatedMarker = "\n... (truncated)"
+
+// truncateBytes cuts s to at most n bytes without splitting a UTF-8 character
+func truncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// fitTitle joins the parts of a title, shortening it to at most maxLength
+// characters. The leading parts (e.g. status and endpoint) are kept whole
+// where possible and the trailing ones (e.g. the message) cut at a word
+// boundary.
+func fitTitle(leading, trailing []string, maxLength int) string {
+	if maxLength <= 0 || maxLength > MaxTitleLength {
+		maxLength = MaxTitleLength
+	}
+
+	title := strings.Join(append(append([]string{}, leading...), trailing...), titleSeparator)
+	if utf8.RuneCountInString(title) <= maxLength {
+		return title
+	}
+
+	prefix := strings.Join(leading, titleSeparator)
+	if len(trailing) == 0 || prefix == "" {
+		return truncateWords(title, maxLength)
+	}
+
+	// Keep at least a few words of the trailing parts, or else shorten the
+	// leading parts too
+	prefix += titleSeparator
+	available := maxLength - utf8.RuneCountInString(prefix)
+	if available < 20 {
+		return truncateWords(title, maxLength)
+	}
+	return prefix + truncateWords(strings.Join(trailing, titleSeparator), available)
+}
+
+// truncateWords cuts s to at most n characters including a trailing
+// ellipsis, at the last word boundary unless that would drop more than half
+// of the text kept
+func truncateWords(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n <= 1 {
+		return "…"
+	}
+
+	cut := string(runes[:n-1])
+	if i := strings.LastIndexByte(cut, ' '); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " -:,;") + "…"
+}
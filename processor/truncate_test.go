@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFitTitle(t *testing.T) {
+	tests := []struct {
+		name      string
+		leading   []string
+		trailing  []string
+		maxLength int
+		want      string
+	}{
+		{
+			name:      "under the limit",
+			leading:   []string{"[500]", "POST /api/orders"},
+			trailing:  []string{"order not found"},
+			maxLength: 80,
+			want:      "[500] - POST /api/orders - order not found",
+		},
+		{
+			name:      "long message cut at a word boundary",
+			leading:   []string{"[500]", "POST /api/orders"},
+			trailing:  []string{"PaymentDeclinedError", "card issuer declined the transaction because of insufficient funds"},
+			maxLength: 80,
+			want:      "[500] - POST /api/orders - PaymentDeclinedError - card issuer declined the…",
+		},
+		{
+			name:      "single word longer than the limit",
+			trailing:  []string{strings.Repeat("x", 40)},
+			maxLength: 20,
+			want:      strings.Repeat("x", 19) + "…",
+		},
+		{
+			name:      "long endpoint shortened before the message",
+			leading:   []string{"[500]", "GET /api/v1/organizations/{id}/projects/{id}/pipelines/{id}/artifacts"},
+			trailing:  []string{"artifact missing"},
+			maxLength: 60,
+			want:      "[500] - GET /api/v1/organizations/{id}/projects/{id}/pipeli…",
+		},
+		{
+			name:      "multibyte runes at the cut point",
+			leading:   []string{"[ERROR]"},
+			trailing:  []string{"日本語のエラーメッセージがとても長くて切り詰める必要があります"},
+			maxLength: 30,
+			want:      "[ERROR] - 日本語のエラーメッセージがとても長くて…",
+		},
+		{
+			name:      "multibyte runes without a word boundary",
+			trailing:  []string{strings.Repeat("ü", 30)},
+			maxLength: 10,
+			want:      strings.Repeat("ü", 9) + "…",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := fitTitle(tt.leading, tt.trailing, tt.maxLength)
+			if got != tt.want {
+				t.Errorf("fitTitle() = %q, want %q", got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("fitTitle() = %q is not valid UTF-8", got)
+			}
+			if n := utf8.RuneCountInString(got); n > tt.maxLength {
+				t.Errorf("fitTitle() is %d characters, want at most %d", n, tt.maxLength)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package processor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"vigil/loki"
+)
+
+// trackerUsername matches the usernames Gitea and GitLab accept
+var trackerUsername = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+// LoadUserMap reads a JSON object mapping application user IDs to issue
+// tracker usernames, e.g. {"1234": "alice"}. A leading "@" on a username is
+// dropped.
+func LoadUserMap(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user map: %w", err)
+	}
+
+	var raw map[string]string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid user map (expected a JSON object of user ID to username): %w", err)
+	}
+
+	users := make(map[string]string, len(raw))
+	for userID, username := range raw {
+		username = strings.TrimPrefix(strings.TrimSpace(username), "@")
+		if !trackerUsername.MatchString(username) {
+			return nil, fmt.Errorf("invalid username %q for user ID %q", username, userID)
+		}
+		users[userID] = username
+	}
+	return users, nil
+}
+
+// trackerUser returns the issue tracker username of the user an entry was
+// logged for, or "" if the entry has no user ID or it isn't mapped
+func (p *Processor) trackerUser(entry loki.LogEntry) string {
+	if entry.UserID == "" {
+		return ""
+	}
+	return p.userMap[entry.UserID]
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net/http"
+
+	"vigil/processor"
+)
+
+// LivenessFunc reports when the poll loop last ran
+type LivenessFunc func() processor.Liveness
+
+// HealthHandler returns a handler reporting the liveness of the poll loop
+// as JSON. It responds 503 once the watchdog considers the loop stalled, so
+// it can back a liveness probe.
+func HealthHandler(liveness LivenessFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSON(w, http.StatusMethodNotAllowed, ingestResponse{Status: "error", Error: "method not allowed"})
+			return
+		}
+
+		status := liveness()
+		code := http.StatusOK
+		if status.Status == processor.LivenessStalled {
+			code = http.StatusServiceUnavailable
+		}
+		writeJSON(w, code, status)
+	})
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"vigil/loki"
+)
+
+// maxIngestBytes limits the size of a submitted log line
+const maxIngestBytes = 1 << 20
+
+// ParseFunc builds a log entry from a submitted log line
+type ParseFunc func(ts time.Time, line string) loki.LogEntry
+
+// SubmitFunc processes a submitted log entry and reports whether it was
+// turned into an issue. Loki lookups made for it stop when ctx is cancelled.
+type SubmitFunc func(ctx context.Context, entry loki.LogEntry) (bool, error)
+
+// ingestResponse is returned by the ingest endpoint
+type ingestResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IngestHandler returns a handler that accepts a JSON log line via POST and
+// processes it immediately. Requests must carry the shared token as a bearer
+// token.
+func IngestHandler(token string, parse ParseFunc, submit SubmitFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSON(w, http.StatusMethodNotAllowed, ingestResponse{Status: "error", Error: "method not allowed"})
+			return
+		}
+
+		if !validToken(r, token) {
+			writeJSON(w, http.StatusUnauthorized, ingestResponse{Status: "error", Error: "invalid token"})
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIngestBytes+1))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, ingestResponse{Status: "error", Error: "failed to read body"})
+			return
+		}
+		if len(body) > maxIngestBytes {
+			writeJSON(w, http.StatusRequestEntityTooLarge, ingestResponse{Status: "error", Error: "body too large"})
+			return
+		}
+		if !json.Valid(body) {
+			writeJSON(w, http.StatusBadRequest, ingestResponse{Status: "error", Error: "body must be a JSON log line"})
+			return
+		}
+
+		entry := parse(time.Now(), string(body))
+		processed, err := submit(r.Context(), entry)
+		if err != nil {
+			log.Printf("Error processing ingested entry: %v", err)
+			writeJSON(w, http.StatusBadGateway, ingestResponse{Status: "error", Error: err.Error()})
+			return
+		}
+
+		if !processed {
+			writeJSON(w, http.StatusAccepted, ingestResponse{Status: "ignored"})
+			return
+		}
+		writeJSON(w, http.StatusAccepted, ingestResponse{Status: "processed"})
+	})
+}
+
+// validToken checks the bearer token in constant time
+func validToken(r *http.Request, token string) bool {
+	provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// writeJSON writes a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
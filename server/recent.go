@@ -0,0 +1,87 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"vigil/processor"
+)
+
+// RecentFunc returns the last processed errors, newest first
+type RecentFunc func() []processor.RecentEntry
+
+// recentResponse is returned by the recent endpoint
+type recentResponse struct {
+	Entries []processor.RecentEntry `json:"entries"`
+}
+
+// RecentHandler returns a read-only handler listing the last processed
+// errors as JSON
+func RecentHandler(recent RecentFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			writeJSON(w, http.StatusMethodNotAllowed, ingestResponse{Status: "error", Error: "method not allowed"})
+			return
+		}
+
+		entries := recent()
+		if entries == nil {
+			entries = []processor.RecentEntry{}
+		}
+		writeJSON(w, http.StatusOK, recentResponse{Entries: entries})
+	})
+}
+
+// recentPage renders the recent errors as a plain HTML table
+var recentPage = template.Must(template.New("recent").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>vigil - recent errors</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border-bottom: 1px solid #ddd; padding: 0.4em; text-align: left; vertical-align: top; }
+th { background: #f5f5f5; }
+code { font-size: 0.9em; }
+.reason { color: #777; font-size: 0.9em; }
+</style>
+</head>
+<body>
+<h1>Recent errors</h1>
+<p>The last {{len .}} errors processed by vigil, newest first. <a href="/api/recent">JSON</a></p>
+<table>
+<tr><th>Last Seen</th><th>Outcome</th><th>Title</th><th>Severity</th><th>Service</th><th>Count</th><th>Issue</th><th>Bug ID</th></tr>
+{{range .}}<tr>
+<td>{{.LastSeen.Format "2006-01-02 15:04:05Z07:00"}}</td>
+<td>{{.Outcome}}{{if .Reason}}<div class="reason">{{.Reason}}</div>{{end}}</td>
+<td>{{.Title}}</td>
+<td>{{.Severity}}</td>
+<td>{{.Service}}</td>
+<td>{{if .Occurrences}}{{.Occurrences}}{{end}}</td>
+<td>{{if .IssueNumber}}{{.Repo}}#{{.IssueNumber}}{{end}}</td>
+<td><code>{{.BugID}}</code></td>
+</tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// RecentPageHandler returns a read-only handler showing the last processed
+// errors as an HTML page
+func RecentPageHandler(recent RecentFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := recentPage.Execute(w, recent()); err != nil {
+			log.Printf("Error writing response: %v", err)
+		}
+	})
+}
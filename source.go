@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+
+	"vigil/config"
+	"vigil/loki"
+	"vigil/processor"
+)
+
+// setupSource reads the SOURCE setting
+func setupSource(cfg *config.Config) string {
+	switch source := cfg.Log.Source; source {
+	case "", processor.SourceLoki:
+		return processor.SourceLoki
+	case processor.SourceFile:
+		return source
+	default:
+		log.Fatalf("Invalid SOURCE %q (expected %q or %q)", source, processor.SourceLoki, processor.SourceFile)
+		return ""
+	}
+}
+
+// runFileSource processes the log lines in SOURCE_FILE, or stdin if it is
+// empty or "-", without querying Loki
+func runFileSource(cfg *config.Config, proc *processor.Processor, parser loki.LineParser) {
+	input, name := os.Stdin, "stdin"
+	if path := cfg.Log.SourceFile; path != "" && path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatalf("Invalid SOURCE_FILE: %v", err)
+		}
+		defer f.Close()
+		input, name = f, path
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	log.Printf("Reading log lines from %s", name)
+	if err := proc.ProcessLines(ctx, input, parser.Parse); err != nil {
+		log.Fatalf("Processing %s failed: %v", name, err)
+	}
+}
@@ -0,0 +1,106 @@
+package transport
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Version is the vigil version reported in the User-Agent header. It is set
+// at build time with -ldflags "-X vigil/transport.Version=v1.2.3".
+var Version = "dev"
+
+// RequestIDHeader carries a unique ID for every outbound request so calls
+// can be traced in proxy logs
+const RequestIDHeader = "X-Request-ID"
+
+// UserAgent returns the User-Agent header value sent on outbound requests
+func UserAgent() string {
+	return "vigil/" + Version
+}
+
+// Headers returns the identifying headers for a single outbound request,
+// for connections that don't go through an http.Client (e.g. websockets)
+func Headers() http.Header {
+	h := make(http.Header)
+	h.Set("User-Agent", UserAgent())
+	h.Set(RequestIDHeader, newRequestID())
+	return h
+}
+
+// proxyURL, if set, is used for all outbound requests instead of the proxy
+// from the environment (see SetProxy)
+var proxyURL *url.URL
+
+// SetProxy sends all outbound requests through the given proxy (http, https
+// or socks5), overriding HTTP_PROXY, HTTPS_PROXY and NO_PROXY. It must be
+// called before any requests are made.
+func SetProxy(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q (expected http, https or socks5)", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy URL %q has no host", rawURL)
+	}
+	proxyURL = u
+	return nil
+}
+
+// Proxy returns the proxy for a request: the one set with SetProxy, or the
+// one configured by HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+func Proxy(req *http.Request) (*url.URL, error) {
+	if proxyURL != nil {
+		return proxyURL, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// Base returns a new transport with the default settings that uses Proxy,
+// for clients that need to customize it (e.g. its TLS config)
+func Base() *http.Transport {
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.Proxy = Proxy
+	return base
+}
+
+// Wrap returns a RoundTripper that adds the User-Agent and a request ID to
+// every request before passing it to base (a new Base transport if nil)
+func Wrap(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = Base()
+	}
+	return &roundTripper{base: base}
+}
+
+type roundTripper struct {
+	base http.RoundTripper
+}
+
+// RoundTrip sets the identifying headers on a copy of the request
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", UserAgent())
+	}
+	if req.Header.Get(RequestIDHeader) == "" {
+		req.Header.Set(RequestIDHeader, newRequestID())
+	}
+	return t.base.RoundTrip(req)
+}
+
+// newRequestID returns a random 16-character hex ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}